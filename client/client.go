@@ -5,41 +5,87 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"hash/fnv"
 	"math/rand"
 	v1 "mizuflow/pkg/api/v1"
 	"mizuflow/pkg/constraints"
 	"mizuflow/pkg/logger"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"slices"
-
 	"go.uber.org/zap"
 )
 
+const DefaultNamespace = "default"
+
+var errHeartbeatTimeout = errors.New("sse heartbeat timeout")
+
+// Client is the evaluation surface of the SDK. MizuClient implements it against
+// the server; mizutest.Client implements it in memory for tests.
+type Client interface {
+	IsEnabled(key string, context map[string]string) bool
+	IsEnabledIn(namespace, key string, context map[string]string) bool
+	GetString(key string, defaultValue string, context map[string]string) string
+	GetStringIn(namespace, key string, defaultValue string, context map[string]string) string
+	GetNumber(key string, defaultValue float64, context map[string]string) float64
+	GetNumberIn(namespace, key string, defaultValue float64, context map[string]string) float64
+	GetJSON(key string, target any, context map[string]string) error
+	GetJSONIn(namespace, key string, target any, context map[string]string) error
+	EvaluateDetail(key string, context EvalContext) EvaluationDetail
+	EvaluateDetailIn(namespace, key string, context EvalContext) EvaluationDetail
+	OnChange(key string, fn ChangeFunc) func()
+	OnChangeIn(namespace, key string, fn ChangeFunc) func()
+	OnAnyChange(fn ChangeFunc) func()
+}
+
+var _ Client = (*MizuClient)(nil)
+
 type MizuClient struct {
-	addr       string
-	env        string
-	namespaces []string
-	apiKey     string
-	httpClient *http.Client
-	cacheFile  string
+	addr             string
+	endpoints        *endpointSet
+	env              string
+	namespaces       []string
+	defaultNamespace string
+	apiKey           string
+	httpClient       *http.Client
+	cacheFile        string
 
 	snapshotIntervalMin time.Duration
 	snapshotIntervalMax time.Duration
 
+	transport     Transport
+	pollInterval  time.Duration
+	sseRetryAfter time.Duration
+	watchBackoff  time.Duration // first SSE reconnect delay, doubled up to 30s
+
 	mu       sync.RWMutex
-	features map[string]v1.FeatureFlag
+	features map[string]v1.FeatureFlag // keyed by featureID(namespace, key)
 	lastRev  int64
+	etag     string // of the last snapshot response, for conditional polls
 	isDirty  bool
 
+	hooks     listenerSet
+	exposures *exposureRecorder
+	overrides *overrideSource
+	mirror    Mirror
+	table     atomic.Pointer[flagTable] // compiled features, read without c.mu
+
+	state     atomic.Int32 // State
+	lastSync  atomic.Int64 // unix nanos
+	ready     chan struct{}
+	readyOnce sync.Once
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	stateMu        sync.Mutex
+	nextStateID    uint64
+	stateListeners map[uint64]func(State)
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -58,6 +104,13 @@ func WithHTTPClient(client *http.Client) Option {
 	}
 }
 
+// WithDefaultNamespace sets the namespace used by the accessors that take no namespace argument.
+func WithDefaultNamespace(namespace string) Option {
+	return func(c *MizuClient) {
+		c.defaultNamespace = namespace
+	}
+}
+
 func WithSnapshotInterval(min, max time.Duration) Option {
 	return func(c *MizuClient) {
 		c.snapshotIntervalMin = min
@@ -76,41 +129,90 @@ func NewMizuClient(addr, env, apiKey string, namespaces []string, opts ...Option
 		httpClient:          &http.Client{Timeout: 10 * time.Second},
 		snapshotIntervalMin: 10 * time.Second,
 		snapshotIntervalMax: 30 * time.Second,
+		pollInterval:        15 * time.Second,
+		sseRetryAfter:       5 * time.Minute,
+		watchBackoff:        time.Second,
 		features:            make(map[string]v1.FeatureFlag),
+		ready:               make(chan struct{}),
 		ctx:                 ctx,
 		cancel:              cancel,
 	}
 
+	c.endpoints = newEndpointSet([]string{addr})
+	if len(namespaces) > 0 {
+		c.defaultNamespace = namespaces[0]
+	} else {
+		c.defaultNamespace = DefaultNamespace
+	}
+
 	for _, opt := range opts {
 		opt(c)
 	}
 	return c
 }
 
+// DefaultNamespace is the namespace of the accessors that take no namespace argument.
+func (c *MizuClient) DefaultNamespace() string {
+	return c.defaultNamespace
+}
+
+// featureID builds the store key of a flag; flags are unique per namespace, not globally.
+func featureID(namespace, key string) string {
+	return namespace + "/" + key
+}
+
 func (c *MizuClient) Start() error {
+	if c.overrides != nil {
+		c.startOverrides()
+	}
 	if err := c.fetchAll(); err != nil {
 		logger.Warn("failed to fetch from server, attempting to load from local cache", zap.Error(err))
 		if loadErr := c.loadSnapshot(); loadErr != nil {
 			return fmt.Errorf("failed to fetch from server: %w, and failed to load from cache: %w", err, loadErr)
 		}
+		c.setState(StateStaleCache)
 		logger.Info("loaded features from local cache persistence")
 	}
-	go c.runWatchLoop()
-	go c.runSnapshotLoop()
+	c.goLoop(c.runWatchLoop)
+	c.goLoop(c.runSnapshotLoop)
+	if c.exposures != nil {
+		c.goLoop(c.runExposureLoop)
+	}
+	if c.overrides != nil && !c.overrides.refused && c.overrides.path != "" {
+		c.goLoop(c.runOverrideLoop)
+	}
 	return nil
 }
 
+// fetchAll loads the full snapshot, trying every endpoint from the healthiest.
 func (c *MizuClient) fetchAll() error {
+	var err error
+	for range c.endpoints.size() {
+		addr := c.endpoints.get()
+		if err = c.fetchFrom(addr); err == nil {
+			c.endpoints.success(addr)
+			return nil
+		}
+		c.endpoints.failure(addr)
+	}
+	return err
+}
+
+func (c *MizuClient) fetchFrom(addr string) error {
 	nsParam := strings.Join(c.namespaces, ",")
-	url := fmt.Sprintf("%s/v1/stream/snapshot?env=%s&namespace=%s", c.addr, c.env, nsParam)
+	url := fmt.Sprintf("%s/v1/stream/snapshot?env=%s&namespace=%s", addr, c.env, nsParam)
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("X-Mizu-Key", c.apiKey)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		logger.Error("failed to fetch all features", zap.Error(err))
+		logger.Error("failed to fetch all features", zap.String("endpoint", addr), zap.Error(err))
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("failed to fetch all features", zap.String("endpoint", addr), zap.Int("status", resp.StatusCode))
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
 
 	var res struct {
 		Data     []v1.FeatureFlag `json:"data"`
@@ -120,117 +222,195 @@ func (c *MizuClient) fetchAll() error {
 		logger.Error("failed to decode features response", zap.Error(err))
 		return err
 	}
+	c.replaceFeatures(res.Data, res.Revision, resp.Header.Get("ETag"))
+	c.markSynced()
+	return nil
+}
+
+// replaceFeatures installs a full snapshot. It holds every flag of the subscribed
+// namespaces, so it replaces the store and drops flags deleted while we were not watching.
+func (c *MizuClient) replaceFeatures(data []v1.FeatureFlag, rev int64, etag string) {
+	features := make(map[string]v1.FeatureFlag, len(data))
+	for _, f := range data {
+		features[featureID(f.Namespace, f.Key)] = f
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	for _, f := range res.Data {
-		c.features[f.Key] = f
-	}
-	c.lastRev = res.Revision
+	c.hooks.dispatch(diffFeatures(c.features, features, rev))
+	c.features = features
+	c.rebuildTable()
+	c.mirrorReset(features, rev)
+	c.lastRev = rev
+	c.etag = etag
 	c.isDirty = true
-	return nil
 }
 
+// runWatchLoop keeps the client in sync over SSE. In TransportAuto it falls back
+// to polling after repeated failed connections and retries SSE later.
 func (c *MizuClient) runWatchLoop() {
-	backoff := time.Second
+	if c.transport == TransportPolling {
+		c.runPolling(0)
+		return
+	}
+
+	backoff := c.watchBackoff
 	maxBackoff := 30 * time.Second
+	failures := 0
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
 		default:
-			c.mu.RLock()
-			nsParam := strings.Join(c.namespaces, ",")
-			url := fmt.Sprintf("%s/v1/stream/watch?last_rev=%d&env=%s&namespace=%s", c.addr, c.lastRev, c.env, nsParam)
-			c.mu.RUnlock()
-
-			// Use sub-context for request cancellation
-			reqCtx, reqCancel := context.WithCancel(c.ctx)
-			req, _ := http.NewRequestWithContext(reqCtx, "GET", url, nil)
-			req.Header.Set("X-Mizu-Key", c.apiKey)
-			resp, err := c.httpClient.Do(req)
-			if err != nil {
-				reqCancel()
-				jitter := time.Duration(rand.Int63n(int64(backoff / 2)))
-				logger.Warn("SSE disconnected", zap.Error(err))
-				time.Sleep(backoff + jitter)
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
+		}
+
+		if c.transport == TransportAuto && failures >= sseFailureThreshold {
+			logger.Warn("SSE keeps failing, falling back to polling", zap.Int("failures", failures), zap.Duration("retry_sse_after", c.sseRetryAfter))
+			c.runPolling(c.sseRetryAfter)
+			failures = 0
+			backoff = c.watchBackoff
+			continue
+		}
+
+		addr := c.endpoints.get()
+		received, err := c.watchOnce(addr)
+		if c.ctx.Err() != nil {
+			return
+		}
+		healthyNext := false
+		if err != nil || !received {
+			healthyNext = c.endpoints.failure(addr)
+		} else {
+			c.endpoints.success(addr)
+		}
+		if received {
+			failures = 0
+			backoff = c.watchBackoff
+			continue
+		}
+		failures++
+		logger.Warn("SSE disconnected", zap.String("endpoint", addr), zap.Int("failures", failures), zap.Error(err))
+		if healthyNext {
+			// another replica has not failed recently, resume there from lastRev right away
+			continue
+		}
+		jitter := time.Duration(rand.Int63n(int64(backoff / 2)))
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(backoff + jitter):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// watchOnce runs a single SSE connection until it ends. received reports
+// whether anything arrived over it; a proxy that buffers the stream delivers
+// nothing until the heartbeat watchdog gives up.
+func (c *MizuClient) watchOnce(addr string) (received bool, err error) {
+	c.mu.RLock()
+	nsParam := strings.Join(c.namespaces, ",")
+	url := fmt.Sprintf("%s/v1/stream/watch?last_rev=%d&env=%s&namespace=%s", addr, c.lastRev, c.env, nsParam)
+	c.mu.RUnlock()
+
+	// Use sub-context for request cancellation
+	reqCtx, reqCancel := context.WithCancel(c.ctx)
+	defer reqCancel()
+	req, _ := http.NewRequestWithContext(reqCtx, "GET", url, nil)
+	req.Header.Set("X-Mizu-Key", c.apiKey)
+	resp, err := c.httpClient.Do(req)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err != nil {
+		c.markDisconnected()
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	// Watchdog for heartbeats
+	var lastActivity int64 = time.Now().Unix()
+	var timedOut atomic.Bool
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-reqCtx.Done():
+				return
+			case <-ticker.C:
+				if time.Now().Unix()-atomic.LoadInt64(&lastActivity) > 25 {
+					logger.Warn("sse heartbeat timeout, reconnecting", zap.String("endpoint", addr))
+					timedOut.Store(true)
+					reqCancel()
+					return
 				}
-				continue
 			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	var eventType string
+	var dataBuffer bytes.Buffer
 
-			// Watchdog for heartbeats
-			var lastActivity int64 = time.Now().Unix()
-			go func() {
-				ticker := time.NewTicker(5 * time.Second)
-				defer ticker.Stop()
-				for {
-					select {
-					case <-reqCtx.Done():
-						return
-					case <-ticker.C:
-						if time.Now().Unix()-atomic.LoadInt64(&lastActivity) > 25 {
-							logger.Warn("sse heartbeat timeout, reconnecting")
-							reqCancel()
-							return
-						}
-					}
+	for scanner.Scan() {
+		if !received {
+			// the compensation or the first heartbeat brings us up to date
+			received = true
+			c.markSynced()
+		}
+		atomic.StoreInt64(&lastActivity, time.Now().Unix())
+		line := scanner.Text()
+		if line == "" {
+			// Process the accumulated message
+			if eventType == "reset" {
+				logger.Warn("received reset event, re-fetching all features")
+				if err := c.fetchAll(); err != nil {
+					logger.Error("failed to refetch features after reset", zap.Error(err))
 				}
-			}()
-
-			backoff = time.Second
-			scanner := bufio.NewScanner(resp.Body)
-
-			var eventType string
-			var dataBuffer bytes.Buffer
-
-			for scanner.Scan() {
-				atomic.StoreInt64(&lastActivity, time.Now().Unix())
-				line := scanner.Text()
-				if line == "" {
-					// Process the accumulated message
-					if eventType == "reset" {
-						logger.Warn("received reset event, re-fetching all features")
-						if err := c.fetchAll(); err != nil {
-							logger.Error("failed to refetch features after reset", zap.Error(err))
-						}
-						// Close current stream
-						reqCancel()
-						break
-					} else if eventType == "ping" {
-						eventType = ""
-						dataBuffer.Reset()
-						continue
-					} else if dataBuffer.Len() > 0 {
-						var msg v1.Message
-						if err := json.Unmarshal(dataBuffer.Bytes(), &msg); err == nil {
-							c.handleUpdate(msg)
-						} else {
-							logger.Error("failed to unmarshal feature update", zap.Error(err))
-						}
-					}
-
-					// Reset buffers for next message
-					eventType = ""
-					dataBuffer.Reset()
-					continue
+				// Close current stream
+				break
+			} else if eventType == "ping" {
+				c.touchSync()
+				eventType = ""
+				dataBuffer.Reset()
+				continue
+			} else if dataBuffer.Len() > 0 {
+				var msg v1.Message
+				if err := json.Unmarshal(dataBuffer.Bytes(), &msg); err == nil {
+					c.handleUpdate(msg)
+				} else {
+					logger.Error("failed to unmarshal feature update", zap.Error(err))
 				}
+			}
 
-				if strings.HasPrefix(line, "event: ") {
-					eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
-				} else if strings.HasPrefix(line, "data:") {
-					// Spec allows multiple data lines, joined by newline
-					if dataBuffer.Len() > 0 {
-						dataBuffer.WriteString("\n")
-					}
-					dataBuffer.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
-				}
+			// Reset buffers for next message
+			eventType = ""
+			dataBuffer.Reset()
+			continue
+		}
+
+		if strings.HasPrefix(line, "event:") {
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		} else if strings.HasPrefix(line, "data:") {
+			// Spec allows multiple data lines, joined by newline
+			if dataBuffer.Len() > 0 {
+				dataBuffer.WriteString("\n")
 			}
-			reqCancel()
-			resp.Body.Close()
+			dataBuffer.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
 		}
 	}
+	if c.ctx.Err() == nil {
+		c.markDisconnected()
+	}
+	if timedOut.Load() {
+		return received, errHeartbeatTimeout
+	}
+	return received, scanner.Err()
 }
 
 func (c *MizuClient) handleUpdate(msg v1.Message) {
@@ -241,119 +421,90 @@ func (c *MizuClient) handleUpdate(msg v1.Message) {
 		return
 	}
 	latency := time.Now().UnixMilli() - msg.UpdatedAt
-	logger.Info("feature update received", zap.String("key", msg.Key), zap.String("action", string(msg.Action)), zap.Int64("rev", msg.Revision), zap.Int64("latency_ms", latency))
+	logger.Info("feature update received", zap.String("namespace", msg.Namespace), zap.String("key", msg.Key), zap.String("action", string(msg.Action)), zap.Int64("rev", msg.Revision), zap.Int64("latency_ms", latency))
+	id := featureID(msg.Namespace, msg.Key)
+	old, existed := c.features[id]
 	switch msg.Action {
 	case constraints.DELETE:
-		delete(c.features, msg.Key)
-		logger.Info("feature deleted", zap.String("key", msg.Key), zap.Int64("rev", msg.Revision))
+		delete(c.features, id)
+		c.storeCompiled(msg.Namespace, msg.Key, nil)
+		if existed {
+			c.hooks.dispatch([]flagChange{{old: old, new: deletedFlag(old, msg.Revision)}})
+		}
+		logger.Info("feature deleted", zap.String("namespace", msg.Namespace), zap.String("key", msg.Key), zap.Int64("rev", msg.Revision))
 	case constraints.PUT:
-		c.features[msg.Key] = v1.FeatureFlag{
-			Key:      msg.Key,
-			Value:    msg.Value,
-			Type:     msg.Type,
-			Version:  msg.Version,
-			Revision: msg.Revision,
+		c.features[id] = v1.FeatureFlag{
+			Namespace: msg.Namespace,
+			Env:       msg.Env,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Type:      msg.Type,
+			Version:   msg.Version,
+			Revision:  msg.Revision,
 		}
-		logger.Info("feature updated", zap.String("key", msg.Key), zap.String("value", msg.Value), zap.Int64("rev", msg.Revision))
+		flag := c.features[id]
+		c.storeCompiled(msg.Namespace, msg.Key, &flag)
+		c.hooks.dispatch([]flagChange{{old: old, new: c.features[id]}})
+		logger.Info("feature updated", zap.String("namespace", msg.Namespace), zap.String("key", msg.Key), zap.String("value", msg.Value), zap.Int64("rev", msg.Revision))
 	default:
 		logger.Warn("unknown action in feature update", zap.String("action", string(msg.Action)))
 	}
+	if c.mirror != nil {
+		c.mirror.Apply(msg)
+	}
 
 	c.lastRev = msg.Revision
 	c.isDirty = true
+	c.touchSync()
 }
 
 func (c *MizuClient) IsEnabled(key string, context map[string]string) bool {
-	val, ok := c.evaluate(key, context)
-	if !ok {
+	return c.IsEnabledIn(c.defaultNamespace, key, context)
+}
+
+func (c *MizuClient) IsEnabledIn(namespace, key string, context map[string]string) bool {
+	detail := c.evaluate(namespace, key, ContextFromMap(context))
+	if !detail.Found() {
 		return false
 	}
+	val := detail.Value
 	return val == "true" || val == "True" || val == "TRUE"
 }
 
 func (c *MizuClient) GetString(key string, defaultValue string, context map[string]string) string {
-	val, ok := c.evaluate(key, context)
-	if !ok {
-		return defaultValue
-	}
-	return val
+	return c.GetStringIn(c.defaultNamespace, key, defaultValue, context)
 }
 
-func (c *MizuClient) GetNumber(key string, defaultValue float64, context map[string]string) float64 {
-	val, ok := c.evaluate(key, context)
-	if !ok {
-		return defaultValue
-	}
-	f, err := strconv.ParseFloat(val, 64)
-	if err != nil {
+func (c *MizuClient) GetStringIn(namespace, key string, defaultValue string, context map[string]string) string {
+	detail := c.evaluate(namespace, key, ContextFromMap(context))
+	if !detail.Found() {
 		return defaultValue
 	}
-	return f
+	return detail.Value
 }
 
-func (c *MizuClient) GetJSON(key string, target any, context map[string]string) error {
-	val, ok := c.evaluate(key, context)
-	if !ok {
-		return fmt.Errorf("feature not found")
-	}
-	return json.Unmarshal([]byte(val), target)
+func (c *MizuClient) GetNumber(key string, defaultValue float64, context map[string]string) float64 {
+	return c.GetNumberIn(c.defaultNamespace, key, defaultValue, context)
 }
 
-func (c *MizuClient) evaluate(key string, context map[string]string) (string, bool) {
-	c.mu.RLock()
-	feature, ok := c.features[key]
-	c.mu.RUnlock()
-
-	if !ok {
-		logger.Warn("key not found", zap.String("key", key))
-		return "", false
-	}
-
-	if feature.Type != constraints.TypeStrategy {
-		return feature.Value, true
-	}
-	var strategy v1.FeatureStrategy
-	if err := json.Unmarshal([]byte(feature.Value), &strategy); err != nil {
-		return feature.Value, true
-	}
-
-	for _, rule := range strategy.Rules {
-		if c.matchRule(rule, context) {
-			return rule.Result, true
-		}
-	}
-
-	return strategy.DefaultValue, true
+func (c *MizuClient) GetNumberIn(namespace, key string, defaultValue float64, context map[string]string) float64 {
+	return GetIn(c, namespace, key, defaultValue, ContextFromMap(context))
 }
 
-func (c *MizuClient) matchRule(rule v1.Rule, content map[string]string) bool {
-	val, ok := content[rule.Attribute]
-	if !ok {
-		return false
-	}
+func (c *MizuClient) GetJSON(key string, target any, context map[string]string) error {
+	return c.GetJSONIn(c.defaultNamespace, key, target, context)
+}
 
-	switch rule.Operator {
-	case "in":
-		if slices.Contains(rule.Values, val) {
-			return true
-		}
-	case "eq":
-		return val == rule.Values[0]
-	case "mod":
-		// rule.Values[0] is expected to be an integer threshold between 0-100
-		threshold, err := strconv.Atoi(rule.Values[0])
-		if err != nil || threshold == 0 {
-			return false
-		}
-		h := fnv.New32a()
-		h.Write([]byte(val))
-		hashVal := h.Sum32()
-		return int(hashVal%100) < threshold
+func (c *MizuClient) GetJSONIn(namespace, key string, target any, context map[string]string) error {
+	detail := c.evaluate(namespace, key, ContextFromMap(context))
+	if !detail.Found() {
+		return fmt.Errorf("feature not found")
 	}
-
-	return false
+	return json.Unmarshal([]byte(detail.Value), target)
 }
 
+// snapshot is the on-disk cache format. Features is keyed by featureID; the flag itself
+// carries its namespace, so the map is rebuilt from the values when loading.
 type snapshot struct {
 	Features map[string]v1.FeatureFlag `json:"features"`
 	Revision int64                     `json:"revision"`
@@ -382,7 +533,7 @@ func (c *MizuClient) runSnapshotLoop() {
 	}
 }
 
-func (c *MizuClient) saveSnapshot() {
+func (c *MizuClient) saveSnapshot() error {
 	c.mu.RLock()
 	data := snapshot{
 		Features: c.features,
@@ -393,17 +544,19 @@ func (c *MizuClient) saveSnapshot() {
 
 	if err != nil {
 		logger.Error("failed to marshal snapshot", zap.Error(err))
-		return
+		return err
 	}
 
 	tmpFile := c.cacheFile + ".tmp"
 	if err := os.WriteFile(tmpFile, bytes, 0644); err != nil {
 		logger.Error("failed to write temp snapshot file", zap.Error(err))
-		return
+		return err
 	}
 	if err := os.Rename(tmpFile, c.cacheFile); err != nil {
 		logger.Error("failed to rename snapshot file", zap.Error(err))
+		return err
 	}
+	return nil
 }
 
 func (c *MizuClient) loadSnapshot() error {
@@ -417,9 +570,21 @@ func (c *MizuClient) loadSnapshot() error {
 		return err
 	}
 
+	features := make(map[string]v1.FeatureFlag, len(s.Features))
+	for _, f := range s.Features {
+		// caches written before namespaces were tracked only hold flags of the default namespace
+		if f.Namespace == "" {
+			f.Namespace = c.defaultNamespace
+		}
+		features[featureID(f.Namespace, f.Key)] = f
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.features = s.Features
+	c.hooks.dispatch(diffFeatures(c.features, features, s.Revision))
+	c.features = features
+	c.rebuildTable()
+	c.mirrorReset(features, s.Revision)
 	c.lastRev = s.Revision
 	return nil
 }
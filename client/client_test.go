@@ -1,13 +1,25 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"math"
 	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
 	"mizuflow/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func init() {
@@ -15,11 +27,11 @@ func init() {
 }
 
 func TestMatchRule(t *testing.T) {
-	c := &MizuClient{}
 
+	const salt = "test-flag"
 	isModHit := func(val string, threshold int) bool {
 		h := fnv.New32a()
-		h.Write([]byte(val))
+		h.Write([]byte(salt + ":" + val))
 		return int(h.Sum32()%100) < threshold
 	}
 
@@ -71,6 +83,78 @@ func TestMatchRule(t *testing.T) {
 			context:  map[string]string{"userId": "user123"},
 			expected: false,
 		},
+		{
+			name:     "Operator NOT_IN - Match",
+			rule:     v1.Rule{Attribute: "country", Operator: "not_in", Values: []string{"JP", "KR"}},
+			context:  map[string]string{"country": "US"},
+			expected: true,
+		},
+		{
+			name:     "Operator NEQ - No Match",
+			rule:     v1.Rule{Attribute: "region", Operator: "neq", Values: []string{"us-east-1"}},
+			context:  map[string]string{"region": "us-east-1"},
+			expected: false,
+		},
+		{
+			name:     "Operator GTE - Match",
+			rule:     v1.Rule{Attribute: "age", Operator: "gte", Values: []string{"18"}},
+			context:  map[string]string{"age": "18"},
+			expected: true,
+		},
+		{
+			name:     "Operator LT - Non-numeric Attribute",
+			rule:     v1.Rule{Attribute: "age", Operator: "lt", Values: []string{"18"}},
+			context:  map[string]string{"age": "young"},
+			expected: false,
+		},
+		{
+			name:     "Operator SEMVER_GT - Match",
+			rule:     v1.Rule{Attribute: "app_version", Operator: "semver_gt", Values: []string{"3.2.0"}},
+			context:  map[string]string{"app_version": "3.10.1"},
+			expected: true,
+		},
+		{
+			name:     "Operator SEMVER_LT - Prerelease",
+			rule:     v1.Rule{Attribute: "app_version", Operator: "semver_lt", Values: []string{"3.2.0"}},
+			context:  map[string]string{"app_version": "3.2.0-beta.1"},
+			expected: true,
+		},
+		{
+			name:     "Operator SEMVER_RANGE - Upper Bound Exclusive",
+			rule:     v1.Rule{Attribute: "app_version", Operator: "semver_range", Values: []string{"3.0.0", "4.0.0"}},
+			context:  map[string]string{"app_version": "4.0.0"},
+			expected: false,
+		},
+		{
+			name:     "Operator REGEX - Match",
+			rule:     v1.Rule{Attribute: "email", Operator: "regex", Values: []string{`@example\.com$`}},
+			context:  map[string]string{"email": "alice@example.com"},
+			expected: true,
+		},
+		{
+			name:     "Operator CONTAINS - Any Value",
+			rule:     v1.Rule{Attribute: "ua", Operator: "contains", Values: []string{"iPhone", "Android"}},
+			context:  map[string]string{"ua": "Mozilla/5.0 (Linux; Android 14)"},
+			expected: true,
+		},
+		{
+			name:     "Operator STARTS_WITH - Match",
+			rule:     v1.Rule{Attribute: "tenant", Operator: "starts_with", Values: []string{"corp-"}},
+			context:  map[string]string{"tenant": "corp-42"},
+			expected: true,
+		},
+		{
+			name:     "Operator ENDS_WITH - No Match",
+			rule:     v1.Rule{Attribute: "host", Operator: "ends_with", Values: []string{".internal"}},
+			context:  map[string]string{"host": "api.example.com"},
+			expected: false,
+		},
+		{
+			name:     "Operator EQ - Empty Values",
+			rule:     v1.Rule{Attribute: "region", Operator: "eq"},
+			context:  map[string]string{"region": "us-east-1"},
+			expected: false,
+		},
 		{
 			name:     "Operator UNKNOWN - Should fail safely",
 			rule:     v1.Rule{Attribute: "role", Operator: "unknown", Values: []string{"something"}},
@@ -81,16 +165,15 @@ func TestMatchRule(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := c.matchRule(tt.rule, tt.context)
+			result := compileRule(tt.rule, salt).matches(ContextFromMap(tt.context))
 			if result != tt.expected {
-				t.Errorf("matchRule() = %v, want %v", result, tt.expected)
+				t.Errorf("matches() = %v, want %v", result, tt.expected)
 			}
 		})
 	}
 }
 
 func TestModDistribution(t *testing.T) {
-	c := &MizuClient{}
 	sampleSize := 10000
 	thresholds := []int{10, 30, 50, 80}
 
@@ -105,7 +188,7 @@ func TestModDistribution(t *testing.T) {
 
 			for i := 0; i < sampleSize; i++ {
 				ctx := map[string]string{"userId": fmt.Sprintf("user-%d", i)}
-				if c.matchRule(rule, ctx) {
+				if compileRule(rule, "test-flag").matches(ContextFromMap(ctx)) {
 					matches++
 				}
 			}
@@ -119,3 +202,951 @@ func TestModDistribution(t *testing.T) {
 		})
 	}
 }
+
+func TestNamespaceIsolation(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"checkout", "payments"})
+
+	c.handleUpdate(v1.Message{Namespace: "checkout", Env: "dev", Key: "new-ui", Value: "true", Type: "bool", Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "payments", Env: "dev", Key: "new-ui", Value: "false", Type: "bool", Revision: 2, Action: constraints.PUT})
+
+	if !c.IsEnabledIn("checkout", "new-ui", nil) {
+		t.Error("checkout/new-ui should be enabled")
+	}
+	if c.IsEnabledIn("payments", "new-ui", nil) {
+		t.Error("payments/new-ui should be disabled")
+	}
+	// first subscribed namespace is the default
+	if !c.IsEnabled("new-ui", nil) {
+		t.Error("default namespace lookup should resolve to checkout")
+	}
+
+	c.handleUpdate(v1.Message{Namespace: "payments", Env: "dev", Key: "new-ui", Revision: 3, Action: constraints.DELETE})
+	if !c.IsEnabledIn("checkout", "new-ui", nil) {
+		t.Error("deleting payments/new-ui must not affect checkout/new-ui")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+	c := NewMizuClient("", "dev", "", []string{"checkout", "payments"}, WithCacheFile(cacheFile), WithDefaultNamespace("payments"))
+	c.handleUpdate(v1.Message{Namespace: "checkout", Env: "dev", Key: "limit", Value: "10", Type: "number", Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "payments", Env: "dev", Key: "limit", Value: "20", Type: "number", Revision: 2, Action: constraints.PUT})
+	c.saveSnapshot()
+
+	restored := NewMizuClient("", "dev", "", []string{"checkout", "payments"}, WithCacheFile(cacheFile), WithDefaultNamespace("payments"))
+	if err := restored.loadSnapshot(); err != nil {
+		t.Fatalf("loadSnapshot() error = %v", err)
+	}
+	if got := restored.GetNumberIn("checkout", "limit", 0, nil); got != 10 {
+		t.Errorf("checkout/limit = %v, want 10", got)
+	}
+	if got := restored.GetNumber("limit", 0, nil); got != 20 {
+		t.Errorf("limit in default namespace = %v, want 20", got)
+	}
+	if restored.lastRev != 2 {
+		t.Errorf("lastRev = %d, want 2", restored.lastRev)
+	}
+}
+
+func TestLoadLegacySnapshot(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+	legacy := `{"features":{"new-ui":{"key":"new-ui","value":"true","type":"bool","version":1,"revision":5}},"revision":5}`
+	if err := os.WriteFile(cacheFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewMizuClient("", "dev", "", []string{"checkout"}, WithCacheFile(cacheFile))
+	if err := c.loadSnapshot(); err != nil {
+		t.Fatalf("loadSnapshot() error = %v", err)
+	}
+	if !c.IsEnabledIn("checkout", "new-ui", nil) {
+		t.Error("legacy entries should be assigned to the default namespace")
+	}
+}
+
+func TestCompoundConditions(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+	strategy := `{
+		"default_value": "off",
+		"rules": [
+			{"attribute": "role", "operator": "eq", "value": ["admin"], "result": "admin"},
+			{
+				"conditions": [
+					{"attribute": "country", "operator": "in", "value": ["JP"]},
+					{"attribute": "app_version", "operator": "semver_gt", "value": ["3.2.0"]}
+				],
+				"result": "jp-new"
+			},
+			{
+				"combinator": "or",
+				"negate": true,
+				"conditions": [
+					{"attribute": "plan", "operator": "eq", "value": ["free"]},
+					{"combinator": "and", "conditions": [
+						{"attribute": "plan", "operator": "eq", "value": ["trial"]},
+						{"attribute": "days", "operator": "lt", "value": ["7"]}
+					]}
+				],
+				"result": "paid"
+			}
+		]
+	}`
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "banner", Value: strategy, Type: constraints.TypeStrategy, Revision: 1, Action: constraints.PUT})
+
+	tests := []struct {
+		name    string
+		context map[string]string
+		want    string
+	}{
+		{"legacy single-condition rule", map[string]string{"role": "admin"}, "admin"},
+		{"AND group matches", map[string]string{"country": "JP", "app_version": "3.3.0", "plan": "free"}, "jp-new"},
+		{"AND group partial match", map[string]string{"country": "JP", "app_version": "3.1.0", "plan": "free"}, "off"},
+		{"negated OR group", map[string]string{"plan": "pro"}, "paid"},
+		{"nested AND inside negated OR", map[string]string{"plan": "trial", "days": "3"}, "off"},
+		{"nested AND not satisfied", map[string]string{"plan": "trial", "days": "10"}, "paid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.GetString("banner", "", tt.context); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModSaltedPerFlag(t *testing.T) {
+	rule := v1.Rule{Attribute: "userId", Operator: "mod", Values: []string{"10"}}
+
+	sampleSize := 10000
+	both := 0
+	for i := 0; i < sampleSize; i++ {
+		ctx := map[string]string{"userId": fmt.Sprintf("user-%d", i)}
+		if compileRule(rule, "flag-a").matches(ContextFromMap(ctx)) && compileRule(rule, "flag-b").matches(ContextFromMap(ctx)) {
+			both++
+		}
+	}
+
+	// independent 10% samples overlap on ~1% of users, unsalted ones on 10%
+	overlap := float64(both) / float64(sampleSize) * 100
+	if overlap > 3 {
+		t.Errorf("rollouts of different flags overlap on %.2f%% of users, want ~1%%", overlap)
+	}
+}
+
+func TestRolloutVariants(t *testing.T) {
+	rollout := &v1.Rollout{
+		BucketBy: "userId",
+		Variants: []v1.Variant{{Value: "A", Weight: 50}, {Value: "B", Weight: 30}, {Value: "C", Weight: 20}},
+	}
+	grown := &v1.Rollout{
+		BucketBy: "userId",
+		Variants: []v1.Variant{{Value: "A", Weight: 40}, {Value: "B", Weight: 30}, {Value: "C", Weight: 30}},
+	}
+
+	sampleSize := 10000
+	counts := map[string]int{}
+	for i := 0; i < sampleSize; i++ {
+		ctx := map[string]string{"userId": fmt.Sprintf("user-%d", i)}
+		before, ok := compileRollout(rollout, "checkout").pick(ContextFromMap(ctx))
+		if !ok {
+			t.Fatal("pick() found no variant")
+		}
+		counts[before]++
+
+		after, _ := compileRollout(grown, "checkout").pick(ContextFromMap(ctx))
+		if before == "C" && after != "C" {
+			t.Fatalf("user-%d left growing variant C for %s", i, after)
+		}
+		// B kept its weight, so its users may only be pulled into the growing C
+		if before == "B" && after == "A" {
+			t.Fatalf("user-%d moved from B to shrinking variant A", i)
+		}
+	}
+
+	for _, v := range rollout.Variants {
+		percentage := float64(counts[v.Value]) / float64(sampleSize) * 100
+		if math.Abs(percentage-float64(v.Weight)) > 2.5 {
+			t.Errorf("variant %s got %.2f%%, want ~%d%%", v.Value, percentage, v.Weight)
+		}
+	}
+
+	if _, ok := compileRollout(rollout, "checkout").pick(ContextFromMap(map[string]string{"other": "x"})); ok {
+		t.Error("pick() should not match without the bucketing attribute")
+	}
+}
+
+func TestRolloutRuleEvaluation(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+	strategy := `{
+		"default_value": "control",
+		"rules": [
+			{"attribute": "role", "operator": "eq", "value": ["qa"], "result": "treatment"},
+			{"rollout": {"bucket_by": "userId", "variants": [{"value": "treatment", "weight": 100}, {"value": "control", "weight": 0}]}}
+		]
+	}`
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "exp", Value: strategy, Type: constraints.TypeStrategy, Revision: 1, Action: constraints.PUT})
+
+	if got := c.GetString("exp", "", map[string]string{"userId": "u1"}); got != "treatment" {
+		t.Errorf("GetString() = %q, want treatment", got)
+	}
+	if got := c.GetString("exp", "", map[string]string{"country": "JP"}); got != "control" {
+		t.Errorf("GetString() without bucketing attribute = %q, want default", got)
+	}
+}
+
+func TestEvaluateDetail(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+	strategy := `{"default_value":"off","rules":[{"attribute":"role","operator":"eq","value":["qa"],"result":"x"},{"id":"beta","attribute":"role","operator":"eq","value":["beta"],"result":"on"}]}`
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "strategy", Value: strategy, Type: constraints.TypeStrategy, Version: 3, Revision: 10, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "broken", Value: "{", Type: constraints.TypeStrategy, Version: 1, Revision: 11, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "limit", Value: "ten", Type: constraints.TypeNumber, Version: 1, Revision: 12, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "name", Value: "mizu", Type: constraints.TypeString, Version: 2, Revision: 13, Action: constraints.PUT})
+
+	tests := []struct {
+		name      string
+		key       string
+		context   map[string]string
+		value     string
+		reason    Reason
+		ruleIndex int
+		ruleID    string
+	}{
+		{"matched rule", "strategy", map[string]string{"role": "beta"}, "on", ReasonTargetMatch, 1, "beta"},
+		{"strategy default", "strategy", map[string]string{"role": "guest"}, "off", ReasonDefault, -1, ""},
+		{"static value", "name", nil, "mizu", ReasonDefault, -1, ""},
+		{"missing flag", "nope", nil, "", ReasonFlagNotFound, -1, ""},
+		{"unparsable strategy", "broken", nil, "{", ReasonParseError, -1, ""},
+		{"value not matching type", "limit", nil, "ten", ReasonTypeMismatch, -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := c.EvaluateDetail(tt.key, ContextFromMap(tt.context))
+			if d.Value != tt.value || d.Reason != tt.reason || d.RuleIndex != tt.ruleIndex || d.RuleID != tt.ruleID {
+				t.Errorf("EvaluateDetail() = %+v, want value=%q reason=%s rule=%d/%q", d, tt.value, tt.reason, tt.ruleIndex, tt.ruleID)
+			}
+		})
+	}
+
+	d := c.EvaluateDetail("strategy", EvalContext{})
+	if d.Version != 3 || d.Revision != 10 {
+		t.Errorf("EvaluateDetail() version/revision = %d/%d, want 3/10", d.Version, d.Revision)
+	}
+}
+
+func TestTypedContext(t *testing.T) {
+	signup := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	ctx := NewEvalContext("user-1").
+		With("age", 30).
+		With("premium", true).
+		With("groups", []string{"staff", "beta"}).
+		With("signup_at", signup)
+
+	tests := []struct {
+		name     string
+		cond     v1.Condition
+		expected bool
+	}{
+		{"int gte", v1.Condition{Attribute: "age", Operator: "gte", Values: []string{"18"}}, true},
+		{"int lt", v1.Condition{Attribute: "age", Operator: "lt", Values: []string{"18"}}, false},
+		{"bool eq", v1.Condition{Attribute: "premium", Operator: "eq", Values: []string{"true"}}, true},
+		{"list in", v1.Condition{Attribute: "groups", Operator: "in", Values: []string{"beta"}}, true},
+		{"list not_in", v1.Condition{Attribute: "groups", Operator: "not_in", Values: []string{"staff"}}, false},
+		{"list not_in miss", v1.Condition{Attribute: "groups", Operator: "not_in", Values: []string{"ops"}}, true},
+		{"list neq", v1.Condition{Attribute: "groups", Operator: "neq", Values: []string{"beta"}}, false},
+		{"time before", v1.Condition{Attribute: "signup_at", Operator: "lt", Values: []string{"2026-01-01T00:00:00Z"}}, true},
+		{"time after", v1.Condition{Attribute: "signup_at", Operator: "gt", Values: []string{"2026-01-01T00:00:00Z"}}, false},
+		{"time unix seconds", v1.Condition{Attribute: "signup_at", Operator: "gte", Values: []string{strconv.FormatInt(signup.Unix(), 10)}}, true},
+		{"targeting key", v1.Condition{Attribute: TargetingKeyAttribute, Operator: "eq", Values: []string{"user-1"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compileCondition(tt.cond, "salt")(ctx); got != tt.expected {
+				t.Errorf("compileCondition() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	rollout := &v1.Rollout{Variants: []v1.Variant{{Value: "on", Weight: 100}}}
+	if _, ok := compileRollout(rollout, "salt").pick(ctx); !ok {
+		t.Error("pick() should bucket by the targeting key when bucket_by is empty")
+	}
+	if _, ok := compileRollout(rollout, "salt").pick(EvalContext{}); ok {
+		t.Error("pick() should not match without a targeting key")
+	}
+}
+
+func TestGetTyped(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "limit", Value: "42", Type: constraints.TypeNumber, Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "timeout", Value: "1500ms", Type: constraints.TypeString, Revision: 2, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "theme", Value: `{"color":"red","size":3}`, Type: constraints.TypeJSON, Revision: 3, Action: constraints.PUT})
+
+	type theme struct {
+		Color string `json:"color"`
+		Size  int    `json:"size"`
+	}
+	ctx := NewEvalContext("user-1")
+
+	if got := Get(c, "limit", 0, ctx); got != 42 {
+		t.Errorf("Get[int]() = %d, want 42", got)
+	}
+	if got := Get(c, "limit", 0.0, ctx); got != 42 {
+		t.Errorf("Get[float64]() = %v, want 42", got)
+	}
+	if got := Get(c, "timeout", time.Second, ctx); got != 1500*time.Millisecond {
+		t.Errorf("Get[time.Duration]() = %v, want 1.5s", got)
+	}
+	if got := Get(c, "theme", theme{}, ctx); got != (theme{Color: "red", Size: 3}) {
+		t.Errorf("Get[theme]() = %+v", got)
+	}
+	if got := Get(c, "missing", "fallback", ctx); got != "fallback" {
+		t.Errorf("Get() on a missing flag = %q, want fallback", got)
+	}
+
+	got, detail := GetDetail(c, "theme", 7, ctx)
+	if got != 7 || detail.Reason != ReasonTypeMismatch {
+		t.Errorf("GetDetail[int]() on JSON = %d/%s, want default and TYPE_MISMATCH", got, detail.Reason)
+	}
+	if _, detail := GetDetail(c, "theme", 7, ctx); detail.Reason != ReasonTypeMismatch {
+		t.Errorf("cached decode failure reason = %s, want TYPE_MISMATCH", detail.Reason)
+	}
+
+	// a new revision must not serve the value decoded for the previous one
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "theme", Value: `{"color":"blue","size":1}`, Type: constraints.TypeJSON, Revision: 4, Action: constraints.PUT})
+	if got := Get(c, "theme", theme{}, ctx); got.Color != "blue" {
+		t.Errorf("Get[theme]() after update = %+v, want blue", got)
+	}
+	if got := c.GetNumber("limit", 0, nil); got != 42 {
+		t.Errorf("GetNumber() = %v, want 42", got)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+
+	changes := make(chan [2]v1.FeatureFlag, 16)
+	unsubscribe := c.OnChange("limit", func(old, new v1.FeatureFlag) {
+		changes <- [2]v1.FeatureFlag{old, new}
+	})
+	var anyCount atomic.Int32
+	c.OnAnyChange(func(old, new v1.FeatureFlag) {
+		anyCount.Add(1)
+	})
+
+	for i := 1; i <= 5; i++ {
+		c.handleUpdate(v1.Message{Namespace: "default", Key: "limit", Value: strconv.Itoa(i), Type: "number", Version: i, Revision: int64(i), Action: constraints.PUT})
+	}
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "other", Value: "x", Type: "string", Revision: 6, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "limit", Revision: 7, Action: constraints.DELETE})
+
+	for i := 1; i <= 5; i++ {
+		ch := receive(t, changes)
+		if ch[1].Value != strconv.Itoa(i) || ch[0].Version != i-1 {
+			t.Fatalf("change %d = %+v -> %+v, want in-order updates", i, ch[0], ch[1])
+		}
+	}
+	deleted := receive(t, changes)
+	if deleted[0].Value != "5" || deleted[1].Type != "" || deleted[1].Revision != 7 {
+		t.Errorf("delete change = %+v -> %+v", deleted[0], deleted[1])
+	}
+
+	unsubscribe()
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "limit", Value: "9", Type: "number", Revision: 8, Action: constraints.PUT})
+	select {
+	case ch := <-changes:
+		t.Errorf("unsubscribed listener received %+v", ch)
+	case <-time.After(50 * time.Millisecond):
+	}
+	deadline := time.Now().Add(time.Second)
+	for anyCount.Load() < 8 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := anyCount.Load(); got != 8 {
+		t.Errorf("OnAnyChange received %d changes, want 8", got)
+	}
+}
+
+func TestOnChangeAfterResync(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"namespace":"default","env":"dev","key":"kept","value":"2","type":"number","version":2,"revision":20}],"revision":21}`))
+	}))
+	defer srv.Close()
+
+	c := NewMizuClient(srv.URL, "dev", "", []string{"default"})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "kept", Value: "1", Type: "number", Version: 1, Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "gone", Value: "x", Type: "string", Version: 1, Revision: 2, Action: constraints.PUT})
+
+	changes := make(chan [2]v1.FeatureFlag, 16)
+	c.OnAnyChange(func(old, new v1.FeatureFlag) {
+		changes <- [2]v1.FeatureFlag{old, new}
+	})
+	if err := c.fetchAll(); err != nil {
+		t.Fatalf("fetchAll() error = %v", err)
+	}
+
+	got := map[string][2]v1.FeatureFlag{}
+	for i := 0; i < 2; i++ {
+		ch := receive(t, changes)
+		got[ch[0].Key] = ch
+	}
+	if ch := got["kept"]; ch[0].Value != "1" || ch[1].Value != "2" {
+		t.Errorf("kept change = %+v -> %+v", ch[0], ch[1])
+	}
+	if ch := got["gone"]; ch[1].Type != "" || ch[1].Revision != 21 {
+		t.Errorf("gone change = %+v -> %+v, want deletion at revision 21", ch[0], ch[1])
+	}
+	if c.GetString("gone", "missing", nil) != "missing" {
+		t.Error("flag deleted upstream should be dropped by the resync")
+	}
+}
+
+func receive(t *testing.T, ch <-chan [2]v1.FeatureFlag) [2]v1.FeatureFlag {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for change notification")
+	}
+	return [2]v1.FeatureFlag{}
+}
+
+func TestExposureReporting(t *testing.T) {
+	reports := make(chan v1.ExposureReport, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/stream/exposures" || r.URL.Query().Get("env") != "dev" || r.URL.Query().Get("namespace") != "default" || r.Header.Get("X-Mizu-Key") != "key" {
+			t.Errorf("unexpected report request %s %s", r.Method, r.URL)
+		}
+		var report v1.ExposureReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		reports <- report
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c := NewMizuClient(srv.URL, "dev", "key", []string{"default"}, WithExposureReporting(200*time.Millisecond, 2))
+	defer c.cancel()
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "a", Value: "true", Type: "bool", Revision: 1, Action: constraints.PUT})
+	go c.runExposureLoop()
+
+	for i := 0; i < 3; i++ {
+		c.IsEnabled("a", nil)
+	}
+	c.IsEnabled("missing", nil)
+	c.IsEnabled("another-missing", nil) // exceeds maxEntries and is dropped
+
+	var report v1.ExposureReport
+	select {
+	case report = <-reports:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no exposure report received")
+	}
+
+	counts := map[string]int64{}
+	for _, e := range report.Entries {
+		counts[e.Key+"/"+e.Variant+"/"+e.Reason] = e.Count
+	}
+	if counts["a/true/DEFAULT"] != 3 || counts["missing//FLAG_NOT_FOUND"] != 1 || len(counts) != 2 {
+		t.Errorf("report entries = %v", counts)
+	}
+	if report.Dropped != 1 {
+		t.Errorf("report dropped = %d, want 1", report.Dropped)
+	}
+}
+
+func TestExposureVariantTruncation(t *testing.T) {
+	r := &exposureRecorder{events: make(chan exposureKey, 1)}
+	r.record(EvaluationDetail{Key: "greeting", Value: strings.Repeat("あ", 100), Reason: ReasonDefault})
+	ev := <-r.events
+	if !utf8.ValidString(ev.variant) || ev.variant != strings.Repeat("あ", maxVariantLength) {
+		t.Errorf("variant = %q, want the first %d characters", ev.variant, maxVariantLength)
+	}
+	// longer than the limit in bytes only
+	full := strings.Repeat("あ", maxVariantLength)
+	r.record(EvaluationDetail{Key: "greeting", Value: full, Reason: ReasonDefault})
+	if ev := <-r.events; ev.variant != full {
+		t.Errorf("variant = %q, want %q kept whole", ev.variant, full)
+	}
+
+	short := EvaluationDetail{Key: "greeting", Value: "hello", Reason: ReasonDefault}
+	if allocs := testing.AllocsPerRun(100, func() { r.record(short); <-r.events }); allocs != 0 {
+		t.Errorf("record() of a short value allocates %v times, want none", allocs)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/stream/snapshot":
+			w.Write([]byte(`{"data":[{"namespace":"default","env":"dev","key":"limit","value":"5","type":"number","version":1,"revision":3}],"revision":3}`))
+		case "/v1/stream/watch":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "cache.json")
+	c := NewMizuClient(srv.URL, "dev", "", []string{"default"}, WithCacheFile(cacheFile))
+	if c.State() != StateInitializing || !c.LastSync().IsZero() {
+		t.Fatalf("new client state = %s, last sync %v", c.State(), c.LastSync())
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.WaitForReady(ctx); err != nil {
+		t.Fatalf("WaitForReady() error = %v", err)
+	}
+	if c.State() != StateLive || c.LastSync().IsZero() {
+		t.Errorf("state after sync = %s, last sync %v", c.State(), c.LastSync())
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if c.State() != StateDisconnected {
+		t.Errorf("state after Close = %s, want disconnected", c.State())
+	}
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Errorf("Close() should write the cache file: %v", err)
+	}
+
+	// the server is gone, the next client serves the cache file
+	srv.Close()
+	stale := NewMizuClient(srv.URL, "dev", "", []string{"default"}, WithCacheFile(cacheFile))
+	if err := stale.Start(); err != nil {
+		t.Fatalf("Start() from cache error = %v", err)
+	}
+	defer stale.Close()
+	if stale.State() != StateStaleCache {
+		t.Errorf("state from cache = %s, want stale_cache", stale.State())
+	}
+	if got := stale.GetNumber("limit", 0, nil); got != 5 {
+		t.Errorf("GetNumber() from cache = %v, want 5", got)
+	}
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if err := stale.WaitForReady(short); err != context.DeadlineExceeded {
+		t.Errorf("WaitForReady() from cache = %v, want deadline exceeded", err)
+	}
+}
+
+// snapshotServer serves the snapshot endpoint with ETags and since_rev the way the server does.
+type snapshotServer struct {
+	mu          sync.Mutex
+	flags       map[string]v1.FeatureFlag
+	changes     []v1.Message
+	rev         int64
+	notModified int
+	incremental int
+	watchCalls  int
+}
+
+func (s *snapshotServer) put(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rev++
+	f := v1.FeatureFlag{Namespace: "default", Env: "dev", Key: key, Value: value, Type: constraints.TypeString, Revision: s.rev}
+	s.flags[key] = f
+	s.changes = append(s.changes, v1.Message{Namespace: "default", Env: "dev", Key: key, Value: value, Type: constraints.TypeString, Revision: s.rev, Action: constraints.PUT})
+}
+
+func (s *snapshotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path == "/v1/stream/watch" {
+		s.watchCalls++
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	etag := fmt.Sprintf(`"%d"`, s.rev)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if since, err := strconv.ParseInt(r.URL.Query().Get("since_rev"), 10, 64); err == nil && since > 0 {
+		s.incremental++
+		var changes []v1.Message
+		for _, m := range s.changes {
+			if m.Revision > since {
+				changes = append(changes, m)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"changes": changes, "incremental": true, "revision": s.rev})
+		return
+	}
+	data := make([]v1.FeatureFlag, 0, len(s.flags))
+	for _, f := range s.flags {
+		data = append(data, f)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"data": data, "revision": s.rev})
+}
+
+func (s *snapshotServer) counts() (notModified, incremental, watchCalls int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified, s.incremental, s.watchCalls
+}
+
+func TestPollingTransport(t *testing.T) {
+	backend := &snapshotServer{flags: map[string]v1.FeatureFlag{}}
+	backend.put("color", "red")
+	srv := httptest.NewServer(backend)
+	defer srv.Close()
+
+	c := NewMizuClient(srv.URL, "dev", "", []string{"default"},
+		WithCacheFile(filepath.Join(t.TempDir(), "cache.json")),
+		WithTransport(TransportPolling),
+		WithPolling(20*time.Millisecond, 0))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+
+	waitFor(t, func() bool { n, _, _ := backend.counts(); return n >= 2 })
+	backend.put("color", "blue")
+	waitFor(t, func() bool { return c.GetString("color", "", nil) == "blue" })
+
+	_, incremental, watchCalls := backend.counts()
+	if incremental == 0 {
+		t.Error("polls should ask for the changes since the last revision")
+	}
+	if watchCalls != 0 {
+		t.Errorf("polling transport opened %d SSE connections", watchCalls)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	c := NewMizuClient("http://localhost", "dev", "", []string{"default"})
+	if got := c.pollTimeout(); got != 11*time.Second {
+		t.Errorf("pollTimeout() = %v, want the client timeout plus a second", got)
+	}
+	// a zero Timeout is no timeout, not an immediate deadline
+	c = NewMizuClient("http://localhost", "dev", "", []string{"default"}, WithHTTPClient(&http.Client{}))
+	if got := c.pollTimeout(); got != defaultPollTimeout {
+		t.Errorf("pollTimeout() without a client timeout = %v, want %v", got, defaultPollTimeout)
+	}
+}
+
+func TestAutoTransportFallsBackToPolling(t *testing.T) {
+	backend := &snapshotServer{flags: map[string]v1.FeatureFlag{}}
+	backend.put("color", "red")
+	srv := httptest.NewServer(backend)
+	defer srv.Close()
+
+	c := NewMizuClient(srv.URL, "dev", "", []string{"default"},
+		WithCacheFile(filepath.Join(t.TempDir(), "cache.json")),
+		WithPolling(20*time.Millisecond, time.Hour))
+	c.watchBackoff = 5 * time.Millisecond
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+
+	backend.put("color", "green")
+	waitFor(t, func() bool { return c.GetString("color", "", nil) == "green" })
+	if _, _, watchCalls := backend.counts(); watchCalls != sseFailureThreshold {
+		t.Errorf("SSE attempts before falling back = %d, want %d", watchCalls, sseFailureThreshold)
+	}
+	if c.State() != StateLive {
+		t.Errorf("state while polling = %s, want live", c.State())
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEndpointSet(t *testing.T) {
+	s := newEndpointSet([]string{"a", "b", "c", "a", ""})
+	if s.size() != 3 || s.get() != "a" {
+		t.Fatalf("endpoints = %d, current %q", s.size(), s.get())
+	}
+	if !s.failure("a") || s.get() != "b" {
+		t.Errorf("after a fails current = %q, want b", s.get())
+	}
+	if !s.failure("b") || s.get() != "c" {
+		t.Errorf("after b fails current = %q, want c", s.get())
+	}
+	// every endpoint failed once: rotate, but report there is nothing healthy left
+	if s.failure("c") || s.get() != "a" {
+		t.Errorf("after c fails current = %q, want a", s.get())
+	}
+	s.failure("a")
+	if s.get() != "b" {
+		t.Errorf("current = %q, want b with the fewest recent failures", s.get())
+	}
+	s.success("b")
+	s.endpoints[2].lastFailure = time.Now().Add(-2 * failureMemory)
+	if !s.failure("b") || s.get() != "c" {
+		t.Errorf("current = %q, want c whose failure is forgotten", s.get())
+	}
+}
+
+func TestFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	lastRev := make(chan string, 1)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/stream/snapshot":
+			w.Write([]byte(`{"data":[{"namespace":"default","env":"dev","key":"limit","value":"5","type":"number","version":1,"revision":8}],"revision":8}`))
+		case "/v1/stream/watch":
+			select {
+			case lastRev <- r.URL.Query().Get("last_rev"):
+			default:
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: ping\ndata: pong\n\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer up.Close()
+
+	c := NewMizuClient(down.URL, "dev", "", []string{"default"},
+		WithCacheFile(filepath.Join(t.TempDir(), "cache.json")),
+		WithEndpoints(up.URL))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+
+	if got := c.CurrentEndpoint(); got != up.URL {
+		t.Errorf("CurrentEndpoint() = %q, want %q", got, up.URL)
+	}
+	select {
+	case rev := <-lastRev:
+		if rev != "8" {
+			t.Errorf("watch resumed from last_rev=%s, want 8", rev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client never watched the healthy endpoint")
+	}
+	if got := c.GetNumber("limit", 0, nil); got != 5 {
+		t.Errorf("GetNumber() = %v, want 5", got)
+	}
+}
+
+func TestCompiledTableSwap(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+	strategy := `{"default_value":"false","rules":[{"attribute":"userId","operator":"mod","value":["50"],"result":"true"}]}`
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "half", Value: strategy, Type: constraints.TypeStrategy, Version: 1, Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "other", Value: "true", Type: constraints.TypeBool, Version: 1, Revision: 2, Action: constraints.PUT})
+
+	before, _ := c.lookupCompiled("default", "half")
+	c.replaceFeatures([]v1.FeatureFlag{
+		{Namespace: "default", Key: "half", Value: strategy, Type: constraints.TypeStrategy, Version: 1, Revision: 1},
+		{Namespace: "default", Key: "other", Value: "false", Type: constraints.TypeBool, Version: 2, Revision: 3},
+	}, 3, "")
+	after, _ := c.lookupCompiled("default", "half")
+	if before != after {
+		t.Error("an unchanged flag should keep its compiled form across a resync")
+	}
+	if c.IsEnabled("other", nil) {
+		t.Error("other should be disabled after the resync")
+	}
+
+	// the precomputed hash prefixes must bucket exactly like hash/fnv
+	for i := range 200 {
+		user := fmt.Sprintf("user-%d", i)
+		want := bucket("half", user) < 50
+		if got := c.IsEnabled("half", map[string]string{"userId": user}); got != want {
+			t.Fatalf("IsEnabled(%s) = %v, want %v", user, got, want)
+		}
+	}
+
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "half", Revision: 4, Action: constraints.DELETE})
+	if _, ok := c.lookupCompiled("default", "half"); ok {
+		t.Error("a deleted flag should leave the compiled table")
+	}
+}
+
+func TestEvaluateAll(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default", "payments"})
+	strategy := `{"default_value":"off","rules":[{"attribute":"country","operator":"eq","value":["JP"],"result":"on"}]}`
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "b", Value: strategy, Type: constraints.TypeStrategy, Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "a", Value: "true", Type: constraints.TypeBool, Revision: 2, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "payments", Key: "c", Value: "1", Type: constraints.TypeNumber, Revision: 3, Action: constraints.PUT})
+
+	details := c.EvaluateAll(ContextFromMap(map[string]string{"country": "JP"}))
+	if len(details) != 2 || details[0].Key != "a" || details[1].Key != "b" {
+		t.Fatalf("EvaluateAll() = %+v, want a and b in order", details)
+	}
+	if details[1].Value != "on" || details[1].Reason != ReasonTargetMatch {
+		t.Errorf("EvaluateAll() b = %+v, want on by TARGET_MATCH", details[1])
+	}
+	if details := c.EvaluateAllIn("missing", EvalContext{}); len(details) != 0 {
+		t.Errorf("EvaluateAllIn(missing) = %+v, want nothing", details)
+	}
+}
+
+const benchStrategy = `{
+	"default_value": "false",
+	"rules": [
+		{"attribute": "country", "operator": "in", "value": ["JP", "KR", "TW", "SG", "HK"], "result": "true"},
+		{"attribute": "email", "operator": "regex", "value": ["@example\\.com$"], "result": "true"},
+		{"attribute": "appVersion", "operator": "semver_range", "value": ["2.0.0", "3.0.0"], "result": "true"},
+		{"attribute": "userId", "operator": "mod", "value": ["30"], "result": "true"}
+	]
+}`
+
+func BenchmarkIsEnabled(b *testing.B) {
+	c := NewMizuClient("", "dev", "", []string{"default"})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "plain", Value: "true", Type: constraints.TypeBool, Revision: 1, Action: constraints.PUT})
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "strategy", Value: benchStrategy, Type: constraints.TypeStrategy, Revision: 2, Action: constraints.PUT})
+	ctx := map[string]string{"userId": "user-42", "country": "US", "email": "a@b.org", "appVersion": "1.9.0"}
+
+	b.Run("bool", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			c.IsEnabled("plain", ctx)
+		}
+	})
+	b.Run("strategy", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			c.IsEnabled("strategy", ctx)
+		}
+	})
+	b.Run("strategy/parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.IsEnabled("strategy", ctx)
+			}
+		})
+	})
+}
+
+// BenchmarkEvaluateStrategy compares the compiled tree against compiling on
+// every call, which is what evaluation cost before strategies were compiled.
+func BenchmarkEvaluateStrategy(b *testing.B) {
+	flag := v1.FeatureFlag{Namespace: "default", Key: "strategy", Value: benchStrategy, Type: constraints.TypeStrategy, Revision: 1}
+	ctx := ContextFromMap(map[string]string{"userId": "user-42", "country": "US", "email": "a@b.org", "appVersion": "1.9.0"})
+
+	b.Run("compiled", func(b *testing.B) {
+		cf := compileFlag(flag)
+		b.ReportAllocs()
+		for b.Loop() {
+			cf.evaluate(ctx, FlagLookup(nil), 0)
+		}
+	})
+	b.Run("per_call", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			EvaluateFlag(flag, ctx)
+		}
+	})
+}
+
+func TestLocalOverrides(t *testing.T) {
+	t.Setenv(OverrideEnvPrefix+"NEW_CHECKOUT", "true")
+	path := filepath.Join(t.TempDir(), "overrides.json")
+	if err := os.WriteFile(path, []byte(`{"color": "green", "checkout/limit": 5}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &snapshotServer{flags: map[string]v1.FeatureFlag{}}
+	backend.put("color", "red")
+	backend.put("new-checkout", "false")
+	srv := httptest.NewServer(backend)
+	defer srv.Close()
+
+	c := NewMizuClient(srv.URL, "dev", "", []string{"default"},
+		WithCacheFile(filepath.Join(t.TempDir(), "cache.json")),
+		WithTransport(TransportPolling),
+		WithLocalOverrides(path))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+
+	if !c.IsEnabled("new-checkout", nil) {
+		t.Error("the environment override should win over the server value")
+	}
+	if d := c.EvaluateDetail("color", EvalContext{}); d.Value != "green" || d.Reason != ReasonOverride {
+		t.Errorf("EvaluateDetail(color) = %q/%s, want green/OVERRIDE", d.Value, d.Reason)
+	}
+	if got := GetIn(c, "checkout", "limit", 0, EvalContext{}); got != 5 {
+		t.Errorf("GetIn(checkout/limit) = %d, want 5", got)
+	}
+	if d := c.EvaluateDetailIn("payments", "limit", EvalContext{}); d.Found() {
+		t.Error("a namespaced override should not apply to other namespaces")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"color": "purple"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return c.GetString("color", "", nil) == "purple" })
+
+	os.Remove(path)
+	waitFor(t, func() bool { return c.GetString("color", "", nil) == "red" })
+}
+
+func TestLocalOverridesRefusedInProd(t *testing.T) {
+	t.Setenv(OverrideEnvPrefix+"NEW_CHECKOUT", "true")
+	c := NewMizuClient("", "prod", "", []string{"default"}, WithLocalOverrides(""))
+	c.handleUpdate(v1.Message{Namespace: "default", Key: "new-checkout", Value: "false", Type: constraints.TypeBool, Revision: 1, Action: constraints.PUT})
+	c.startOverrides()
+
+	if d := c.EvaluateDetail("new-checkout", EvalContext{}); d.Reason == ReasonOverride || d.Value != "false" {
+		t.Errorf("EvaluateDetail() in prod = %q/%s, overrides must be ignored", d.Value, d.Reason)
+	}
+}
+
+func TestPrerequisites(t *testing.T) {
+	c := NewMizuClient("", "dev", "", []string{"default", "payments"})
+	put := func(ns, key, typ, value string, rev int64) {
+		c.handleUpdate(v1.Message{Namespace: ns, Key: key, Value: value, Type: typ, Revision: rev, Action: constraints.PUT})
+	}
+	put("default", "new-checkout", constraints.TypeBool, "true", 1)
+	put("default", "checkout-theme", constraints.TypeStrategy, `{"default_value":"classic","prerequisites":[{"key":"new-checkout","value":"true"}],"rules":[{"attribute":"country","operator":"in","value":["JP"],"result":"dark"}]}`, 2)
+	put("default", "theme-banner", constraints.TypeStrategy, `{"default_value":"off","prerequisites":[{"key":"checkout-theme","value":"dark"}],"rules":[{"rollout":{"variants":[{"value":"on","weight":100}]}}]}`, 3)
+	// prerequisites resolve in the namespace of the dependent flag
+	put("payments", "checkout-theme", constraints.TypeStrategy, `{"default_value":"classic","prerequisites":[{"key":"new-checkout","value":"true"}],"rules":[]}`, 4)
+
+	jp := NewEvalContext("user-1").With("country", "JP")
+	if d := c.EvaluateDetail("checkout-theme", jp); d.Value != "dark" || d.Reason != ReasonTargetMatch {
+		t.Errorf("checkout-theme = %q/%s, want dark/TARGET_MATCH", d.Value, d.Reason)
+	}
+	if d := c.EvaluateDetail("theme-banner", jp); d.Value != "on" {
+		t.Errorf("theme-banner = %q/%s, want on through the prerequisite chain", d.Value, d.Reason)
+	}
+	if d := c.EvaluateDetail("theme-banner", NewEvalContext("user-1")); d.Value != "off" || d.Reason != ReasonPrerequisiteFailed {
+		t.Errorf("theme-banner outside JP = %q/%s, want off/PREREQUISITE_FAILED", d.Value, d.Reason)
+	}
+	if d := c.EvaluateDetailIn("payments", "checkout-theme", jp); d.Reason != ReasonPrerequisiteFailed {
+		t.Errorf("payments/checkout-theme = %s, want PREREQUISITE_FAILED without payments/new-checkout", d.Reason)
+	}
+
+	put("default", "new-checkout", constraints.TypeBool, "false", 5)
+	if d := c.EvaluateDetail("checkout-theme", jp); d.Value != "classic" || d.Reason != ReasonPrerequisiteFailed || d.RuleIndex != -1 {
+		t.Errorf("checkout-theme = %+v, want the default once the prerequisite is off", d)
+	}
+
+	// a cycle the server should have rejected must not recurse forever
+	put("default", "a", constraints.TypeStrategy, `{"default_value":"x","prerequisites":[{"key":"b","value":"x"}]}`, 6)
+	put("default", "b", constraints.TypeStrategy, `{"default_value":"x","prerequisites":[{"key":"a","value":"x"}]}`, 7)
+	if d := c.EvaluateDetail("a", jp); d.Reason != ReasonPrerequisiteFailed {
+		t.Errorf("cyclic prerequisite = %s, want PREREQUISITE_FAILED", d.Reason)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+)
+
+// TargetingKeyAttribute resolves to EvalContext.TargetingKey in rule conditions.
+const TargetingKeyAttribute = "targeting_key"
+
+// EvalContext describes the subject a flag is evaluated for. Attribute values
+// may be strings, numbers, booleans, string slices or time.Time. TargetingKey
+// identifies the subject and is the default bucketing attribute of rollouts.
+type EvalContext struct {
+	TargetingKey string
+	Attributes   map[string]any
+
+	// flat backs ContextFromMap without copying the caller's map
+	flat map[string]string
+}
+
+func NewEvalContext(targetingKey string) EvalContext {
+	return EvalContext{TargetingKey: targetingKey}
+}
+
+// ContextFromMap adapts the attribute maps of the string based API.
+func ContextFromMap(attributes map[string]string) EvalContext {
+	return EvalContext{flat: attributes}
+}
+
+// With returns a copy of the context with the attribute set.
+func (e EvalContext) With(name string, value any) EvalContext {
+	attrs := make(map[string]any, len(e.Attributes)+len(e.flat)+1)
+	for k, v := range e.flat {
+		attrs[k] = v
+	}
+	maps.Copy(attrs, e.Attributes)
+	attrs[name] = value
+	e.Attributes = attrs
+	e.flat = nil
+	return e
+}
+
+func (e EvalContext) lookup(name string) (any, bool) {
+	if name == TargetingKeyAttribute && e.TargetingKey != "" {
+		return e.TargetingKey, true
+	}
+	if v, ok := e.Attributes[name]; ok {
+		return v, true
+	}
+	v, ok := e.flat[name]
+	return v, ok
+}
+
+func scalarString(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case bool:
+		return strconv.FormatBool(t), true
+	case int:
+		return strconv.FormatInt(int64(t), 10), true
+	case int32:
+		return strconv.FormatInt(int64(t), 10), true
+	case int64:
+		return strconv.FormatInt(t, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10), true
+	case uint64:
+		return strconv.FormatUint(t, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case json.Number:
+		return t.String(), true
+	case time.Time:
+		return t.Format(time.RFC3339Nano), true
+	case fmt.Stringer:
+		return t.String(), true
+	}
+	return "", false
+}
+
+func attributeNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// attributeTime accepts time.Time values and RFC3339 strings.
+func attributeTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		ts, err := time.Parse(time.RFC3339, t)
+		return ts, err == nil
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"mizuflow/pkg/constraints"
+	"slices"
+	"strconv"
+)
+
+// Reason tells where an evaluated value came from.
+type Reason string
+
+const (
+	// ReasonTargetMatch means a strategy rule matched the evaluation context.
+	ReasonTargetMatch Reason = "TARGET_MATCH"
+	// ReasonDefault means the flag value or the strategy default was served.
+	ReasonDefault Reason = "DEFAULT"
+	// ReasonFlagNotFound means the flag is unknown to the client.
+	ReasonFlagNotFound Reason = "FLAG_NOT_FOUND"
+	// ReasonParseError means the strategy could not be decoded and the raw value was served.
+	ReasonParseError Reason = "PARSE_ERROR"
+	// ReasonTypeMismatch means the value does not conform to the declared or requested type.
+	ReasonTypeMismatch Reason = "TYPE_MISMATCH"
+	// ReasonOverride means a local override was served instead of the server value.
+	ReasonOverride Reason = "OVERRIDE"
+	// ReasonPrerequisiteFailed means a prerequisite flag did not evaluate to its
+	// required value and the strategy default was served.
+	ReasonPrerequisiteFailed Reason = "PREREQUISITE_FAILED"
+)
+
+// EvaluationDetail is the result of evaluating a flag together with its provenance.
+type EvaluationDetail struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Reason    Reason `json:"reason"`
+	// RuleIndex is the position of the matched rule, -1 when no rule matched.
+	RuleIndex int    `json:"rule_index"`
+	RuleID    string `json:"rule_id,omitempty"`
+	Version   int    `json:"version"`
+	Revision  int64  `json:"revision"`
+}
+
+// Found reports whether the flag exists.
+func (d EvaluationDetail) Found() bool {
+	return d.Reason != ReasonFlagNotFound
+}
+
+// EvaluateDetail evaluates a flag of the default namespace.
+func (c *MizuClient) EvaluateDetail(key string, context EvalContext) EvaluationDetail {
+	return c.EvaluateDetailIn(c.defaultNamespace, key, context)
+}
+
+func (c *MizuClient) EvaluateDetailIn(namespace, key string, context EvalContext) EvaluationDetail {
+	return c.evaluate(namespace, key, context)
+}
+
+// EvaluateAll evaluates every flag of the default namespace.
+func (c *MizuClient) EvaluateAll(context EvalContext) []EvaluationDetail {
+	return c.EvaluateAllIn(c.defaultNamespace, context)
+}
+
+// EvaluateAllIn evaluates every flag the client holds in namespace, ordered by key.
+func (c *MizuClient) EvaluateAllIn(namespace string, context EvalContext) []EvaluationDetail {
+	table := c.table.Load()
+	if table == nil {
+		return nil
+	}
+	var keys []string
+	for k := range *table {
+		if k.namespace == namespace {
+			keys = append(keys, k.key)
+		}
+	}
+	slices.Sort(keys)
+
+	details := make([]EvaluationDetail, 0, len(keys))
+	for _, key := range keys {
+		// a flag deleted since the table was read is left out
+		if d := c.evaluate(namespace, key, context); d.Found() {
+			details = append(details, d)
+		}
+	}
+	return details
+}
+
+// conformsTo checks a plain (non-strategy) value against its declared type.
+func conformsTo(typeStr, value string) bool {
+	switch typeStr {
+	case constraints.TypeBool:
+		return value == "true" || value == "false"
+	case constraints.TypeNumber:
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case constraints.TypeJSON:
+		return json.Valid([]byte(value))
+	}
+	return true
+}
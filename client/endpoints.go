@@ -0,0 +1,105 @@
+package client
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// failureMemory is how long a failure counts against an endpoint.
+const failureMemory = 2 * time.Minute
+
+type endpoint struct {
+	addr        string
+	failures    int // consecutive
+	lastFailure time.Time
+}
+
+// endpointSet tracks the health of the server replicas a client can talk to.
+type endpointSet struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	current   int
+}
+
+func newEndpointSet(addrs []string) *endpointSet {
+	s := &endpointSet{}
+	for _, addr := range addrs {
+		if addr == "" || slices.ContainsFunc(s.endpoints, func(e *endpoint) bool { return e.addr == addr }) {
+			continue
+		}
+		s.endpoints = append(s.endpoints, &endpoint{addr: addr})
+	}
+	if len(s.endpoints) == 0 {
+		s.endpoints = append(s.endpoints, &endpoint{})
+	}
+	return s
+}
+
+// WithEndpoints adds server replicas to fail over to. The address passed to
+// NewMizuClient stays the first choice.
+func WithEndpoints(addrs ...string) Option {
+	return func(c *MizuClient) {
+		c.endpoints = newEndpointSet(append([]string{c.addr}, addrs...))
+	}
+}
+
+// CurrentEndpoint is the address the client is talking to, or will try next.
+func (c *MizuClient) CurrentEndpoint() string {
+	return c.endpoints.get()
+}
+
+func (s *endpointSet) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endpoints[s.current].addr
+}
+
+func (s *endpointSet) size() int {
+	return len(s.endpoints)
+}
+
+func (s *endpointSet) success(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.index(addr); i >= 0 {
+		s.endpoints[i].failures = 0
+		s.current = i
+	}
+}
+
+// failure records a failed attempt and moves to the healthiest endpoint. It
+// reports whether that endpoint has no recent failures, so it is worth trying
+// without backing off.
+func (s *endpointSet) failure(addr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if i := s.index(addr); i >= 0 {
+		s.endpoints[i].failures++
+		s.endpoints[i].lastFailure = now
+	}
+
+	// walk the ring starting after the current endpoint, so ties rotate
+	n := len(s.endpoints)
+	best := (s.current + 1) % n
+	for step := 2; step <= n; step++ {
+		i := (s.current + step) % n
+		if s.endpoints[i].recentFailures(now) < s.endpoints[best].recentFailures(now) {
+			best = i
+		}
+	}
+	s.current = best
+	return s.endpoints[best].recentFailures(now) == 0
+}
+
+func (s *endpointSet) index(addr string) int {
+	return slices.IndexFunc(s.endpoints, func(e *endpoint) bool { return e.addr == addr })
+}
+
+func (e *endpoint) recentFailures(now time.Time) int {
+	if now.Sub(e.lastFailure) > failureMemory {
+		return 0
+	}
+	return e.failures
+}
@@ -0,0 +1,574 @@
+package client
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"math"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/logger"
+	"mizuflow/pkg/semver"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func (c *MizuClient) evaluate(namespace, key string, context EvalContext) EvaluationDetail {
+	detail := c.resolve(namespace, key, context)
+	c.exposures.record(detail)
+	return detail
+}
+
+func (c *MizuClient) resolve(namespace, key string, context EvalContext) EvaluationDetail {
+	return c.evaluatePrerequisite(namespace, key, context, 0)
+}
+
+// evaluatePrerequisite resolves a flag at the given prerequisite depth, so
+// prerequisites see local overrides like any other evaluation.
+func (c *MizuClient) evaluatePrerequisite(namespace, key string, context EvalContext, depth int) EvaluationDetail {
+	if value, ok := c.overrides.lookup(namespace, key); ok {
+		return EvaluationDetail{Namespace: namespace, Key: key, Value: value, Reason: ReasonOverride, RuleIndex: -1}
+	}
+	cf, ok := c.lookupCompiled(namespace, key)
+	if !ok {
+		logger.Warn("key not found", zap.String("namespace", namespace), zap.String("key", key))
+		return EvaluationDetail{Namespace: namespace, Key: key, Reason: ReasonFlagNotFound, RuleIndex: -1}
+	}
+	return cf.evaluate(context, c, depth)
+}
+
+// maxPrerequisiteDepth bounds prerequisite chains. The server rejects cycles;
+// the bound keeps a cycle that slipped through from recursing forever.
+const maxPrerequisiteDepth = 8
+
+// prerequisiteSource evaluates the flags a strategy depends on.
+type prerequisiteSource interface {
+	evaluatePrerequisite(namespace, key string, context EvalContext, depth int) EvaluationDetail
+}
+
+// FlagLookup finds another flag of the namespace being evaluated.
+type FlagLookup func(key string) (v1.FeatureFlag, bool)
+
+func (lookup FlagLookup) evaluatePrerequisite(namespace, key string, context EvalContext, depth int) EvaluationDetail {
+	var feature v1.FeatureFlag
+	ok := false
+	if lookup != nil {
+		feature, ok = lookup(key)
+	}
+	if !ok {
+		return EvaluationDetail{Namespace: namespace, Key: key, Reason: ReasonFlagNotFound, RuleIndex: -1}
+	}
+	return compileFlag(feature).evaluate(context, lookup, depth)
+}
+
+// EvaluateFlag evaluates a single flag against context. It is the evaluation
+// used by MizuClient, exported for clients that hold flags themselves. It
+// compiles the flag on every call; MizuClient compiles once per revision.
+// Prerequisites always fail, use EvaluateFlagWith to resolve them.
+func EvaluateFlag(feature v1.FeatureFlag, context EvalContext) EvaluationDetail {
+	return EvaluateFlagWith(feature, context, nil)
+}
+
+// EvaluateFlagWith is EvaluateFlag resolving prerequisites through lookup.
+func EvaluateFlagWith(feature v1.FeatureFlag, context EvalContext, lookup FlagLookup) EvaluationDetail {
+	return compileFlag(feature).evaluate(context, lookup, 0)
+}
+
+// compiledFlag is the immutable evaluation form of one flag revision: the
+// strategy is decoded, thresholds and bounds parsed, sets built and regexes
+// compiled. It is replaced, never modified, when the flag changes.
+type compiledFlag struct {
+	flag     v1.FeatureFlag
+	reason   Reason            // of the plain value: DEFAULT, TYPE_MISMATCH or PARSE_ERROR
+	strategy *compiledStrategy // nil unless the flag is a valid strategy
+	decoded  decodeCache
+}
+
+type compiledStrategy struct {
+	defaultValue  string
+	prerequisites []v1.Prerequisite
+	rules         []compiledRule
+}
+
+type compiledRule struct {
+	id      string
+	result  string
+	cond    predicate // nil when the rule has no condition
+	rollout *compiledRollout
+}
+
+type predicate func(EvalContext) bool
+
+type compiledRollout struct {
+	attribute string
+	variants  []compiledVariant
+}
+
+type compiledVariant struct {
+	value  string
+	weight float64
+	prefix uint64 // FNV-1a state after "salt:value:"
+}
+
+func compileFlag(feature v1.FeatureFlag) *compiledFlag {
+	cf := &compiledFlag{flag: feature, reason: ReasonDefault}
+	if feature.Type != constraints.TypeStrategy {
+		if !conformsTo(feature.Type, feature.Value) {
+			cf.reason = ReasonTypeMismatch
+		}
+		return cf
+	}
+	var strategy v1.FeatureStrategy
+	if err := json.Unmarshal([]byte(feature.Value), &strategy); err != nil {
+		logger.Warn("failed to parse strategy, serving raw value", zap.String("namespace", feature.Namespace), zap.String("key", feature.Key), zap.Error(err))
+		cf.reason = ReasonParseError
+		return cf
+	}
+
+	// the salt makes every flag sample a different slice of the population
+	salt := strategy.Seed
+	if salt == "" {
+		salt = feature.Key
+	}
+	cs := &compiledStrategy{
+		defaultValue:  strategy.DefaultValue,
+		prerequisites: strategy.Prerequisites,
+		rules:         make([]compiledRule, 0, len(strategy.Rules)),
+	}
+	for _, rule := range strategy.Rules {
+		cs.rules = append(cs.rules, compileRule(rule, salt))
+	}
+	cf.strategy = cs
+	return cf
+}
+
+func (cf *compiledFlag) evaluate(context EvalContext, prerequisites prerequisiteSource, depth int) EvaluationDetail {
+	detail := EvaluationDetail{
+		Namespace: cf.flag.Namespace,
+		Key:       cf.flag.Key,
+		Value:     cf.flag.Value,
+		Reason:    cf.reason,
+		RuleIndex: -1,
+		Version:   cf.flag.Version,
+		Revision:  cf.flag.Revision,
+	}
+	if cf.strategy == nil {
+		return detail
+	}
+
+	if !cf.strategy.prerequisitesMet(cf.flag.Namespace, context, prerequisites, depth) {
+		detail.Value = cf.strategy.defaultValue
+		detail.Reason = ReasonPrerequisiteFailed
+		return detail
+	}
+
+	for i := range cf.strategy.rules {
+		rule := &cf.strategy.rules[i]
+		if !rule.matches(context) {
+			continue
+		}
+		value := rule.result
+		if rule.rollout != nil {
+			variant, ok := rule.rollout.pick(context)
+			if !ok {
+				continue
+			}
+			value = variant
+		}
+		detail.Value = value
+		detail.Reason = ReasonTargetMatch
+		detail.RuleIndex = i
+		detail.RuleID = rule.id
+		return detail
+	}
+
+	detail.Value = cf.strategy.defaultValue
+	return detail
+}
+
+func (s *compiledStrategy) prerequisitesMet(namespace string, context EvalContext, source prerequisiteSource, depth int) bool {
+	if len(s.prerequisites) == 0 {
+		return true
+	}
+	if depth >= maxPrerequisiteDepth {
+		logger.Warn("prerequisite chain too deep", zap.String("namespace", namespace), zap.Int("depth", depth))
+		return false
+	}
+	for _, p := range s.prerequisites {
+		detail := source.evaluatePrerequisite(namespace, p.Key, context, depth+1)
+		// a prerequisite that fails its own prerequisites fails the chain, whatever its default
+		if !detail.Found() || detail.Reason == ReasonPrerequisiteFailed || detail.Value != p.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func compileRule(rule v1.Rule, salt string) compiledRule {
+	cr := compiledRule{id: rule.ID, result: rule.Result}
+	if rule.HasCondition() {
+		cr.cond = compileCondition(rule.Condition(), salt)
+	}
+	if rule.Rollout != nil {
+		cr.rollout = compileRollout(rule.Rollout, salt)
+	}
+	return cr
+}
+
+// matches reports whether the rule applies; a rule without a condition only
+// matches when it rolls out variants to everyone.
+func (r compiledRule) matches(context EvalContext) bool {
+	if r.cond == nil {
+		return r.rollout != nil
+	}
+	return r.cond(context)
+}
+
+func compileCondition(cond v1.Condition, salt string) predicate {
+	if !cond.IsGroup() {
+		match := compileAttribute(cond, salt)
+		if cond.Negate {
+			return func(context EvalContext) bool { return !match(context) }
+		}
+		return match
+	}
+
+	subs := make([]predicate, 0, len(cond.Conditions))
+	for _, sub := range cond.Conditions {
+		subs = append(subs, compileCondition(sub, salt))
+	}
+	isAnd := cond.Combinator != constraints.CombinatorOr
+	negate := cond.Negate
+	return func(context EvalContext) bool {
+		matched := isAnd
+		for _, sub := range subs {
+			if sub(context) != matched {
+				// short-circuit: first miss of an AND group, first hit of an OR group
+				matched = !matched
+				break
+			}
+		}
+		return matched != negate
+	}
+}
+
+func never(EvalContext) bool { return false }
+
+// compileAttribute builds the test of a single condition. List attributes match
+// when any element does; not_in and neq require that no element does.
+func compileAttribute(cond v1.Condition, salt string) predicate {
+	values := cond.Values
+	if len(values) == 0 {
+		return never
+	}
+
+	var test func(attr any) bool
+	switch cond.Operator {
+	case constraints.OpIn, constraints.OpNotIn:
+		set := make(map[string]struct{}, len(values))
+		for _, v := range values {
+			set[v] = struct{}{}
+		}
+		in := func(val string) bool { _, ok := set[val]; return ok }
+		if cond.Operator == constraints.OpIn {
+			test = func(attr any) bool { return anyString(attr, in) }
+		} else {
+			test = func(attr any) bool { return !anyString(attr, in) }
+		}
+	case constraints.OpEq:
+		want := values[0]
+		test = func(attr any) bool { return anyString(attr, func(val string) bool { return val == want }) }
+	case constraints.OpNeq:
+		want := values[0]
+		test = func(attr any) bool { return !anyString(attr, func(val string) bool { return val == want }) }
+	case constraints.OpMod:
+		// values[0] is expected to be an integer threshold between 0-100
+		threshold, err := strconv.Atoi(values[0])
+		if err != nil {
+			return never
+		}
+		prefix := bucketPrefix(salt)
+		test = func(attr any) bool {
+			val, ok := scalarString(attr)
+			return ok && int(fnv32a(prefix, val)%100) < threshold
+		}
+	case constraints.OpGt, constraints.OpGte, constraints.OpLt, constraints.OpLte:
+		test = compileOrdered(cond.Operator, values[0])
+	case constraints.OpSemverGt, constraints.OpSemverLt, constraints.OpSemverRange:
+		test = compileSemver(cond.Operator, values)
+	case constraints.OpRegex:
+		re, err := regexp.Compile(values[0])
+		if err != nil {
+			return never
+		}
+		test = func(attr any) bool { return anyString(attr, re.MatchString) }
+	case constraints.OpContains:
+		test = func(attr any) bool {
+			return anyString(attr, func(val string) bool {
+				return slices.ContainsFunc(values, func(v string) bool { return strings.Contains(val, v) })
+			})
+		}
+	case constraints.OpStartsWith:
+		test = func(attr any) bool {
+			return anyString(attr, func(val string) bool {
+				return slices.ContainsFunc(values, func(v string) bool { return strings.HasPrefix(val, v) })
+			})
+		}
+	case constraints.OpEndsWith:
+		test = func(attr any) bool {
+			return anyString(attr, func(val string) bool {
+				return slices.ContainsFunc(values, func(v string) bool { return strings.HasSuffix(val, v) })
+			})
+		}
+	}
+	if test == nil {
+		return never
+	}
+
+	attribute := cond.Attribute
+	return func(context EvalContext) bool {
+		attr, ok := context.lookup(attribute)
+		return ok && test(attr)
+	}
+}
+
+// anyString reports whether match accepts the attribute or, for lists, any of its elements.
+func anyString(attr any, match func(string) bool) bool {
+	switch t := attr.(type) {
+	case string:
+		return match(t)
+	case []string:
+		return slices.ContainsFunc(t, match)
+	case []any:
+		for _, item := range t {
+			if s, ok := scalarString(item); ok && match(s) {
+				return true
+			}
+		}
+		return false
+	}
+	s, ok := scalarString(attr)
+	return ok && match(s)
+}
+
+// compileOrdered builds gt/gte/lt/lte. Numeric targets compare numbers (time
+// attributes as unix seconds); RFC3339 targets compare timestamps.
+func compileOrdered(op, target string) func(any) bool {
+	if b, err := strconv.ParseFloat(target, 64); err == nil {
+		return func(attr any) bool {
+			if t, ok := attr.(time.Time); ok {
+				return orderedResult(op, cmp.Compare(float64(t.UnixMilli())/1000, b))
+			}
+			a, ok := attributeNumber(attr)
+			return ok && orderedResult(op, cmp.Compare(a, b))
+		}
+	}
+	bound, err := time.Parse(time.RFC3339, target)
+	if err != nil {
+		return nil
+	}
+	return func(attr any) bool {
+		t, ok := attributeTime(attr)
+		return ok && orderedResult(op, t.Compare(bound))
+	}
+}
+
+func orderedResult(op string, c int) bool {
+	switch op {
+	case constraints.OpGt:
+		return c > 0
+	case constraints.OpGte:
+		return c >= 0
+	case constraints.OpLt:
+		return c < 0
+	case constraints.OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// compileSemver builds the semver operators. semver_range is a half-open
+// interval: values[0] <= val < values[1].
+func compileSemver(op string, values []string) func(any) bool {
+	lower, err := semver.Parse(values[0])
+	if err != nil {
+		return nil
+	}
+	var inRange func(v semver.Version) bool
+	switch op {
+	case constraints.OpSemverGt:
+		inRange = func(v semver.Version) bool { return semver.Compare(v, lower) > 0 }
+	case constraints.OpSemverLt:
+		inRange = func(v semver.Version) bool { return semver.Compare(v, lower) < 0 }
+	case constraints.OpSemverRange:
+		if len(values) < 2 {
+			return nil
+		}
+		upper, err := semver.Parse(values[1])
+		if err != nil {
+			return nil
+		}
+		inRange = func(v semver.Version) bool { return semver.Compare(v, lower) >= 0 && semver.Compare(v, upper) < 0 }
+	}
+	return func(attr any) bool {
+		return anyString(attr, func(val string) bool {
+			v, err := semver.Parse(val)
+			return err == nil && inRange(v)
+		})
+	}
+}
+
+// An empty bucket_by buckets by the targeting key.
+func compileRollout(rollout *v1.Rollout, salt string) *compiledRollout {
+	cr := &compiledRollout{attribute: rollout.BucketBy}
+	if cr.attribute == "" {
+		cr.attribute = TargetingKeyAttribute
+	}
+	for _, variant := range rollout.Variants {
+		cr.variants = append(cr.variants, compiledVariant{
+			value:  variant.Value,
+			weight: float64(variant.Weight),
+			prefix: fnv64a(fnv64a(fnv64a(fnv64a(offset64, salt), ":"), variant.Value), ":"),
+		})
+	}
+	return cr
+}
+
+// pick selects a variant with weighted rendezvous hashing: every variant scores
+// the bucketing value independently and the highest score wins. Raising a
+// variant's weight only pulls users into it, so the users of a growing variant
+// never move elsewhere.
+func (r *compiledRollout) pick(context EvalContext) (string, bool) {
+	attr, ok := context.lookup(r.attribute)
+	if !ok {
+		return "", false
+	}
+	val, ok := scalarString(attr)
+	if !ok {
+		return "", false
+	}
+
+	best := -1
+	var bestScore float64
+	for i := range r.variants {
+		variant := &r.variants[i]
+		if variant.weight <= 0 {
+			continue
+		}
+		// uniform in (0, 1) from the top 53 bits
+		u := (float64(mix64(fnv64a(variant.prefix, val))>>11) + 0.5) / (1 << 53)
+		score := variant.weight / -math.Log(u)
+		if best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return r.variants[best].value, true
+}
+
+const (
+	offset32 = 2166136261
+	prime32  = 16777619
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
+
+// fnv32a and fnv64a continue an FNV-1a hash from state h, so the salt prefix
+// is hashed once at compile time instead of on every evaluation.
+func fnv32a(h uint32, s string) uint32 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+	return h
+}
+
+func fnv64a(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
+
+func bucketPrefix(salt string) uint32 {
+	return fnv32a(fnv32a(offset32, salt), ":")
+}
+
+// bucket maps val into [0, 100) for salt, the same slice mod rules select.
+func bucket(salt, val string) int {
+	return int(fnv32a(bucketPrefix(salt), val) % 100)
+}
+
+// mix64 is the splitmix64 finalizer; FNV alone spreads trailing bytes poorly into the high bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+type flagKey struct {
+	namespace string
+	key       string
+}
+
+// flagTable is the compiled form of the store. It is copy-on-write: writers
+// build a new table under c.mu and swap it in, readers load it without locking.
+type flagTable map[flagKey]*compiledFlag
+
+func (c *MizuClient) lookupCompiled(namespace, key string) (*compiledFlag, bool) {
+	table := c.table.Load()
+	if table == nil {
+		return nil, false
+	}
+	cf, ok := (*table)[flagKey{namespace: namespace, key: key}]
+	return cf, ok
+}
+
+// rebuildTable compiles c.features into a new table, reusing the compiled
+// flags that did not change. Callers hold c.mu.
+func (c *MizuClient) rebuildTable() {
+	var old flagTable
+	if t := c.table.Load(); t != nil {
+		old = *t
+	}
+	table := make(flagTable, len(c.features))
+	for _, f := range c.features {
+		k := flagKey{namespace: f.Namespace, key: f.Key}
+		if cf, ok := old[k]; ok && cf.flag == f {
+			table[k] = cf
+			continue
+		}
+		table[k] = compileFlag(f)
+	}
+	c.table.Store(&table)
+}
+
+// storeCompiled swaps in a table with one flag replaced, or removed when f is
+// nil. Callers hold c.mu.
+func (c *MizuClient) storeCompiled(namespace, key string, f *v1.FeatureFlag) {
+	var table flagTable
+	if t := c.table.Load(); t != nil {
+		table = maps.Clone(*t)
+	}
+	if table == nil {
+		table = make(flagTable)
+	}
+	k := flagKey{namespace: namespace, key: key}
+	if f == nil {
+		delete(table, k)
+	} else {
+		table[k] = compileFlag(*f)
+	}
+	c.table.Store(&table)
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxVariantLength truncates reported values so large JSON flags don't bloat reports
+	maxVariantLength     = 64
+	exposureQueueSize    = 4096
+	defaultExposureFlush = time.Minute
+	defaultExposureLimit = 1000
+)
+
+type exposureKey struct {
+	namespace string
+	key       string
+	variant   string
+	reason    Reason
+}
+
+// exposureRecorder aggregates evaluations in memory. Recording never blocks:
+// when the queue or the aggregation window is full the evaluation is dropped
+// and only counted.
+type exposureRecorder struct {
+	events     chan exposureKey
+	interval   time.Duration
+	maxEntries int
+	dropped    atomic.Int64
+	inflight   atomic.Bool
+}
+
+// WithExposureReporting makes the client report which flags it evaluates and
+// which values it served. Reports are flushed every interval and hold at most
+// maxEntries distinct flag/variant/reason combinations.
+func WithExposureReporting(interval time.Duration, maxEntries int) Option {
+	return func(c *MizuClient) {
+		if interval <= 0 {
+			interval = defaultExposureFlush
+		}
+		if maxEntries <= 0 {
+			maxEntries = defaultExposureLimit
+		}
+		c.exposures = &exposureRecorder{
+			events:     make(chan exposureKey, exposureQueueSize),
+			interval:   interval,
+			maxEntries: maxEntries,
+		}
+	}
+}
+
+func (r *exposureRecorder) record(detail EvaluationDetail) {
+	if r == nil {
+		return
+	}
+	variant := detail.Value
+	// a value within the limit in bytes is within it in runes too, only longer
+	// ones pay for the conversion
+	if len(variant) > maxVariantLength {
+		// by runes, a cut inside a multi-byte character would be invalid UTF-8
+		if runes := []rune(variant); len(runes) > maxVariantLength {
+			variant = string(runes[:maxVariantLength])
+		}
+	}
+	select {
+	case r.events <- exposureKey{namespace: detail.Namespace, key: detail.Key, variant: variant, reason: detail.Reason}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+func (c *MizuClient) runExposureLoop() {
+	r := c.exposures
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	window := make(map[exposureKey]int64)
+	windowStart := time.Now()
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.flushExposures(window, windowStart)
+			return
+		case ev := <-r.events:
+			if _, ok := window[ev]; !ok && len(window) >= r.maxEntries {
+				r.dropped.Add(1)
+				continue
+			}
+			window[ev]++
+		case now := <-ticker.C:
+			dropped := r.dropped.Swap(0)
+			if len(window) == 0 && dropped == 0 {
+				windowStart = now
+				continue
+			}
+			report := c.exposureReport(window, windowStart, now, dropped)
+			window = make(map[exposureKey]int64)
+			windowStart = now
+
+			// only one report in flight; a slow server costs us this window, not latency
+			if !r.inflight.CompareAndSwap(false, true) {
+				for _, e := range report.Entries {
+					r.dropped.Add(e.Count)
+				}
+				r.dropped.Add(dropped)
+				continue
+			}
+			c.wg.Add(1)
+			go func() {
+				defer c.wg.Done()
+				defer r.inflight.Store(false)
+				if err := c.sendExposures(c.ctx, report); err != nil {
+					logger.Warn("failed to report exposures", zap.Int("entries", len(report.Entries)), zap.Error(err))
+				}
+			}()
+		}
+	}
+}
+
+func (c *MizuClient) exposureReport(window map[exposureKey]int64, start, end time.Time, dropped int64) v1.ExposureReport {
+	report := v1.ExposureReport{
+		Env:         c.env,
+		WindowStart: start.UnixMilli(),
+		WindowEnd:   end.UnixMilli(),
+		Dropped:     dropped,
+		Entries:     make([]v1.ExposureEntry, 0, len(window)),
+	}
+	for k, count := range window {
+		report.Entries = append(report.Entries, v1.ExposureEntry{
+			Namespace: k.namespace,
+			Key:       k.key,
+			Variant:   k.variant,
+			Reason:    string(k.reason),
+			Count:     count,
+		})
+	}
+	return report
+}
+
+// flushExposures sends the last window on shutdown, including events still queued.
+func (c *MizuClient) flushExposures(window map[exposureKey]int64, start time.Time) {
+	r := c.exposures
+drain:
+	for {
+		select {
+		case ev := <-r.events:
+			if _, ok := window[ev]; !ok && len(window) >= r.maxEntries {
+				r.dropped.Add(1)
+				continue
+			}
+			window[ev]++
+		default:
+			break drain
+		}
+	}
+	dropped := r.dropped.Swap(0)
+	if len(window) == 0 && dropped == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.sendExposures(ctx, c.exposureReport(window, start, time.Now(), dropped)); err != nil {
+		logger.Warn("failed to flush exposures on close", zap.Int("entries", len(window)), zap.Error(err))
+	}
+}
+
+func (c *MizuClient) sendExposures(ctx context.Context, report v1.ExposureReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/stream/exposures?env=%s&namespace=%s", c.endpoints.get(), url.QueryEscape(c.env), url.QueryEscape(strings.Join(c.namespaces, ",")))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Mizu-Key", c.apiKey)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ChangeFunc receives the previous and the current state of a flag. old is the
+// zero value when the flag was created. When the flag was deleted, new only
+// carries the flag identity and the revision of the deletion, its Type is empty.
+type ChangeFunc func(old, new v1.FeatureFlag)
+
+type flagChange struct {
+	old v1.FeatureFlag
+	new v1.FeatureFlag
+}
+
+// listener delivers changes to a single callback on its own goroutine, in the
+// order they were applied to the client.
+type listener struct {
+	id        uint64
+	namespace string
+	key       string // empty for OnAnyChange listeners
+	fn        ChangeFunc
+
+	mu      sync.Mutex
+	queue   []flagChange
+	running bool
+	removed bool
+}
+
+type listenerSet struct {
+	mu        sync.RWMutex
+	nextID    uint64
+	listeners map[uint64]*listener
+}
+
+// OnChange registers fn for changes of a flag in the default namespace and
+// returns a function that unsubscribes it.
+func (c *MizuClient) OnChange(key string, fn ChangeFunc) func() {
+	return c.OnChangeIn(c.defaultNamespace, key, fn)
+}
+
+func (c *MizuClient) OnChangeIn(namespace, key string, fn ChangeFunc) func() {
+	return c.hooks.add(&listener{namespace: namespace, key: key, fn: fn})
+}
+
+// OnAnyChange registers fn for changes of every flag the client holds.
+func (c *MizuClient) OnAnyChange(fn ChangeFunc) func() {
+	return c.hooks.add(&listener{fn: fn})
+}
+
+func (s *listenerSet) add(l *listener) func() {
+	s.mu.Lock()
+	if s.listeners == nil {
+		s.listeners = make(map[uint64]*listener)
+	}
+	s.nextID++
+	l.id = s.nextID
+	s.listeners[l.id] = l
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.listeners, l.id)
+			s.mu.Unlock()
+
+			l.mu.Lock()
+			l.removed = true
+			l.queue = nil
+			l.mu.Unlock()
+		})
+	}
+}
+
+// dispatch queues changes on the matching listeners. Callers hold the client
+// mutex so that queues receive changes in the order they were applied; the
+// callbacks themselves run on the listener goroutines.
+func (s *listenerSet) dispatch(changes []flagChange) {
+	if len(changes) == 0 {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, l := range s.listeners {
+		for _, ch := range changes {
+			if l.key != "" && (l.key != ch.new.Key || l.namespace != ch.new.Namespace) {
+				continue
+			}
+			l.enqueue(ch)
+		}
+	}
+}
+
+func (l *listener) enqueue(ch flagChange) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.removed {
+		return
+	}
+	l.queue = append(l.queue, ch)
+	if !l.running {
+		l.running = true
+		go l.drain()
+	}
+}
+
+func (l *listener) drain() {
+	for {
+		l.mu.Lock()
+		if len(l.queue) == 0 || l.removed {
+			l.running = false
+			l.mu.Unlock()
+			return
+		}
+		ch := l.queue[0]
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+
+		l.invoke(ch)
+	}
+}
+
+func (l *listener) invoke(ch flagChange) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("feature change listener panicked", zap.String("key", ch.new.Key), zap.Any("panic", r))
+		}
+	}()
+	l.fn(ch.old, ch.new)
+}
+
+// diffFeatures lists the changes turning old into new. Deleted flags are reported
+// with the given revision.
+func diffFeatures(old, new map[string]v1.FeatureFlag, rev int64) []flagChange {
+	var changes []flagChange
+	for id, f := range new {
+		prev, ok := old[id]
+		if ok && prev.Version == f.Version && prev.Value == f.Value && prev.Type == f.Type {
+			continue
+		}
+		changes = append(changes, flagChange{old: prev, new: f})
+	}
+	for id, f := range old {
+		if _, ok := new[id]; !ok {
+			changes = append(changes, flagChange{old: f, new: deletedFlag(f, rev)})
+		}
+	}
+	return changes
+}
+
+func deletedFlag(f v1.FeatureFlag, rev int64) v1.FeatureFlag {
+	return v1.FeatureFlag{
+		Namespace: f.Namespace,
+		Env:       f.Env,
+		Key:       f.Key,
+		Revision:  rev,
+	}
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrClosed is returned by WaitForReady when the client was closed before its first sync.
+var ErrClosed = errors.New("mizu client closed")
+
+// State tells where the flags a client serves come from.
+type State int32
+
+const (
+	// StateInitializing means Start has not loaded any flags yet.
+	StateInitializing State = iota
+	// StateLive means the client is in sync with the server.
+	StateLive
+	// StateStaleCache means the flags were loaded from the cache file and the
+	// server has not been reached since.
+	StateStaleCache
+	// StateDisconnected means the client lost the server after a live sync, or was closed.
+	StateDisconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case StateInitializing:
+		return "initializing"
+	case StateLive:
+		return "live"
+	case StateStaleCache:
+		return "stale_cache"
+	case StateDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+func (c *MizuClient) State() State {
+	return State(c.state.Load())
+}
+
+// LastSync is the last time the client confirmed it holds the server's flags:
+// a full fetch, a stream (re)connect, an update or a heartbeat. It is the zero
+// time before the first live sync.
+func (c *MizuClient) LastSync() time.Time {
+	ns := c.lastSync.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// WaitForReady blocks until the first live sync with the server. Flags loaded
+// from the cache file do not count.
+func (c *MizuClient) WaitForReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		return nil
+	default:
+	}
+	select {
+	case <-c.ready:
+		return nil
+	case <-c.ctx.Done():
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the background loops, waits for them to exit and writes a final
+// snapshot to the cache file. Pending exposures are flushed. It is safe to call
+// more than once.
+func (c *MizuClient) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.wg.Wait()
+		c.setState(StateDisconnected)
+
+		c.mu.RLock()
+		dirty := c.isDirty
+		c.mu.RUnlock()
+		if dirty {
+			err = c.saveSnapshot()
+		}
+	})
+	return err
+}
+
+// OnStateChange registers fn for state transitions and returns a function that
+// unsubscribes it. fn runs on the goroutine that changed the state and must not block.
+func (c *MizuClient) OnStateChange(fn func(State)) func() {
+	c.stateMu.Lock()
+	if c.stateListeners == nil {
+		c.stateListeners = make(map[uint64]func(State))
+	}
+	c.nextStateID++
+	id := c.nextStateID
+	c.stateListeners[id] = fn
+	c.stateMu.Unlock()
+
+	return func() {
+		c.stateMu.Lock()
+		delete(c.stateListeners, id)
+		c.stateMu.Unlock()
+	}
+}
+
+func (c *MizuClient) setState(s State) {
+	if State(c.state.Swap(int32(s))) != s {
+		c.notifyState(s)
+	}
+}
+
+func (c *MizuClient) notifyState(s State) {
+	c.stateMu.Lock()
+	fns := make([]func(State), 0, len(c.stateListeners))
+	for _, fn := range c.stateListeners {
+		fns = append(fns, fn)
+	}
+	c.stateMu.Unlock()
+	for _, fn := range fns {
+		fn(s)
+	}
+}
+
+func (c *MizuClient) markSynced() {
+	c.lastSync.Store(time.Now().UnixNano())
+	c.setState(StateLive)
+	c.readyOnce.Do(func() { close(c.ready) })
+}
+
+// markDisconnected records a lost stream; a client that never went live keeps serving its cache.
+func (c *MizuClient) markDisconnected() {
+	if c.state.CompareAndSwap(int32(StateLive), int32(StateDisconnected)) {
+		c.notifyState(StateDisconnected)
+	}
+}
+
+func (c *MizuClient) touchSync() {
+	c.lastSync.Store(time.Now().UnixNano())
+}
+
+// goLoop runs fn as a background loop that Close waits for.
+func (c *MizuClient) goLoop(fn func()) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		fn()
+	}()
+}
@@ -0,0 +1,33 @@
+package client
+
+import v1 "mizuflow/pkg/api/v1"
+
+// Mirror receives the raw synchronization of a client, for processes that
+// serve the flags again, like the relay. Reset is called with the full flag
+// set whenever the client loads a snapshot, from the server or from the cache
+// file; Apply is called with every incremental update, in revision order.
+//
+// Both are called while the client holds its store lock, so they must return
+// quickly and must not call back into the client.
+type Mirror interface {
+	Reset(flags []v1.FeatureFlag, revision int64)
+	Apply(msg v1.Message)
+}
+
+func WithMirror(m Mirror) Option {
+	return func(c *MizuClient) {
+		c.mirror = m
+	}
+}
+
+// mirrorReset passes a full snapshot to the mirror. Callers hold c.mu.
+func (c *MizuClient) mirrorReset(features map[string]v1.FeatureFlag, rev int64) {
+	if c.mirror == nil {
+		return
+	}
+	flags := make([]v1.FeatureFlag, 0, len(features))
+	for _, f := range features {
+		flags = append(flags, f)
+	}
+	c.mirror.Reset(flags, rev)
+}
@@ -0,0 +1,344 @@
+// Package mizutest provides an in-memory client.Client for tests. Flags are
+// seeded from Go values or a fixture file and evaluated exactly like
+// client.MizuClient evaluates them, without a server or a cache file.
+package mizutest
+
+import (
+	"encoding/json"
+	"fmt"
+	"mizuflow/client"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Client holds flags in memory. Change hooks run synchronously before the
+// mutating call returns, so tests can assert on them without waiting.
+type Client struct {
+	defaultNamespace string
+
+	mu        sync.RWMutex
+	flags     map[string]v1.FeatureFlag
+	revision  int64
+	nextID    uint64
+	listeners map[uint64]listener
+}
+
+type listener struct {
+	namespace string
+	key       string // empty for OnAnyChange listeners
+	fn        client.ChangeFunc
+}
+
+var _ client.Client = (*Client)(nil)
+
+type Option func(*Client)
+
+// WithDefaultNamespace sets the namespace used by the methods that take no namespace argument.
+func WithDefaultNamespace(namespace string) Option {
+	return func(c *Client) {
+		c.defaultNamespace = namespace
+	}
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		defaultNamespace: client.DefaultNamespace,
+		flags:            make(map[string]v1.FeatureFlag),
+		listeners:        make(map[uint64]listener),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewFromFixture creates a client seeded from a YAML or JSON fixture file.
+func NewFromFixture(path string, opts ...Option) (*Client, error) {
+	f, err := ReadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	c := New(opts...)
+	if err := c.Load(f); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func flagID(namespace, key string) string {
+	return namespace + "/" + key
+}
+
+// Set stores value under key in the default namespace. See SetIn.
+func (c *Client) Set(key string, value any) {
+	c.SetIn(c.defaultNamespace, key, value)
+}
+
+// SetIn stores value under key. The flag type follows the Go type: bool,
+// numbers and strings map to the plain types, v1.FeatureStrategy to a
+// strategy and anything else is stored as JSON. It panics when value cannot
+// be encoded.
+func (c *Client) SetIn(namespace, key string, value any) {
+	raw, typ, err := encodeValue(value, "")
+	if err != nil {
+		panic(fmt.Sprintf("mizutest: flag %s/%s: %v", namespace, key, err))
+	}
+	c.SetFlag(v1.FeatureFlag{Namespace: namespace, Key: key, Value: raw, Type: typ})
+}
+
+// SetFlag stores flag as is. An empty namespace means the default namespace;
+// Version and Revision are assigned by the client.
+func (c *Client) SetFlag(flag v1.FeatureFlag) {
+	if flag.Namespace == "" {
+		flag.Namespace = c.defaultNamespace
+	}
+	id := flagID(flag.Namespace, flag.Key)
+
+	c.mu.Lock()
+	old := c.flags[id]
+	c.revision++
+	flag.Version = old.Version + 1
+	flag.Revision = c.revision
+	c.flags[id] = flag
+	listeners := c.matching(flag.Namespace, flag.Key)
+	c.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(old, flag)
+	}
+}
+
+// Delete removes a flag of the default namespace.
+func (c *Client) Delete(key string) {
+	c.DeleteIn(c.defaultNamespace, key)
+}
+
+// DeleteIn removes a flag. Hooks receive a new flag with an empty Type, as
+// they do from client.MizuClient.
+func (c *Client) DeleteIn(namespace, key string) {
+	id := flagID(namespace, key)
+
+	c.mu.Lock()
+	old, ok := c.flags[id]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.flags, id)
+	c.revision++
+	deleted := v1.FeatureFlag{Namespace: old.Namespace, Env: old.Env, Key: old.Key, Revision: c.revision}
+	listeners := c.matching(namespace, key)
+	c.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(old, deleted)
+	}
+}
+
+// Load stores every flag of the fixture.
+func (c *Client) Load(f Fixture) error {
+	for _, ff := range f.Flags {
+		if ff.Key == "" {
+			return fmt.Errorf("fixture flag without key")
+		}
+		raw, typ, err := encodeValue(ff.Value, ff.Type)
+		if err != nil {
+			return fmt.Errorf("fixture flag %q: %w", ff.Key, err)
+		}
+		c.SetFlag(v1.FeatureFlag{Namespace: ff.Namespace, Key: ff.Key, Value: raw, Type: typ})
+	}
+	return nil
+}
+
+func (c *Client) lookup(namespace, key string) (v1.FeatureFlag, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	flag, ok := c.flags[flagID(namespace, key)]
+	return flag, ok
+}
+
+func (c *Client) EvaluateDetail(key string, context client.EvalContext) client.EvaluationDetail {
+	return c.EvaluateDetailIn(c.defaultNamespace, key, context)
+}
+
+func (c *Client) EvaluateDetailIn(namespace, key string, context client.EvalContext) client.EvaluationDetail {
+	flag, ok := c.lookup(namespace, key)
+	if !ok {
+		return client.EvaluationDetail{Namespace: namespace, Key: key, Reason: client.ReasonFlagNotFound, RuleIndex: -1}
+	}
+	return client.EvaluateFlagWith(flag, context, func(key string) (v1.FeatureFlag, bool) {
+		return c.lookup(namespace, key)
+	})
+}
+
+func (c *Client) IsEnabled(key string, context map[string]string) bool {
+	return c.IsEnabledIn(c.defaultNamespace, key, context)
+}
+
+func (c *Client) IsEnabledIn(namespace, key string, context map[string]string) bool {
+	detail := c.EvaluateDetailIn(namespace, key, client.ContextFromMap(context))
+	if !detail.Found() {
+		return false
+	}
+	val := detail.Value
+	return val == "true" || val == "True" || val == "TRUE"
+}
+
+func (c *Client) GetString(key string, defaultValue string, context map[string]string) string {
+	return c.GetStringIn(c.defaultNamespace, key, defaultValue, context)
+}
+
+func (c *Client) GetStringIn(namespace, key string, defaultValue string, context map[string]string) string {
+	detail := c.EvaluateDetailIn(namespace, key, client.ContextFromMap(context))
+	if !detail.Found() {
+		return defaultValue
+	}
+	return detail.Value
+}
+
+func (c *Client) GetNumber(key string, defaultValue float64, context map[string]string) float64 {
+	return c.GetNumberIn(c.defaultNamespace, key, defaultValue, context)
+}
+
+func (c *Client) GetNumberIn(namespace, key string, defaultValue float64, context map[string]string) float64 {
+	return client.GetIn(c, namespace, key, defaultValue, client.ContextFromMap(context))
+}
+
+func (c *Client) GetJSON(key string, target any, context map[string]string) error {
+	return c.GetJSONIn(c.defaultNamespace, key, target, context)
+}
+
+func (c *Client) GetJSONIn(namespace, key string, target any, context map[string]string) error {
+	detail := c.EvaluateDetailIn(namespace, key, client.ContextFromMap(context))
+	if !detail.Found() {
+		return fmt.Errorf("feature not found")
+	}
+	return json.Unmarshal([]byte(detail.Value), target)
+}
+
+func (c *Client) OnChange(key string, fn client.ChangeFunc) func() {
+	return c.OnChangeIn(c.defaultNamespace, key, fn)
+}
+
+func (c *Client) OnChangeIn(namespace, key string, fn client.ChangeFunc) func() {
+	return c.subscribe(listener{namespace: namespace, key: key, fn: fn})
+}
+
+func (c *Client) OnAnyChange(fn client.ChangeFunc) func() {
+	return c.subscribe(listener{fn: fn})
+}
+
+func (c *Client) subscribe(l listener) func() {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.listeners[id] = l
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		delete(c.listeners, id)
+		c.mu.Unlock()
+	}
+}
+
+// matching returns the callbacks interested in a flag, ordered by subscription. Callers hold c.mu.
+func (c *Client) matching(namespace, key string) []client.ChangeFunc {
+	ids := make([]uint64, 0, len(c.listeners))
+	for id, l := range c.listeners {
+		if l.key == "" || (l.namespace == namespace && l.key == key) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	fns := make([]client.ChangeFunc, len(ids))
+	for i, id := range ids {
+		fns[i] = c.listeners[id].fn
+	}
+	return fns
+}
+
+// Fixture is the file format of NewFromFixture:
+//
+//	flags:
+//	  - key: new-checkout
+//	    value: true
+//	  - key: checkout-experiment
+//	    namespace: payments
+//	    type: strategy
+//	    value:
+//	      default_value: control
+//	      rules:
+//	        - {attribute: country, operator: in, value: [JP], result: treatment}
+//
+// Type is inferred from the value when omitted; objects and lists are stored
+// as JSON unless the type says strategy.
+type Fixture struct {
+	Flags []FixtureFlag `json:"flags" yaml:"flags"`
+}
+
+type FixtureFlag struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Key       string `json:"key" yaml:"key"`
+	Type      string `json:"type" yaml:"type"`
+	Value     any    `json:"value" yaml:"value"`
+}
+
+// ReadFixture reads a fixture file; .json files are decoded as JSON, anything else as YAML.
+func ReadFixture(path string) (Fixture, error) {
+	var f Fixture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &f)
+	} else {
+		err = yaml.Unmarshal(data, &f)
+	}
+	if err != nil {
+		return f, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// encodeValue renders value in the string form flags are stored in. typ
+// overrides the inferred type.
+func encodeValue(value any, typ string) (string, string, error) {
+	var raw, inferred string
+	switch v := value.(type) {
+	case string:
+		raw, inferred = v, constraints.TypeString
+	case bool:
+		raw, inferred = strconv.FormatBool(v), constraints.TypeBool
+	case int:
+		raw, inferred = strconv.Itoa(v), constraints.TypeNumber
+	case int64:
+		raw, inferred = strconv.FormatInt(v, 10), constraints.TypeNumber
+	case float64:
+		raw, inferred = strconv.FormatFloat(v, 'f', -1, 64), constraints.TypeNumber
+	case v1.FeatureStrategy, *v1.FeatureStrategy:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", "", err
+		}
+		raw, inferred = string(b), constraints.TypeStrategy
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", "", err
+		}
+		raw, inferred = string(b), constraints.TypeJSON
+	}
+	if typ == "" {
+		typ = inferred
+	}
+	return raw, typ, nil
+}
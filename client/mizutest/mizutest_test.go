@@ -0,0 +1,125 @@
+package mizutest
+
+import (
+	"mizuflow/client"
+	v1 "mizuflow/pkg/api/v1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetAndEvaluate(t *testing.T) {
+	c := New()
+	c.Set("new-checkout", true)
+	c.Set("limit", 25)
+	c.Set("theme", map[string]string{"color": "red"})
+	c.SetIn("payments", "provider", "stripe")
+	c.Set("exp", v1.FeatureStrategy{
+		DefaultValue: "control",
+		Rules:        []v1.Rule{{Attribute: "country", Operator: "in", Values: []string{"JP"}, Result: "treatment"}},
+	})
+
+	var cl client.Client = c
+	if !cl.IsEnabled("new-checkout", nil) {
+		t.Error("IsEnabled() = false, want true")
+	}
+	if got := cl.GetNumber("limit", 0, nil); got != 25 {
+		t.Errorf("GetNumber() = %v, want 25", got)
+	}
+	if got := client.Get(cl, "theme", map[string]string{}, client.EvalContext{}); got["color"] != "red" {
+		t.Errorf("Get() = %v, want color red", got)
+	}
+	if got := cl.GetStringIn("payments", "provider", "", nil); got != "stripe" {
+		t.Errorf("GetStringIn() = %q, want stripe", got)
+	}
+	if got := cl.GetString("exp", "", map[string]string{"country": "JP"}); got != "treatment" {
+		t.Errorf("GetString() = %q, want treatment", got)
+	}
+	d := cl.EvaluateDetail("exp", client.ContextFromMap(map[string]string{"country": "US"}))
+	if d.Value != "control" || d.Reason != client.ReasonDefault {
+		t.Errorf("EvaluateDetail() = %+v, want control/DEFAULT", d)
+	}
+	if d := cl.EvaluateDetail("missing", client.EvalContext{}); d.Found() {
+		t.Errorf("EvaluateDetail() on a missing flag = %+v", d)
+	}
+
+	c.Set("new-payments", v1.FeatureStrategy{
+		DefaultValue:  "off",
+		Prerequisites: []v1.Prerequisite{{Key: "new-checkout", Value: "true"}},
+		Rules:         []v1.Rule{{Attribute: "country", Operator: "in", Values: []string{"JP"}, Result: "on"}},
+	})
+	if got := cl.GetString("new-payments", "", map[string]string{"country": "JP"}); got != "on" {
+		t.Errorf("GetString() with prerequisite met = %q, want on", got)
+	}
+	c.Set("new-checkout", false)
+	if d := cl.EvaluateDetail("new-payments", client.ContextFromMap(map[string]string{"country": "JP"})); d.Value != "off" || d.Reason != client.ReasonPrerequisiteFailed {
+		t.Errorf("EvaluateDetail() with prerequisite failed = %+v", d)
+	}
+}
+
+func TestChangeHooks(t *testing.T) {
+	c := New()
+	c.Set("limit", 1)
+
+	var changes []string
+	unsubscribe := c.OnChange("limit", func(old, new v1.FeatureFlag) {
+		changes = append(changes, old.Value+"->"+new.Value)
+	})
+	var anyCount int
+	c.OnAnyChange(func(old, new v1.FeatureFlag) { anyCount++ })
+
+	c.Set("limit", 2)
+	c.Set("other", "x")
+	c.Delete("limit")
+	unsubscribe()
+	c.Set("limit", 3)
+
+	if len(changes) != 2 || changes[0] != "1->2" || changes[1] != "2->" {
+		t.Errorf("OnChange() saw %v, want [1->2 2->]", changes)
+	}
+	if anyCount != 4 {
+		t.Errorf("OnAnyChange() called %d times, want 4", anyCount)
+	}
+}
+
+func TestFixture(t *testing.T) {
+	dir := t.TempDir()
+	yamlFixture := `
+flags:
+  - key: new-checkout
+    value: true
+  - key: limit
+    value: 10
+  - key: exp
+    namespace: payments
+    type: strategy
+    value:
+      default_value: control
+      rules:
+        - {attribute: country, operator: in, value: [JP], result: treatment}
+`
+	jsonFixture := `{"flags":[{"key":"new-checkout","value":true},{"key":"limit","value":10},
+		{"key":"exp","namespace":"payments","type":"strategy","value":{"default_value":"control","rules":[{"attribute":"country","operator":"in","value":["JP"],"result":"treatment"}]}}]}`
+
+	for name, content := range map[string]string{"flags.yaml": yamlFixture, "flags.json": jsonFixture} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := NewFromFixture(path)
+			if err != nil {
+				t.Fatalf("NewFromFixture() error = %v", err)
+			}
+			if !c.IsEnabled("new-checkout", nil) {
+				t.Error("IsEnabled() = false, want true")
+			}
+			if got := c.GetNumber("limit", 0, nil); got != 10 {
+				t.Errorf("GetNumber() = %v, want 10", got)
+			}
+			if got := c.GetStringIn("payments", "exp", "", map[string]string{"country": "JP"}); got != "treatment" {
+				t.Errorf("GetStringIn() = %q, want treatment", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// Package ofprovider adapts client.MizuClient to the OpenFeature Go SDK.
+package ofprovider
+
+import (
+	"context"
+	"fmt"
+	"mizuflow/client"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"sync"
+	"time"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+	"go.uber.org/zap"
+)
+
+const providerName = "MizuFlow"
+
+// Provider is an OpenFeature provider backed by a MizuClient. The provider owns
+// the client: Init starts it and Shutdown closes it, so pass a client that has
+// not been started.
+type Provider struct {
+	client      *client.MizuClient
+	namespace   string
+	initTimeout time.Duration
+
+	events chan of.Event
+
+	mu     sync.Mutex
+	stale  bool
+	cancel []func()
+}
+
+var (
+	_ of.FeatureProvider          = (*Provider)(nil)
+	_ of.ContextAwareStateHandler = (*Provider)(nil)
+	_ of.EventHandler             = (*Provider)(nil)
+)
+
+type Option func(*Provider)
+
+// WithNamespace evaluates flags of namespace instead of the client's default namespace.
+func WithNamespace(namespace string) Option {
+	return func(p *Provider) {
+		p.namespace = namespace
+	}
+}
+
+// WithInitTimeout bounds how long Init waits for the first sync, 10s by default.
+func WithInitTimeout(d time.Duration) Option {
+	return func(p *Provider) {
+		p.initTimeout = d
+	}
+}
+
+func New(c *client.MizuClient, opts ...Option) *Provider {
+	p := &Provider{
+		client:      c,
+		initTimeout: 10 * time.Second,
+		events:      make(chan of.Event, 64),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *Provider) Metadata() of.Metadata {
+	return of.Metadata{Name: providerName}
+}
+
+func (p *Provider) Hooks() []of.Hook {
+	return nil
+}
+
+func (p *Provider) EventChannel() <-chan of.Event {
+	return p.events
+}
+
+func (p *Provider) Init(evaluationContext of.EvaluationContext) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.initTimeout)
+	defer cancel()
+	return p.InitWithContext(ctx, evaluationContext)
+}
+
+// InitWithContext starts the client and waits for its first sync. A client that
+// can only serve its cache file comes up stale rather than failing.
+func (p *Provider) InitWithContext(ctx context.Context, _ of.EvaluationContext) error {
+	p.mu.Lock()
+	p.cancel = append(p.cancel, p.client.OnStateChange(p.stateChanged))
+	p.mu.Unlock()
+
+	if err := p.client.Start(); err != nil {
+		return err
+	}
+	// subscribed after the initial load, which the SDK announces as ready rather than as changes
+	p.mu.Lock()
+	p.cancel = append(p.cancel, p.client.OnAnyChange(p.flagChanged))
+	p.mu.Unlock()
+	if p.client.State() == client.StateStaleCache {
+		p.stateChanged(client.StateStaleCache)
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.initTimeout)
+		defer cancel()
+	}
+	if err := p.client.WaitForReady(ctx); err != nil {
+		return fmt.Errorf("mizuflow provider not ready: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.initTimeout)
+	defer cancel()
+	_ = p.ShutdownWithContext(ctx)
+}
+
+func (p *Provider) ShutdownWithContext(ctx context.Context) error {
+	p.mu.Lock()
+	for _, cancel := range p.cancel {
+		cancel()
+	}
+	p.cancel = nil
+	p.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- p.client.Close() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stateChanged reports a lost server as stale and its return as ready. The
+// first ready event is sent by the OpenFeature SDK once Init returns.
+func (p *Provider) stateChanged(s client.State) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch s {
+	case client.StateStaleCache, client.StateDisconnected:
+		if !p.stale {
+			p.stale = true
+			p.emit(of.ProviderStale, of.ProviderEventDetails{Message: "serving flags without a live connection: " + s.String()})
+		}
+	case client.StateLive:
+		if p.stale {
+			p.stale = false
+			p.emit(of.ProviderReady, of.ProviderEventDetails{Message: "connection to the server restored"})
+		}
+	}
+}
+
+func (p *Provider) flagChanged(old, new v1.FeatureFlag) {
+	if new.Namespace != p.evalNamespace() {
+		return
+	}
+	p.emit(of.ProviderConfigChange, of.ProviderEventDetails{
+		Message:     "flag changed",
+		FlagChanges: []string{new.Key},
+		EventMetadata: map[string]any{
+			"revision": new.Revision,
+		},
+	})
+}
+
+func (p *Provider) emit(t of.EventType, details of.ProviderEventDetails) {
+	select {
+	case p.events <- of.Event{ProviderName: providerName, EventType: t, ProviderEventDetails: details}:
+	default:
+		logger.Warn("openfeature event channel full, dropping event", zap.String("type", string(t)))
+	}
+}
+
+func (p *Provider) evalNamespace() string {
+	if p.namespace != "" {
+		return p.namespace
+	}
+	return p.client.DefaultNamespace()
+}
+
+func (p *Provider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, flatCtx of.FlattenedContext) of.BoolResolutionDetail {
+	v, detail := client.GetDetailIn(p.client, p.evalNamespace(), flag, defaultValue, evalContext(flatCtx))
+	return of.BoolResolutionDetail{Value: v, ProviderResolutionDetail: resolution(detail)}
+}
+
+func (p *Provider) StringEvaluation(ctx context.Context, flag string, defaultValue string, flatCtx of.FlattenedContext) of.StringResolutionDetail {
+	v, detail := client.GetDetailIn(p.client, p.evalNamespace(), flag, defaultValue, evalContext(flatCtx))
+	return of.StringResolutionDetail{Value: v, ProviderResolutionDetail: resolution(detail)}
+}
+
+func (p *Provider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, flatCtx of.FlattenedContext) of.FloatResolutionDetail {
+	v, detail := client.GetDetailIn(p.client, p.evalNamespace(), flag, defaultValue, evalContext(flatCtx))
+	return of.FloatResolutionDetail{Value: v, ProviderResolutionDetail: resolution(detail)}
+}
+
+func (p *Provider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, flatCtx of.FlattenedContext) of.IntResolutionDetail {
+	v, detail := client.GetDetailIn(p.client, p.evalNamespace(), flag, defaultValue, evalContext(flatCtx))
+	return of.IntResolutionDetail{Value: v, ProviderResolutionDetail: resolution(detail)}
+}
+
+// ObjectEvaluation decodes JSON values into maps, slices and scalars. The
+// decoded value is cached per flag revision and must not be modified.
+func (p *Provider) ObjectEvaluation(ctx context.Context, flag string, defaultValue any, flatCtx of.FlattenedContext) of.InterfaceResolutionDetail {
+	v, detail := client.GetDetailIn(p.client, p.evalNamespace(), flag, defaultValue, evalContext(flatCtx))
+	return of.InterfaceResolutionDetail{Value: v, ProviderResolutionDetail: resolution(detail)}
+}
+
+// evalContext maps the OpenFeature targeting key onto the MizuFlow targeting
+// key; every other attribute is passed through with its type.
+func evalContext(flatCtx of.FlattenedContext) client.EvalContext {
+	ec := client.EvalContext{Attributes: make(map[string]any, len(flatCtx))}
+	for k, v := range flatCtx {
+		if k == of.TargetingKey {
+			if s, ok := v.(string); ok {
+				ec.TargetingKey = s
+			}
+			continue
+		}
+		ec.Attributes[k] = v
+	}
+	return ec
+}
+
+func resolution(detail client.EvaluationDetail) of.ProviderResolutionDetail {
+	res := of.ProviderResolutionDetail{
+		Variant: detail.RuleID,
+		FlagMetadata: of.FlagMetadata{
+			"namespace":  detail.Namespace,
+			"version":    detail.Version,
+			"revision":   detail.Revision,
+			"rule_index": detail.RuleIndex,
+		},
+	}
+	switch detail.Reason {
+	case client.ReasonTargetMatch:
+		res.Reason = of.TargetingMatchReason
+	case client.ReasonDefault:
+		res.Reason = of.DefaultReason
+	case client.ReasonFlagNotFound:
+		res.Reason = of.ErrorReason
+		res.ResolutionError = of.NewFlagNotFoundResolutionError(fmt.Sprintf("flag %s/%s not found", detail.Namespace, detail.Key))
+	case client.ReasonParseError:
+		res.Reason = of.ErrorReason
+		res.ResolutionError = of.NewParseErrorResolutionError("flag strategy could not be parsed")
+	case client.ReasonTypeMismatch:
+		res.Reason = of.ErrorReason
+		res.ResolutionError = of.NewTypeMismatchResolutionError("flag value does not match the requested type")
+	case client.ReasonOverride, client.ReasonPrerequisiteFailed:
+		// OpenFeature has no reason for these, keep ours
+		res.Reason = of.Reason(detail.Reason)
+	default:
+		res.Reason = of.UnknownReason
+	}
+	return res
+}
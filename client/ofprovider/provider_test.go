@@ -0,0 +1,117 @@
+package ofprovider
+
+import (
+	"context"
+	"fmt"
+	"mizuflow/client"
+	"mizuflow/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	of "github.com/open-feature/go-sdk/openfeature"
+)
+
+func init() {
+	logger.InitLogger("test")
+}
+
+const snapshotBody = `{"data":[
+	{"namespace":"default","env":"dev","key":"new-checkout","value":"{\"default_value\":\"false\",\"rules\":[{\"id\":\"jp\",\"attribute\":\"country\",\"operator\":\"in\",\"value\":[\"JP\"],\"result\":\"true\"}]}","type":"strategy","version":1,"revision":1},
+	{"namespace":"default","env":"dev","key":"limit","value":"7","type":"number","version":1,"revision":2},
+	{"namespace":"default","env":"dev","key":"theme","value":"{\"color\":\"red\"}","type":"json","version":1,"revision":3}
+],"revision":3}`
+
+func TestProvider(t *testing.T) {
+	updates := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/stream/snapshot":
+			w.Write([]byte(snapshotBody))
+		case "/v1/stream/watch":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case msg := <-updates:
+					fmt.Fprintf(w, "event: update\ndata: %s\n\n", msg)
+					w.(http.Flusher).Flush()
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+
+	mc := client.NewMizuClient(srv.URL, "dev", "", []string{"default"}, client.WithCacheFile(filepath.Join(t.TempDir(), "cache.json")))
+	p := New(mc, WithInitTimeout(2*time.Second))
+	if err := of.SetProviderAndWait(p); err != nil {
+		t.Fatalf("SetProviderAndWait() error = %v", err)
+	}
+	defer of.Shutdown()
+
+	changed := make(chan []string, 4)
+	onChange := func(details of.EventDetails) { changed <- details.FlagChanges }
+	of.AddHandler(of.ProviderConfigChange, &onChange)
+
+	ofc := of.NewClient("test")
+	ctx := context.Background()
+	jp := of.NewEvaluationContext("user-1", map[string]any{"country": "JP"})
+
+	b, err := ofc.BooleanValueDetails(ctx, "new-checkout", false, jp)
+	if err != nil || !b.Value || b.Reason != of.TargetingMatchReason || b.Variant != "jp" {
+		t.Errorf("BooleanValueDetails() = %+v, %v", b, err)
+	}
+	b, _ = ofc.BooleanValueDetails(ctx, "new-checkout", true, of.NewEvaluationContext("user-2", map[string]any{"country": "US"}))
+	if b.Value || b.Reason != of.DefaultReason {
+		t.Errorf("BooleanValueDetails() default = %+v", b)
+	}
+	if f, err := ofc.FloatValue(ctx, "limit", 0, jp); err != nil || f != 7 {
+		t.Errorf("FloatValue() = %v, %v", f, err)
+	}
+	if i, err := ofc.IntValue(ctx, "limit", 0, jp); err != nil || i != 7 {
+		t.Errorf("IntValue() = %v, %v", i, err)
+	}
+	o, err := ofc.ObjectValue(ctx, "theme", nil, jp)
+	if m, ok := o.(map[string]any); err != nil || !ok || m["color"] != "red" {
+		t.Errorf("ObjectValue() = %v, %v", o, err)
+	}
+
+	s, err := ofc.StringValueDetails(ctx, "missing", "fallback", jp)
+	if err == nil || s.Value != "fallback" || s.ErrorCode != of.FlagNotFoundCode {
+		t.Errorf("StringValueDetails() on a missing flag = %+v, %v", s, err)
+	}
+	n, err := ofc.IntValueDetails(ctx, "theme", 3, jp)
+	if err == nil || n.Value != 3 || n.ErrorCode != of.TypeMismatchCode {
+		t.Errorf("IntValueDetails() on JSON = %+v, %v", n, err)
+	}
+
+	updates <- `{"namespace":"default","env":"dev","key":"limit","value":"9","type":"number","version":2,"revision":4,"action":1}`
+	select {
+	case keys := <-changed:
+		if len(keys) != 1 || keys[0] != "limit" {
+			t.Errorf("config change flags = %v, want [limit]", keys)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the config change event")
+	}
+	if f, _ := ofc.FloatValue(ctx, "limit", 0, jp); f != 9 {
+		t.Errorf("FloatValue() after update = %v, want 9", f)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	ec := evalContext(of.FlattenedContext{of.TargetingKey: "user-1", "age": int64(30), "tags": []any{"a", "b"}})
+	if ec.TargetingKey != "user-1" {
+		t.Errorf("TargetingKey = %q, want user-1", ec.TargetingKey)
+	}
+	if _, ok := ec.Attributes[of.TargetingKey]; ok {
+		t.Error("targeting key should not be copied into the attributes")
+	}
+	if ec.Attributes["age"] != int64(30) {
+		t.Errorf("age = %v, want typed 30", ec.Attributes["age"])
+	}
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"mizuflow/pkg/logger"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// OverrideEnvPrefix prefixes the environment variables that override a flag,
+	// e.g. MIZU_OVERRIDE_NEW_CHECKOUT=true overrides new-checkout.
+	OverrideEnvPrefix    = "MIZU_OVERRIDE_"
+	overridePollInterval = time.Second
+)
+
+// overrideSource holds the developer overrides. Environment variables are read
+// once when the option is applied; the file is reloaded whenever it changes.
+type overrideSource struct {
+	path    string
+	env     map[string]string // overrideEnvKey(key) -> value
+	file    atomic.Pointer[map[flagKey]string]
+	refused bool
+
+	// stamp of the loaded file, only touched by Start and the reload loop
+	modTime time.Time
+	size    int64
+}
+
+// WithLocalOverrides serves local values instead of the server's, for
+// debugging against a shared environment without changing it. Values come
+// from MIZU_OVERRIDE_<KEY> environment variables, where KEY is the flag key
+// upper-cased with every other character replaced by '_', and from the JSON
+// file at path, if any, which maps "key" or "namespace/key" to a value and is
+// reloaded when it changes. Environment variables win over the file and both
+// win over the server in every namespace they match.
+//
+// Overrides are meant for development only: every active override is logged
+// at Start, and the option is refused when the client runs against env "prod".
+func WithLocalOverrides(path string) Option {
+	return func(c *MizuClient) {
+		o := &overrideSource{path: path, env: make(map[string]string)}
+		for _, kv := range os.Environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			if key, ok := strings.CutPrefix(name, OverrideEnvPrefix); ok && key != "" {
+				o.env[key] = value
+			}
+		}
+		o.refused = c.env == "prod"
+		c.overrides = o
+	}
+}
+
+// overrideEnvKey maps a flag key to the suffix of its environment variable.
+func overrideEnvKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, key)
+}
+
+func (o *overrideSource) lookup(namespace, key string) (string, bool) {
+	if o == nil || o.refused {
+		return "", false
+	}
+	if len(o.env) > 0 {
+		if v, ok := o.env[overrideEnvKey(key)]; ok {
+			return v, true
+		}
+	}
+	if file := o.file.Load(); file != nil {
+		if v, ok := (*file)[flagKey{namespace: namespace, key: key}]; ok {
+			return v, true
+		}
+		if v, ok := (*file)[flagKey{key: key}]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// readOverrideFile parses the override file. Non-string values are kept in
+// their JSON form, so {"limit": 5} serves "5" and {"theme": {...}} serves the object.
+func readOverrideFile(path string) (map[flagKey]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	out := make(map[flagKey]string, len(raw))
+	for name, value := range raw {
+		k := flagKey{key: name}
+		if ns, key, ok := strings.Cut(name, "/"); ok {
+			k = flagKey{namespace: ns, key: key}
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			s = string(value)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
+
+// reloadOverrides rereads the override file. A file that fails to parse keeps
+// the previous overrides; a missing file clears them.
+func (c *MizuClient) reloadOverrides() error {
+	o := c.overrides
+	if o.path == "" {
+		return nil
+	}
+	o.modTime, o.size = time.Time{}, 0
+	if info, err := os.Stat(o.path); err == nil {
+		o.modTime, o.size = info.ModTime(), info.Size()
+	}
+	file, err := readOverrideFile(o.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = map[flagKey]string{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	o.file.Store(&file)
+	return nil
+}
+
+// startOverrides loads the override file and logs every active override, so
+// nobody mistakes an overridden value for the server's.
+func (c *MizuClient) startOverrides() {
+	o := c.overrides
+	if o.refused {
+		logger.Error("local overrides are not allowed in prod, ignoring them", zap.String("env", c.env))
+		return
+	}
+	if err := c.reloadOverrides(); err != nil {
+		logger.Error("failed to load override file", zap.String("path", o.path), zap.Error(err))
+	}
+
+	var active []string
+	for key, value := range o.env {
+		active = append(active, OverrideEnvPrefix+key+"="+value)
+	}
+	if file := o.file.Load(); file != nil {
+		for k, value := range *file {
+			name := k.key
+			if k.namespace != "" {
+				name = k.namespace + "/" + k.key
+			}
+			active = append(active, name+"="+value)
+		}
+	}
+	slices.Sort(active)
+	logger.Warn("LOCAL OVERRIDES ENABLED: these flags ignore server values", zap.String("env", c.env), zap.String("file", o.path), zap.Strings("overrides", active))
+}
+
+// runOverrideLoop reloads the override file when its modification time or size changes.
+func (c *MizuClient) runOverrideLoop() {
+	o := c.overrides
+	ticker := time.NewTicker(overridePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(o.path)
+			if err != nil && o.modTime.IsZero() {
+				continue
+			}
+			if err == nil && info.ModTime().Equal(o.modTime) && info.Size() == o.size {
+				continue
+			}
+			if err := c.reloadOverrides(); err != nil {
+				logger.Error("failed to reload override file, keeping previous overrides", zap.String("path", o.path), zap.Error(err))
+				continue
+			}
+			logger.Warn("local overrides reloaded", zap.String("path", o.path), zap.Int("overrides", len(*o.file.Load())))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Transport selects how the client follows flag changes.
+type Transport int
+
+const (
+	// TransportAuto streams over SSE and polls while SSE keeps failing.
+	TransportAuto Transport = iota
+	// TransportSSE only streams over SSE.
+	TransportSSE
+	// TransportPolling only polls the snapshot endpoint.
+	TransportPolling
+)
+
+// sseFailureThreshold is the number of consecutive SSE connections that deliver
+// nothing before TransportAuto falls back to polling.
+const sseFailureThreshold = 3
+
+// defaultPollTimeout bounds a poll when the HTTP client has no timeout of its own.
+const defaultPollTimeout = 30 * time.Second
+
+func WithTransport(t Transport) Option {
+	return func(c *MizuClient) {
+		c.transport = t
+	}
+}
+
+// WithPolling sets the poll interval and, for TransportAuto, how long to poll
+// before trying SSE again.
+func WithPolling(interval, retrySSEAfter time.Duration) Option {
+	return func(c *MizuClient) {
+		c.pollInterval = interval
+		c.sseRetryAfter = retrySSEAfter
+	}
+}
+
+// runPolling polls until ctx is done or, when d > 0, until d has passed.
+func (c *MizuClient) runPolling(d time.Duration) {
+	var deadline <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		addr := c.endpoints.get()
+		if err := c.poll(addr); err != nil {
+			c.endpoints.failure(addr)
+			c.markDisconnected()
+			logger.Warn("failed to poll features", zap.String("endpoint", addr), zap.Error(err))
+		} else {
+			c.endpoints.success(addr)
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollTimeout leaves the HTTP client's own timeout the first to fire; a zero
+// Timeout means none, not an immediate deadline.
+func (c *MizuClient) pollTimeout() time.Duration {
+	if c.httpClient.Timeout > 0 {
+		return c.httpClient.Timeout + time.Second
+	}
+	return defaultPollTimeout
+}
+
+// poll asks for the changes after the last seen revision. The ETag of the last
+// response makes an unchanged server answer 304 without a body.
+func (c *MizuClient) poll(addr string) error {
+	c.mu.RLock()
+	endpoint := fmt.Sprintf("%s/v1/stream/snapshot?env=%s&namespace=%s&since_rev=%d",
+		addr, url.QueryEscape(c.env), url.QueryEscape(strings.Join(c.namespaces, ",")), c.lastRev)
+	etag := c.etag
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.pollTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Mizu-Key", c.apiKey)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		c.markSynced()
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Data        []v1.FeatureFlag `json:"data"`
+		Changes     []v1.Message     `json:"changes"`
+		Incremental bool             `json:"incremental"`
+		Revision    int64            `json:"revision"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if res.Incremental {
+		for _, msg := range res.Changes {
+			c.handleUpdate(msg)
+		}
+		c.mu.Lock()
+		if res.Revision > c.lastRev {
+			c.lastRev = res.Revision
+		}
+		c.etag = resp.Header.Get("ETag")
+		c.mu.Unlock()
+	} else {
+		c.replaceFeatures(res.Data, res.Revision, resp.Header.Get("ETag"))
+	}
+	c.markSynced()
+	return nil
+}
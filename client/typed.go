@@ -0,0 +1,155 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"mizuflow/pkg/logger"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxDecodedValues bounds the decoded values kept per flag revision; a strategy
+// can serve many distinct values.
+const maxDecodedValues = 64
+
+// Get evaluates a flag of the default namespace and decodes it into T. Strings,
+// booleans, numbers and time.Duration are parsed from their text form, anything
+// else is decoded as JSON. The default is returned when the flag is missing or
+// its value does not decode into T.
+//
+// Decoded values are cached per flag revision and shared between callers, so
+// maps, slices and pointers inside T must be treated as read-only.
+func Get[T any](c Client, key string, defaultValue T, ctx EvalContext) T {
+	v, _ := GetDetail(c, key, defaultValue, ctx)
+	return v
+}
+
+func GetIn[T any](c Client, namespace, key string, defaultValue T, ctx EvalContext) T {
+	v, _ := GetDetailIn(c, namespace, key, defaultValue, ctx)
+	return v
+}
+
+// GetDetail is Get with the evaluation detail. A value that does not decode
+// into T is reported as TYPE_MISMATCH.
+func GetDetail[T any](c Client, key string, defaultValue T, ctx EvalContext) (T, EvaluationDetail) {
+	return decodeDetail(c, c.EvaluateDetail(key, ctx), defaultValue)
+}
+
+func GetDetailIn[T any](c Client, namespace, key string, defaultValue T, ctx EvalContext) (T, EvaluationDetail) {
+	return decodeDetail(c, c.EvaluateDetailIn(namespace, key, ctx), defaultValue)
+}
+
+func decodeDetail[T any](c Client, detail EvaluationDetail, defaultValue T) (T, EvaluationDetail) {
+	if !detail.Found() || detail.Reason == ReasonParseError {
+		return defaultValue, detail
+	}
+	var cache *decodeCache
+	if mc, ok := c.(*MizuClient); ok {
+		cache = mc.decodeCache(detail)
+	}
+	if cache == nil {
+		v, err := decodeValue[T](detail.Value)
+		if err != nil {
+			detail.Reason = ReasonTypeMismatch
+			return defaultValue, detail
+		}
+		return v, detail
+	}
+
+	ck := decodeKey{typ: reflect.TypeFor[T](), raw: detail.Value}
+	if v, ok := cache.values.Load(ck); ok {
+		if _, failed := v.(decodeFailure); failed {
+			detail.Reason = ReasonTypeMismatch
+			return defaultValue, detail
+		}
+		return v.(T), detail
+	}
+	v, err := decodeValue[T](detail.Value)
+	if err != nil {
+		// logged once per revision, the failure is cached like a value
+		logger.Warn("feature value does not decode into the requested type", zap.String("namespace", detail.Namespace), zap.String("key", detail.Key), zap.String("type", ck.typ.String()), zap.Error(err))
+		cache.store(ck, decodeFailure{})
+		detail.Reason = ReasonTypeMismatch
+		return defaultValue, detail
+	}
+	cache.store(ck, v)
+	return v, detail
+}
+
+type decodeKey struct {
+	typ reflect.Type
+	raw string
+}
+
+// decodeCache holds the decoded values of one flag revision. It lives in the
+// compiled flag, so it is dropped with it when the revision changes.
+type decodeCache struct {
+	values sync.Map // decodeKey -> T
+	size   atomic.Int32
+}
+
+// decodeFailure marks a value that does not decode into the requested type.
+type decodeFailure struct{}
+
+func (d *decodeCache) store(key decodeKey, v any) {
+	if d.size.Add(1) <= maxDecodedValues {
+		d.values.Store(key, v)
+	}
+}
+
+// decodeCache returns the cache of the compiled flag that produced detail, or
+// nil if the flag changed since.
+func (c *MizuClient) decodeCache(detail EvaluationDetail) *decodeCache {
+	cf, ok := c.lookupCompiled(detail.Namespace, detail.Key)
+	if !ok || cf.flag.Revision != detail.Revision || cf.flag.Version != detail.Version {
+		return nil
+	}
+	return &cf.decoded
+}
+
+func decodeValue[T any](raw string) (T, error) {
+	var out T
+	var err error
+	switch p := any(&out).(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		*p, err = strconv.ParseBool(raw)
+	case *int:
+		var n int64
+		n, err = strconv.ParseInt(raw, 10, 0)
+		*p = int(n)
+	case *int64:
+		*p, err = strconv.ParseInt(raw, 10, 64)
+	case *int32:
+		var n int64
+		n, err = strconv.ParseInt(raw, 10, 32)
+		*p = int32(n)
+	case *uint:
+		var n uint64
+		n, err = strconv.ParseUint(raw, 10, 0)
+		*p = uint(n)
+	case *uint64:
+		*p, err = strconv.ParseUint(raw, 10, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(raw, 64)
+	case *float32:
+		var f float64
+		f, err = strconv.ParseFloat(raw, 32)
+		*p = float32(f)
+	case *time.Duration:
+		*p, err = time.ParseDuration(raw)
+	default:
+		err = json.Unmarshal([]byte(raw), &out)
+	}
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("decode %q: %w", raw, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,781 @@
+// Command mizuctl manages feature flags through the MizuFlow control plane.
+//
+//	mizuctl login -u alice
+//	mizuctl list -env prod -n payments
+//	mizuctl set checkout-v2 true -type bool
+//	mizuctl set checkout-v2 false -expect 4
+//	mizuctl set checkout-v2 -type strategy -f strategy.json
+//	mizuctl set checkout-v2 true -env prod -reason "launch, see INC-42"
+//	mizuctl approve 7 -m "looks good"
+//	mizuctl apply 7
+//	mizuctl meta checkout-v2 -owner payments -tags checkout,q3 -expires 2026-12-31
+//	mizuctl schedule black-friday-banner true -at 2026-11-27T00:00:00+09:00
+//	mizuctl rollout checkout-v2 -rule canary -steps 1:6h,5:6h,25:12h,100
+//	mizuctl history checkout-v2
+//	mizuctl rollback checkout-v2 -audit 42
+//	mizuctl tail -env prod
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"mizuflow/internal/ctl"
+	"mizuflow/internal/dto/req"
+)
+
+const usage = `usage: mizuctl <command> [flags] [args]
+
+commands:
+  login                 log in and keep a session for the other commands
+  logout                end the session
+  list                  list flags
+  get KEY               show a flag
+  set KEY [VALUE]       create or update a flag, -f reads the value from a file
+  meta KEY              edit description, owner, tags and expiry of a flag
+  rollback KEY          restore a flag to the value of an audit entry
+  delete KEY            archive a flag, -hard deletes it for good
+  restore KEY           bring an archived flag back
+  schedule KEY [VALUE]  write a flag value later, at -at
+  schedules             list scheduled changes
+  unschedule ID         cancel a scheduled change
+  rollout KEY           raise the percentage of a mod rule step by step
+  rollouts              list rollout plans
+  rollout-pause ID      hold a rollout plan at its current step
+  rollout-resume ID     continue a paused rollout plan
+  rollout-abort ID      stop a rollout plan and restore the percentage
+  changes               list change requests of protected environments
+  change ID             show a change request with its diff
+  approve ID            approve a change request of someone else
+  reject ID             reject a change request, or withdraw your own
+  apply ID              write an approved change request
+  history KEY           show the audit history of a flag
+  tail                  follow flag changes as they are published
+
+Run mizuctl <command> -h for the flags of a command. -server, -env and -n
+default to MIZU_SERVER, MIZU_ENV and MIZU_NAMESPACE.
+`
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"login":          runLogin,
+	"logout":         runLogout,
+	"list":           runList,
+	"get":            runGet,
+	"set":            runSet,
+	"meta":           runMeta,
+	"rollback":       runRollback,
+	"delete":         runDelete,
+	"restore":        runRestore,
+	"schedule":       runSchedule,
+	"schedules":      runSchedules,
+	"unschedule":     runUnschedule,
+	"rollout":        runRollout,
+	"rollouts":       runRollouts,
+	"rollout-pause":  rolloutAction("pause"),
+	"rollout-resume": rolloutAction("resume"),
+	"rollout-abort":  rolloutAction("abort"),
+	"changes":        runChanges,
+	"change":         runChange,
+	"approve":        reviewAction("approve"),
+	"reject":         reviewAction("reject"),
+	"apply":          runApply,
+	"history":        runHistory,
+	"tail":           runTail,
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "mizuctl: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd(ctx, os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "mizuctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// options are the flags every command shares.
+type options struct {
+	server    string
+	env       string
+	namespace string
+	output    string
+	session   string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet("mizuctl "+name, flag.ContinueOnError)
+	o := &options{}
+	fs.StringVar(&o.server, "server", envOr("MIZU_SERVER", "http://localhost:8080"), "control plane address")
+	fs.StringVar(&o.env, "env", envOr("MIZU_ENV", "dev"), "environment")
+	fs.StringVar(&o.namespace, "n", envOr("MIZU_NAMESPACE", "default"), "namespace")
+	fs.StringVar(&o.output, "o", "table", "output format, table or json")
+	fs.StringVar(&o.session, "session", ctl.DefaultSessionPath(), "session file")
+	return fs, o
+}
+
+// parse accepts flags before and after the positional arguments, which the
+// flag package alone stops at, and checks their count.
+func parse(fs *flag.FlagSet, args []string, positional ...string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		remaining := fs.Args()
+		if n := len(args) - len(remaining); n > 0 && args[n-1] == "--" {
+			// everything after -- is positional, e.g. a negative number
+			rest = append(rest, remaining...)
+			break
+		}
+		args = remaining
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	if len(rest) < len(positional) {
+		return nil, fmt.Errorf("missing %s", strings.Join(positional[len(rest):], " "))
+	}
+	return rest, nil
+}
+
+func (o *options) client() (*ctl.Client, error) {
+	if o.output != "table" && o.output != "json" {
+		return nil, fmt.Errorf("unknown output format %q", o.output)
+	}
+	return ctl.NewClient(o.server, o.session)
+}
+
+// expectedVersion turns an -expect flag into the request field, negative
+// meaning no check.
+func expectedVersion(v int) *int {
+	if v < 0 {
+		return nil
+	}
+	return &v
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func runLogin(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("login")
+	username := fs.String("u", os.Getenv("USER"), "username")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	password := os.Getenv("MIZU_PASSWORD")
+	if password == "" {
+		fmt.Fprintf(os.Stderr, "password for %s: ", *username)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if err := c.Login(ctx, *username, password); err != nil {
+		return err
+	}
+	fmt.Printf("logged in to %s as %s\n", o.server, c.Username())
+	return nil
+}
+
+func runLogout(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("logout")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	return c.Logout(ctx)
+}
+
+func runList(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("list")
+	var opts ctl.ListOptions
+	fs.StringVar(&opts.Search, "search", "", "only flags whose key contains this")
+	fs.StringVar(&opts.Owner, "owner", "", "only flags of this owner")
+	fs.StringVar(&opts.Tag, "tag", "", "only flags with this tag")
+	fs.BoolVar(&opts.IncludeArchived, "archived", false, "include archived flags")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	items, err := c.ListFeatures(ctx, o.namespace, o.env, opts)
+	if err != nil {
+		return err
+	}
+	return printFeatures(o.output, items)
+}
+
+func runGet(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("get")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	item, err := c.GetFeature(ctx, o.namespace, o.env, pos[0])
+	if err != nil {
+		return err
+	}
+	return printFeature(o.output, item)
+}
+
+func runSet(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("set")
+	typ := fs.String("type", "", "flag type: bool, string, number, json or strategy; defaults to the current type")
+	file := fs.String("f", "", "read the value from a file, - for stdin")
+	expect := fs.Int("expect", -1, "only write while the flag is at this version, 0 for a new flag")
+	reason := fs.String("reason", "", "why, for the approvers of a protected environment")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	key := pos[0]
+	value, err := readValue(pos[1:], *file)
+	if err != nil {
+		return err
+	}
+	if *typ == "" {
+		current, err := c.GetFeature(ctx, o.namespace, o.env, key)
+		var apiErr *ctl.APIError
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("-type is required for a new flag: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		*typ = current.Type
+	}
+	if value, err = ctl.NormalizeValue(*typ, value); err != nil {
+		return err
+	}
+
+	out, err := c.SetFeature(ctx, req.CreateFeatureRequest{
+		Namespace:       o.namespace,
+		Env:             o.env,
+		Key:             key,
+		Value:           value,
+		Type:            *typ,
+		ExpectedVersion: expectedVersion(*expect),
+		Reason:          *reason,
+	})
+	if err != nil {
+		return err
+	}
+	if out.ChangeRequest != nil {
+		return printProposed(o.output, out.ChangeRequest)
+	}
+	fmt.Printf("%s/%s/%s set, version %d\n", o.env, o.namespace, key, out.Version)
+	return nil
+}
+
+// runMeta edits the metadata of a flag; fields without a flag keep their value.
+func runMeta(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("meta")
+	description := fs.String("description", "", "what the flag is for")
+	owner := fs.String("owner", "", "user or team owning the flag")
+	tags := fs.String("tags", "", "comma separated tags, replacing the current ones")
+	expires := fs.String("expires", "", "date the flag should be removed by, YYYY-MM-DD or RFC 3339, none to clear")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	current, err := c.GetFeature(ctx, o.namespace, o.env, pos[0])
+	if err != nil {
+		return err
+	}
+	meta := req.FeatureMetadata{
+		Description: current.Description,
+		Owner:       current.Owner,
+		Tags:        current.Tags,
+		ExpiresAt:   current.ExpiresAt,
+	}
+	var parseErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "description":
+			meta.Description = *description
+		case "owner":
+			meta.Owner = *owner
+		case "tags":
+			meta.Tags = strings.Split(*tags, ",")
+		case "expires":
+			meta.ExpiresAt, parseErr = parseExpiry(*expires)
+		}
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+
+	item, err := c.UpdateMetadata(ctx, o.namespace, o.env, pos[0], meta)
+	if err != nil {
+		return err
+	}
+	return printFeature(o.output, item)
+}
+
+func parseExpiry(s string) (*time.Time, error) {
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+	t, err := parseTime(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -expires: %w", err)
+	}
+	return &t, nil
+}
+
+// parseTime reads RFC 3339, or a date with an optional time in local time.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time, want RFC 3339 or YYYY-MM-DD [HH:MM[:SS]]", s)
+}
+
+func runRollback(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("rollback")
+	auditID := fs.Uint64("audit", 0, "audit entry to restore, see mizuctl history")
+	expect := fs.Int("expect", -1, "only roll back while the flag is at this version")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	if *auditID == 0 {
+		return errors.New("-audit is required")
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	out, err := c.Rollback(ctx, o.namespace, o.env, pos[0], *auditID, expectedVersion(*expect))
+	if err != nil {
+		return err
+	}
+	if out.ChangeRequest != nil {
+		return printProposed(o.output, out.ChangeRequest)
+	}
+	fmt.Printf("%s/%s/%s rolled back to audit %d, version %d\n", o.env, o.namespace, pos[0], *auditID, out.Version)
+	return nil
+}
+
+func runDelete(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("delete")
+	hard := fs.Bool("hard", false, "delete the flag for good instead of archiving it")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	out, err := c.DeleteFeature(ctx, o.namespace, o.env, pos[0], *hard)
+	if err != nil {
+		return err
+	}
+	if out.ChangeRequest != nil {
+		return printProposed(o.output, out.ChangeRequest)
+	}
+	what := "archived"
+	if *hard {
+		what = "deleted"
+	}
+	fmt.Printf("%s/%s/%s %s, version %d\n", o.env, o.namespace, pos[0], what, out.Version)
+	return nil
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("restore")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	out, err := c.RestoreFeature(ctx, o.namespace, o.env, pos[0])
+	if err != nil {
+		return err
+	}
+	if out.ChangeRequest != nil {
+		return printProposed(o.output, out.ChangeRequest)
+	}
+	fmt.Printf("%s/%s/%s restored, version %d\n", o.env, o.namespace, pos[0], out.Version)
+	return nil
+}
+
+func runSchedule(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("schedule")
+	at := fs.String("at", "", "when to write, RFC 3339 or YYYY-MM-DD HH:MM in local time")
+	typ := fs.String("type", "", "flag type, defaults to the current type")
+	file := fs.String("f", "", "read the value from a file, - for stdin")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	if *at == "" {
+		return errors.New("-at is required")
+	}
+	runAt, err := parseTime(*at)
+	if err != nil {
+		return fmt.Errorf("invalid -at: %w", err)
+	}
+	value, err := readValue(pos[1:], *file)
+	if err != nil {
+		return err
+	}
+	if *typ != "" {
+		if value, err = ctl.NormalizeValue(*typ, value); err != nil {
+			return err
+		}
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	out, err := c.CreateSchedule(ctx, req.CreateScheduleRequest{
+		Namespace: o.namespace,
+		Env:       o.env,
+		Key:       pos[0],
+		Value:     value,
+		Type:      *typ,
+		RunAt:     runAt,
+	})
+	if err != nil {
+		return err
+	}
+	if out.ChangeRequest != nil {
+		return printProposed(o.output, out.ChangeRequest)
+	}
+	item := out.ScheduleItem
+	if o.output == "json" {
+		return printJSON(item)
+	}
+	fmt.Printf("%s/%s/%s scheduled for %s, id %d\n", o.env, o.namespace, pos[0], item.RunAt.Local().Format(time.DateTime), item.ID)
+	return nil
+}
+
+func runSchedules(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("schedules")
+	key := fs.String("key", "", "only changes of this flag")
+	all := fs.Bool("all", false, "include applied, failed and canceled changes")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	items, err := c.ListSchedules(ctx, o.namespace, o.env, *key, *all)
+	if err != nil {
+		return err
+	}
+	return printSchedules(o.output, items)
+}
+
+func runUnschedule(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("unschedule")
+	pos, err := parse(fs, args, "ID")
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(pos[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", pos[0])
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	if err := c.CancelSchedule(ctx, id); err != nil {
+		return err
+	}
+	fmt.Printf("scheduled change %d canceled\n", id)
+	return nil
+}
+
+func runRollout(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("rollout")
+	rule := fs.String("rule", "", "id of the strategy rule with the mod operator")
+	stepsFlag := fs.String("steps", "", "percentages and how long each holds, e.g. 1:6h,5:6h,25:12h,100")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	if *rule == "" || *stepsFlag == "" {
+		return errors.New("-rule and -steps are required")
+	}
+	steps, err := parseSteps(*stepsFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -steps: %w", err)
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	out, err := c.CreateRollout(ctx, req.CreateRolloutRequest{
+		Namespace: o.namespace,
+		Env:       o.env,
+		Key:       pos[0],
+		RuleID:    *rule,
+		Steps:     steps,
+	})
+	if err != nil {
+		return err
+	}
+	if out.ChangeRequest != nil {
+		return printProposed(o.output, out.ChangeRequest)
+	}
+	item := out.RolloutItem
+	if o.output == "json" {
+		return printJSON(item)
+	}
+	fmt.Printf("%s/%s/%s rollout %d started at %d%%\n", o.env, o.namespace, pos[0], item.ID, item.Percentage)
+	return nil
+}
+
+// parseSteps reads PERCENTAGE:DURATION pairs, the duration of the last step
+// being optional.
+func parseSteps(s string) ([]req.RolloutStep, error) {
+	var steps []req.RolloutStep
+	for part := range strings.SplitSeq(s, ",") {
+		pct, duration, _ := strings.Cut(strings.TrimSpace(part), ":")
+		n, err := strconv.Atoi(strings.TrimSuffix(pct, "%"))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a percentage", pct)
+		}
+		steps = append(steps, req.RolloutStep{Percentage: n, Duration: duration})
+	}
+	return steps, nil
+}
+
+func runRollouts(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("rollouts")
+	key := fs.String("key", "", "only plans of this flag")
+	all := fs.Bool("all", false, "include completed, aborted and failed plans")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	items, err := c.ListRollouts(ctx, o.namespace, o.env, *key, *all)
+	if err != nil {
+		return err
+	}
+	return printRollouts(o.output, items)
+}
+
+func rolloutAction(action string) command {
+	return func(ctx context.Context, args []string) error {
+		fs, o := newFlagSet("rollout-" + action)
+		pos, err := parse(fs, args, "ID")
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseUint(pos[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", pos[0])
+		}
+		c, err := o.client()
+		if err != nil {
+			return err
+		}
+		item, err := c.RolloutAction(ctx, id, action)
+		if err != nil {
+			return err
+		}
+		if o.output == "json" {
+			return printJSON(item)
+		}
+		fmt.Printf("rollout %d %s at %d%%\n", item.ID, item.Status, item.Percentage)
+		return nil
+	}
+}
+
+func runChanges(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("changes")
+	key := fs.String("key", "", "only requests for this flag")
+	all := fs.Bool("all", false, "include applied, rejected and failed requests")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	items, err := c.ListChangeRequests(ctx, o.namespace, o.env, *key, *all)
+	if err != nil {
+		return err
+	}
+	return printChangeRequests(o.output, items)
+}
+
+func runChange(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("change")
+	pos, err := parse(fs, args, "ID")
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(pos[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", pos[0])
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	item, err := c.GetChangeRequest(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printChangeRequest(o.output, item)
+}
+
+func reviewAction(action string) command {
+	return func(ctx context.Context, args []string) error {
+		fs, o := newFlagSet(action)
+		comment := fs.String("m", "", "comment")
+		pos, err := parse(fs, args, "ID")
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseUint(pos[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", pos[0])
+		}
+		c, err := o.client()
+		if err != nil {
+			return err
+		}
+		item, err := c.ReviewChangeRequest(ctx, id, action, *comment)
+		if err != nil {
+			return err
+		}
+		if o.output == "json" {
+			return printJSON(item)
+		}
+		fmt.Printf("change request %d %s, %d of %d approvals\n", item.ID, item.Status, len(item.Approvals), item.RequiredApprovals)
+		return nil
+	}
+}
+
+func runApply(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("apply")
+	pos, err := parse(fs, args, "ID")
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(pos[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", pos[0])
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	item, err := c.ApplyChangeRequest(ctx, id)
+	if err != nil {
+		return err
+	}
+	if o.output == "json" {
+		return printJSON(item)
+	}
+	fmt.Printf("change request %d applied, %s/%s/%s version %d\n", item.ID, item.Env, item.Namespace, item.Key, item.Version)
+	return nil
+}
+
+func runHistory(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("history")
+	pos, err := parse(fs, args, "KEY")
+	if err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	items, err := c.Audits(ctx, o.namespace, o.env, pos[0])
+	if err != nil {
+		return err
+	}
+	return printAudits(o.output, items)
+}
+
+func runTail(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("tail")
+	all := fs.Bool("all", false, "follow every environment instead of -env")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	env := o.env
+	if *all {
+		env = ""
+	}
+	return c.TailAdmin(ctx, env, messagePrinter(o.output))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mizuflow/internal/dto/resp"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+)
+
+// maxCell cuts long values, typically strategies, in table output; -o json
+// shows them whole.
+const maxCell = 60
+
+// readValue takes the value from the arguments or from file, - being stdin.
+func readValue(args []string, file string) (string, error) {
+	switch {
+	case file != "" && len(args) > 0:
+		return "", errors.New("give the value either as argument or with -f, not both")
+	case file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		return strings.TrimRight(string(data), "\r\n"), err
+	case file != "":
+		data, err := os.ReadFile(file)
+		return strings.TrimRight(string(data), "\r\n"), err
+	case len(args) == 1:
+		return args[0], nil
+	case len(args) == 0:
+		return "", errors.New("missing VALUE or -f")
+	}
+	return "", fmt.Errorf("unexpected arguments %v", args[1:])
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printFeatures(output string, items []resp.FeatureItem) error {
+	if output == "json" {
+		return printJSON(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tVERSION\tVALUE\tOWNER\tUPDATED BY\tUPDATED AT")
+	for _, f := range items {
+		key := f.Key
+		if f.Archived {
+			key += " (archived)"
+		} else if expired(&f) {
+			key += " (expired)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", key, f.Type, f.Version, cell(f.Value), f.Owner, f.UpdatedBy, f.UpdatedAt.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func printFeature(output string, f *resp.FeatureItem) error {
+	if output == "json" {
+		return printJSON(f)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Key:\t%s\n", f.Key)
+	fmt.Fprintf(w, "Namespace:\t%s\n", f.Namespace)
+	fmt.Fprintf(w, "Env:\t%s\n", f.Env)
+	fmt.Fprintf(w, "Type:\t%s\n", f.Type)
+	fmt.Fprintf(w, "Version:\t%d\n", f.Version)
+	if f.Archived {
+		fmt.Fprintf(w, "Status:\tarchived\n")
+	}
+	if f.Description != "" {
+		fmt.Fprintf(w, "Description:\t%s\n", f.Description)
+	}
+	if f.Owner != "" {
+		fmt.Fprintf(w, "Owner:\t%s\n", f.Owner)
+	}
+	if len(f.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(f.Tags, ", "))
+	}
+	if f.ExpiresAt != nil {
+		expires := f.ExpiresAt.Local().Format(time.DateTime)
+		if expired(f) {
+			expires += " (expired)"
+		}
+		fmt.Fprintf(w, "Expires:\t%s\n", expires)
+	}
+	fmt.Fprintf(w, "Updated:\t%s by %s\n", f.UpdatedAt.Local().Format(time.DateTime), f.UpdatedBy)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("Value:")
+	fmt.Println(indentJSON(f.Value))
+	return nil
+}
+
+func printAudits(output string, items []resp.AuditLogItem) error {
+	if output == "json" {
+		return printJSON(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "AUDIT\tTIME\tOPERATOR\tACTION\tTYPE\tOLD\tNEW")
+	for _, a := range items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.CreatedAt.Local().Format(time.DateTime), a.Operator, a.Action, a.Type, cell(a.OldValue), cell(a.NewValue))
+	}
+	return w.Flush()
+}
+
+func printSchedules(output string, items []resp.ScheduleItem) error {
+	if output == "json" {
+		return printJSON(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKEY\tRUN AT\tSTATUS\tTYPE\tVALUE\tBY\tRESULT")
+	for _, s := range items {
+		result := s.Result
+		if s.Version > 0 {
+			result = fmt.Sprintf("version %d", s.Version)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.Key, s.RunAt.Local().Format(time.DateTime), s.Status, s.Type, cell(s.Value), s.CreatedBy, cell(result))
+	}
+	return w.Flush()
+}
+
+func printRollouts(output string, items []resp.RolloutItem) error {
+	if output == "json" {
+		return printJSON(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKEY\tRULE\tSTATUS\tSTEP\tPERCENT\tNEXT\tBY\tRESULT")
+	for _, r := range items {
+		next := "-"
+		switch {
+		case r.NextAt != nil:
+			next = r.NextAt.Local().Format(time.DateTime)
+		case r.Remaining != "":
+			next = r.Remaining + " left"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d/%d\t%d%%\t%s\t%s\t%s\n", r.ID, r.Key, r.RuleID, r.Status, r.Step+1, len(r.Steps), r.Percentage, next, r.UpdatedBy, cell(r.Result))
+	}
+	return w.Flush()
+}
+
+func printChangeRequests(output string, items []resp.ChangeRequestItem) error {
+	if output == "json" {
+		return printJSON(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKEY\tACTION\tSTATUS\tAPPROVALS\tVALUE\tBY\tCREATED AT\tREASON")
+	for _, r := range items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\n", r.ID, r.Key, r.Action, r.Status, len(r.Approvals), r.RequiredApprovals, cell(r.Value), r.CreatedBy, r.CreatedAt.Local().Format(time.DateTime), cell(r.Reason))
+	}
+	return w.Flush()
+}
+
+// printProposed tells a write to a protected environment was turned into a
+// change request.
+func printProposed(output string, r *resp.ChangeRequestItem) error {
+	if output == "json" {
+		return printJSON(r)
+	}
+	fmt.Printf("%s is protected, change request %d to %s %s created, it needs %d approvals\n", r.Env, r.ID, r.Action, r.Key, r.RequiredApprovals)
+	return nil
+}
+
+func printChangeRequest(output string, r *resp.ChangeRequestItem) error {
+	if output == "json" {
+		return printJSON(r)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Change request:\t%d\n", r.ID)
+	fmt.Fprintf(w, "Flag:\t%s/%s/%s\n", r.Env, r.Namespace, r.Key)
+	fmt.Fprintf(w, "Action:\t%s\n", r.Action)
+	fmt.Fprintf(w, "Type:\t%s\n", r.Type)
+	fmt.Fprintf(w, "Status:\t%s\n", r.Status)
+	fmt.Fprintf(w, "Author:\t%s, %s\n", r.CreatedBy, r.CreatedAt.Local().Format(time.DateTime))
+	if r.Reason != "" {
+		fmt.Fprintf(w, "Reason:\t%s\n", r.Reason)
+	}
+	fmt.Fprintf(w, "Based on:\tversion %d\n", r.BaseVersion)
+	fmt.Fprintf(w, "Approvals:\t%d of %d\n", len(r.Approvals), r.RequiredApprovals)
+	for _, a := range r.Approvals {
+		approval := a.Approver + ", " + a.CreatedAt.Local().Format(time.DateTime)
+		if a.Comment != "" {
+			approval += ": " + a.Comment
+		}
+		fmt.Fprintf(w, "\t%s\n", approval)
+	}
+	switch {
+	case r.Version > 0:
+		fmt.Fprintf(w, "Result:\tversion %d\n", r.Version)
+	case r.Result != "":
+		fmt.Fprintf(w, "Result:\t%s\n", r.Result)
+	}
+	if len(r.Metadata) > 0 {
+		fmt.Fprintf(w, "Metadata:\t%s\n", r.Metadata)
+	}
+	if len(r.Payload) > 0 {
+		fmt.Fprintf(w, "Payload:\t%s\n", r.Payload)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("Diff:")
+	fmt.Print(r.Diff)
+	return nil
+}
+
+// messagePrinter prints admin stream messages one per line, as JSON lines
+// with -o json so the output can be piped.
+func messagePrinter(output string) func(v1.Message) {
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		return func(msg v1.Message) { enc.Encode(msg) }
+	}
+	return func(msg v1.Message) {
+		at := time.Now()
+		if msg.UpdatedAt > 0 {
+			at = time.UnixMilli(msg.UpdatedAt)
+		}
+		action := "PUT"
+		if msg.Action == constraints.DELETE {
+			action = "DELETE"
+		}
+		fmt.Printf("%s  rev %d  %-6s %s/%s/%s v%d  %s\n", at.Local().Format(time.TimeOnly), msg.Revision, action, msg.Env, msg.Namespace, msg.Key, msg.Version, cell(msg.Value))
+	}
+}
+
+func expired(f *resp.FeatureItem) bool {
+	return f.ExpiresAt != nil && f.ExpiresAt.Before(time.Now())
+}
+
+func cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxCell {
+		return s[:maxCell-3] + "..."
+	}
+	return s
+}
+
+func indentJSON(s string) string {
+	if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+		return s
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, []byte(s), "", "  ") != nil {
+		return s
+	}
+	return buf.String()
+}
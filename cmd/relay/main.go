@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"mizuflow/internal/config"
+	"mizuflow/internal/relay"
+	"mizuflow/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	cfg := config.LoadRelay()
+
+	logger.InitLogger(cfg.Relay.Environment)
+	defer logger.Sync()
+
+	if err := run(cfg); err != nil {
+		logger.Error("relay startup failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.RelayConfig) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := relay.New(relay.Config{
+		Upstream:           cfg.Upstream.Endpoints,
+		APIKey:             cfg.Upstream.APIKey,
+		Env:                cfg.Upstream.Env,
+		Namespaces:         cfg.Upstream.Namespaces,
+		CacheDir:           cfg.Relay.CacheDir,
+		KeyRefreshInterval: cfg.Upstream.KeyRefreshInterval,
+		HeartbeatInterval:  cfg.Stream.HeartbeatInterval,
+		HubBufferSize:      cfg.Stream.HubBufferSize,
+		RevisionBufferSize: cfg.Relay.RevisionBufferSize,
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.Start(ctx); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	srv := &http.Server{
+		Addr:    cfg.Relay.Port,
+		Handler: r.Handler(),
+	}
+
+	go func() {
+		logger.Info("relay starting",
+			zap.String("addr", cfg.Relay.Port),
+			zap.String("env", cfg.Upstream.Env),
+			zap.Strings("namespaces", cfg.Upstream.Namespaces))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("relay listen failed", zap.Error(err))
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("shutting down relay...")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("relay forced to shutdown: %w", err)
+	}
+
+	logger.Info("relay exited properly")
+	return nil
+}
@@ -68,13 +68,25 @@ func run(cfg *config.Config) error {
 	featureRepo := repository.NewFeatureMasterRepository(db)
 	outboxRepo := repository.NewOutboxRepository(db)
 	sdkRepo := repository.NewSDKKeyRepository(db)
+	exposureRepo := repository.NewExposureRepository(db)
+	scheduleRepo := repository.NewScheduleRepository(db)
+	rolloutRepo := repository.NewRolloutRepository(db)
+	changeRepo := repository.NewChangeRequestRepository(db)
 
 	// 5. Initialize Services
 	observer := metrics.NewPrometheusObserver()
 	hub := service.NewHub(observer, cfg.Stream.HeartbeatInterval, cfg.Stream.HubBufferSize)
 
-	svc := service.NewFeatureService(db, etcdRepo, mysqlRepo, featureRepo, outboxRepo, hub)
+	svc := service.NewFeatureService(db, etcdRepo, mysqlRepo, featureRepo, outboxRepo, hub, service.NewProtection(cfg.Approvals.Environments))
 	authSvc := service.NewAuthService(rdb, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+	exposureSvc := service.NewExposureService(exposureRepo, cfg.Workers.ExposureFlushInterval)
+	scheduleSvc := service.NewScheduleService(etcdCli, scheduleRepo, mysqlRepo, svc, service.ScheduleConfig{
+		Interval: cfg.Workers.SchedulerInterval,
+	})
+	rolloutSvc := service.NewRolloutService(etcdCli, rolloutRepo, svc, hub, service.RolloutConfig{
+		Interval: cfg.Workers.RolloutInterval,
+	})
+	changeSvc := service.NewChangeRequestService(changeRepo, mysqlRepo, svc, scheduleSvc, rolloutSvc)
 
 	// 6. Initialize & Start Workers (Background Tasks)
 	outboxWorker := service.NewOutboxWorker(outboxRepo, etcdRepo, cfg.Workers.OutboxInterval)
@@ -93,6 +105,18 @@ func run(cfg *config.Config) error {
 		logger.Info("starting reconciler")
 		reconciler.Run(ctx)
 	}()
+	go func() {
+		logger.Info("starting scheduler")
+		scheduleSvc.Run(ctx)
+	}()
+	go func() {
+		logger.Info("starting rollout worker")
+		rolloutSvc.Run(ctx)
+	}()
+	go func() {
+		logger.Info("starting rollout watcher")
+		rolloutSvc.Watch(ctx)
+	}()
 	go func() {
 		logger.Info("starting hub")
 		hub.Run()
@@ -101,12 +125,22 @@ func run(cfg *config.Config) error {
 		logger.Info("starting feature service watcher")
 		svc.Run(ctx)
 	}()
+	go func() {
+		logger.Info("starting exposure flusher")
+		exposureSvc.Run(ctx)
+	}()
 
 	// 7. Setup HTTP Server
 	r := api.RegisterRoutes(
-		api.NewFeatureHandler(svc, hub),
+		api.NewFeatureHandler(svc, changeSvc, hub),
 		api.NewStreamHandler(svc, hub),
 		api.NewAuthHandler(authSvc),
+		api.NewExposureHandler(exposureSvc),
+		api.NewRelayHandler(sdkRepo),
+		api.NewScheduleHandler(scheduleSvc, changeSvc),
+		api.NewRolloutHandler(rolloutSvc, changeSvc),
+		api.NewChangeRequestHandler(changeSvc),
+		sdkRepo,
 		sdkRepo,
 		rdb,
 		cfg.RateLimit.RequestsPerSecond,
@@ -187,6 +221,11 @@ func initDB(cfg config.MySQLConfig) (*gorm.DB, error) {
 		&model.FeatureAudit{},
 		&model.OutboxTask{},
 		&model.SDKClient{},
+		&model.FeatureExposure{},
+		&model.ScheduledChange{},
+		&model.RolloutPlan{},
+		&model.ChangeRequest{},
+		&model.ChangeApproval{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"mizuflow/client"
+	"mizuflow/internal/config"
+	"mizuflow/internal/sidecar"
+	"mizuflow/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	cfg := config.LoadSidecar()
+
+	logger.InitLogger(cfg.Sidecar.Environment)
+	defer logger.Sync()
+
+	if err := run(cfg); err != nil {
+		logger.Error("sidecar startup failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.SidecarConfig) error {
+	if len(cfg.Upstream.Endpoints) == 0 || cfg.Upstream.Env == "" || len(cfg.Upstream.Namespaces) == 0 {
+		return errors.New("sidecar needs upstream endpoints, an env and namespaces")
+	}
+	if cfg.Sidecar.CacheFile != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.Sidecar.CacheFile), 0700); err != nil {
+			return fmt.Errorf("failed to create cache dir: %w", err)
+		}
+	}
+
+	c := client.NewMizuClient(cfg.Upstream.Endpoints[0], cfg.Upstream.Env, cfg.Upstream.APIKey, cfg.Upstream.Namespaces,
+		client.WithEndpoints(cfg.Upstream.Endpoints...),
+		client.WithCacheFile(cfg.Sidecar.CacheFile),
+	)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	srv := &http.Server{
+		Addr:    cfg.Sidecar.Port,
+		Handler: sidecar.New(c, cfg.Sidecar.HeartbeatInterval).Handler(),
+	}
+
+	go func() {
+		logger.Info("sidecar starting",
+			zap.String("addr", cfg.Sidecar.Port),
+			zap.String("env", cfg.Upstream.Env),
+			zap.Strings("namespaces", cfg.Upstream.Namespaces))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("sidecar listen failed", zap.Error(err))
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("shutting down sidecar...")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("sidecar forced to shutdown: %w", err)
+	}
+
+	logger.Info("sidecar exited properly")
+	return nil
+}
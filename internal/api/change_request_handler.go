@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/repository"
+	"mizuflow/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangeGate turns writes to protected environments into change requests.
+type ChangeGate interface {
+	Protects(env string) bool
+	CreateChangeRequest(ctx context.Context, r req.CreateFeatureRequest, operator string) (*resp.ChangeRequestItem, error)
+	ProposeRollback(ctx context.Context, r req.RollbackFeatureRequest, key, operator string) (*resp.ChangeRequestItem, error)
+	ProposeDelete(ctx context.Context, namespace, env, key string, hard bool, operator string) (*resp.ChangeRequestItem, error)
+	ProposeRestore(ctx context.Context, namespace, env, key, operator string) (*resp.ChangeRequestItem, error)
+	ProposeSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string) (*resp.ChangeRequestItem, error)
+	ProposeRollout(ctx context.Context, r req.CreateRolloutRequest, operator string) (*resp.ChangeRequestItem, error)
+}
+
+type ChangeRequestProvider interface {
+	ListChangeRequests(ctx context.Context, filter repository.ChangeRequestFilter) ([]resp.ChangeRequestItem, error)
+	GetChangeRequest(ctx context.Context, id uint64) (*resp.ChangeRequestItem, error)
+	ApproveChangeRequest(ctx context.Context, id uint64, comment, operator string) (*resp.ChangeRequestItem, error)
+	RejectChangeRequest(ctx context.Context, id uint64, comment, operator string) (*resp.ChangeRequestItem, error)
+	ApplyChangeRequest(ctx context.Context, id uint64, operator string) (*resp.ChangeRequestItem, error)
+}
+
+type ChangeRequestHandler struct {
+	service ChangeRequestProvider
+}
+
+func NewChangeRequestHandler(service ChangeRequestProvider) *ChangeRequestHandler {
+	return &ChangeRequestHandler{service: service}
+}
+
+// ListChangeRequests lists the pending requests, all of them with include_done=true.
+func (h *ChangeRequestHandler) ListChangeRequests(c *gin.Context) {
+	items, err := h.service.ListChangeRequests(c.Request.Context(), repository.ChangeRequestFilter{
+		Namespace:   c.Query("namespace"),
+		Env:         c.Query("env"),
+		Key:         c.Query("key"),
+		IncludeDone: c.Query("include_done") == "true",
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, items)
+}
+
+func (h *ChangeRequestHandler) GetChangeRequest(c *gin.Context) {
+	id, ok := changeRequestID(c)
+	if !ok {
+		return
+	}
+	item, err := h.service.GetChangeRequest(c.Request.Context(), id)
+	writeChangeRequest(c, item, err)
+}
+
+func (h *ChangeRequestHandler) ApproveChangeRequest(c *gin.Context) {
+	h.review(c, h.service.ApproveChangeRequest)
+}
+
+func (h *ChangeRequestHandler) RejectChangeRequest(c *gin.Context) {
+	h.review(c, h.service.RejectChangeRequest)
+}
+
+func (h *ChangeRequestHandler) ApplyChangeRequest(c *gin.Context) {
+	id, ok := changeRequestID(c)
+	if !ok {
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	item, err := h.service.ApplyChangeRequest(c.Request.Context(), id, operator)
+	writeChangeRequest(c, item, err)
+}
+
+// review takes an optional comment; an empty body is no comment.
+func (h *ChangeRequestHandler) review(c *gin.Context, fn func(ctx context.Context, id uint64, comment, operator string) (*resp.ChangeRequestItem, error)) {
+	id, ok := changeRequestID(c)
+	if !ok {
+		return
+	}
+	var r req.ReviewChangeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(400, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+	operator := service.GetOperator(c.Request.Context())
+	item, err := fn(c.Request.Context(), id, r.Comment, operator)
+	writeChangeRequest(c, item, err)
+}
+
+func changeRequestID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeChangeRequest(c *gin.Context, item *resp.ChangeRequestItem, err error) {
+	switch {
+	case errors.Is(err, service.ErrChangeRequestNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSelfApproval):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrChangeRequestState),
+		errors.Is(err, service.ErrInvalidSchedule),
+		errors.Is(err, service.ErrInvalidRollout),
+		errors.Is(err, service.ErrRolloutActive):
+		// a scheduled change may be due, or the flag changed, before the
+		// request is approved
+		c.JSON(409, gin.H{"error": err.Error()})
+	case err != nil:
+		writeSaveError(c, err)
+	default:
+		c.JSON(200, item)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"mizuflow/internal/dto/resp"
+	v1 "mizuflow/pkg/api/v1"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExposureProvider interface {
+	Record(report v1.ExposureReport, namespaces map[string]bool)
+	ListExposures(ctx context.Context, env, namespace, key string) ([]resp.ExposureItem, error)
+}
+
+type ExposureHandler struct {
+	service ExposureProvider
+}
+
+func NewExposureHandler(service ExposureProvider) *ExposureHandler {
+	return &ExposureHandler{service: service}
+}
+
+func (h *ExposureHandler) ReportExposures(c *gin.Context) {
+	var report v1.ExposureReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		c.JSON(400, gin.H{"error": "JSON format error"})
+		return
+	}
+	// the SDK key was validated for this env, don't trust the body
+	report.Env = c.Query("env")
+	// like the stream, reports only count for the namespaces the SDK follows
+	namespaces := make(map[string]bool)
+	for ns := range strings.SplitSeq(c.Query("namespace"), ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces[ns] = true
+		}
+	}
+	if len(namespaces) == 0 {
+		c.JSON(400, gin.H{"error": "namespace is required"})
+		return
+	}
+	h.service.Record(report, namespaces)
+	c.Status(202)
+}
+
+func (h *ExposureHandler) ListExposures(c *gin.Context) {
+	items, err := h.service.ListExposures(c.Request.Context(), c.Query("env"), c.Query("namespace"), c.Query("key"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, items)
+}
@@ -2,8 +2,10 @@ package api
 
 import (
 	"context"
+	"errors"
 	"mizuflow/internal/dto/req"
 	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/repository"
 	"mizuflow/internal/service"
 	v1 "mizuflow/pkg/api/v1"
 
@@ -11,26 +13,33 @@ import (
 )
 
 type FeatureProvider interface {
-	SaveFeature(ctx context.Context, flag v1.FeatureFlag, operator string) (int, error)
+	SaveFeature(ctx context.Context, flag v1.FeatureFlag, meta *req.FeatureMetadata, expectedVersion *int, operator string) (int, error)
 	GetFeature(ctx context.Context, namespace, env, key string) (*resp.FeatureItem, error)
-	ListFeatures(ctx context.Context, namespace, env, search string) ([]resp.FeatureItem, error)
+	ListFeatures(ctx context.Context, filter repository.FeatureFilter, includeArchived bool) ([]resp.FeatureItem, error)
+	UpdateFeatureMetadata(ctx context.Context, namespace, env, key string, meta req.FeatureMetadata, operator string) (*resp.FeatureItem, error)
 	GetFeatureAudits(ctx context.Context, namespace, env, key string) ([]resp.AuditLogItem, error)
-	RollbackFeature(ctx context.Context, namespace, env, key string, auditID uint, operator string) (int, error)
+	RollbackFeature(ctx context.Context, namespace, env, key string, auditID uint, expectedVersion *int, operator string) (int, error)
+	DeleteFeature(ctx context.Context, namespace, env, key string, hard bool, operator string) (int, error)
+	RestoreFeature(ctx context.Context, namespace, env, key, operator string) (int, error)
 	Health(ctx context.Context) error
 }
 
 type FeatureHandler struct {
 	service FeatureProvider
+	changes ChangeGate
 	hub     *service.Hub
 }
 
-func NewFeatureHandler(service FeatureProvider, hub *service.Hub) *FeatureHandler {
+func NewFeatureHandler(service FeatureProvider, changes ChangeGate, hub *service.Hub) *FeatureHandler {
 	return &FeatureHandler{
 		service: service,
+		changes: changes,
 		hub:     hub,
 	}
 }
 
+// CreateFeature writes a flag, or in a protected environment opens a change
+// request for it and answers 202.
 func (h *FeatureHandler) CreateFeature(c *gin.Context) {
 	var r req.CreateFeatureRequest
 
@@ -39,6 +48,15 @@ func (h *FeatureHandler) CreateFeature(c *gin.Context) {
 		return
 	}
 	operator := service.GetOperator(c.Request.Context())
+	if h.changes.Protects(r.Env) {
+		change, err := h.changes.CreateChangeRequest(c.Request.Context(), r, operator)
+		if err != nil {
+			writeSaveError(c, err)
+			return
+		}
+		c.JSON(202, resp.CreateFeatureResponse{ChangeRequest: change})
+		return
+	}
 	rev, err := h.service.SaveFeature(c.Request.Context(), v1.FeatureFlag{
 		Namespace: r.Namespace,
 		Env:       r.Env,
@@ -46,26 +64,23 @@ func (h *FeatureHandler) CreateFeature(c *gin.Context) {
 		Value:     r.Value,
 		Version:   0,
 		Type:      r.Type,
-	}, operator)
+	}, r.FeatureMetadata, r.ExpectedVersion, operator)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		writeSaveError(c, err)
 		return
 	}
 	c.JSON(200, resp.CreateFeatureResponse{Version: rev})
 }
 
 func (h *FeatureHandler) GetFeature(c *gin.Context) {
+	key := c.Param("key")
 	var r req.GetFeatureRequest
-	if err := c.ShouldBindUri(&r); err != nil {
-		c.JSON(400, gin.H{"error": "invalid key"})
-		return
-	}
 	if err := c.ShouldBindQuery(&r); err != nil {
 		c.JSON(400, gin.H{"error": "invalid params"})
 		return
 	}
 
-	featureItem, err := h.service.GetFeature(c.Request.Context(), r.Namespace, r.Env, r.Key)
+	featureItem, err := h.service.GetFeature(c.Request.Context(), r.Namespace, r.Env, key)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -74,11 +89,16 @@ func (h *FeatureHandler) GetFeature(c *gin.Context) {
 }
 
 func (h *FeatureHandler) ListFeatures(c *gin.Context) {
-	namespace := c.Query("namespace")
-	env := c.Query("env")
-	search := c.Query("search")
+	filter := repository.FeatureFilter{
+		Namespace: c.Query("namespace"),
+		Env:       c.Query("env"),
+		Search:    c.Query("search"),
+		Owner:     c.Query("owner"),
+		Tag:       c.Query("tag"),
+	}
+	includeArchived := c.Query("include_archived") == "true"
 
-	features, err := h.service.ListFeatures(c.Request.Context(), namespace, env, search)
+	features, err := h.service.ListFeatures(c.Request.Context(), filter, includeArchived)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -99,6 +119,8 @@ func (h *FeatureHandler) GetFeatureAudits(c *gin.Context) {
 	c.JSON(200, audits)
 }
 
+// RollbackFeature, DeleteFeature and RestoreFeature open a change request in a
+// protected environment and answer 202, like CreateFeature.
 func (h *FeatureHandler) RollbackFeature(c *gin.Context) {
 	key := c.Param("key")
 	var r req.RollbackFeatureRequest
@@ -107,14 +129,127 @@ func (h *FeatureHandler) RollbackFeature(c *gin.Context) {
 		return
 	}
 	operator := service.GetOperator(c.Request.Context())
-	rev, err := h.service.RollbackFeature(c.Request.Context(), r.Namespace, r.Env, key, uint(r.AuditID), operator)
+	if h.changes.Protects(r.Env) {
+		change, err := h.changes.ProposeRollback(c.Request.Context(), r, key, operator)
+		if err != nil {
+			writeSaveError(c, err)
+			return
+		}
+		c.JSON(202, resp.RollbackFeatureResponse{ChangeRequest: change})
+		return
+	}
+	rev, err := h.service.RollbackFeature(c.Request.Context(), r.Namespace, r.Env, key, uint(r.AuditID), r.ExpectedVersion, operator)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		writeSaveError(c, err)
 		return
 	}
 	c.JSON(200, resp.RollbackFeatureResponse{Version: rev})
 }
 
+func (h *FeatureHandler) DeleteFeature(c *gin.Context) {
+	key := c.Param("key")
+	var r req.DeleteFeatureRequest
+	if err := c.ShouldBindQuery(&r); err != nil {
+		c.JSON(400, gin.H{"error": "invalid params"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	if h.changes.Protects(r.Env) {
+		change, err := h.changes.ProposeDelete(c.Request.Context(), r.Namespace, r.Env, key, r.Hard, operator)
+		if err != nil {
+			writeSaveError(c, err)
+			return
+		}
+		c.JSON(202, resp.DeleteFeatureResponse{ChangeRequest: change})
+		return
+	}
+	rev, err := h.service.DeleteFeature(c.Request.Context(), r.Namespace, r.Env, key, r.Hard, operator)
+	if err != nil {
+		writeSaveError(c, err)
+		return
+	}
+	c.JSON(200, resp.DeleteFeatureResponse{Version: rev})
+}
+
+func (h *FeatureHandler) RestoreFeature(c *gin.Context) {
+	key := c.Param("key")
+	var r req.RestoreFeatureRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	if h.changes.Protects(r.Env) {
+		change, err := h.changes.ProposeRestore(c.Request.Context(), r.Namespace, r.Env, key, operator)
+		if err != nil {
+			writeSaveError(c, err)
+			return
+		}
+		c.JSON(202, resp.RestoreFeatureResponse{ChangeRequest: change})
+		return
+	}
+	rev, err := h.service.RestoreFeature(c.Request.Context(), r.Namespace, r.Env, key, operator)
+	if err != nil {
+		writeSaveError(c, err)
+		return
+	}
+	c.JSON(200, resp.RestoreFeatureResponse{Version: rev})
+}
+
+// UpdateFeatureMetadata replaces description, owner, tags and expiry of a flag;
+// its value and version are left alone, so protected environments allow it.
+func (h *FeatureHandler) UpdateFeatureMetadata(c *gin.Context) {
+	key := c.Param("key")
+	var r req.UpdateFeatureMetadataRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	item, err := h.service.UpdateFeatureMetadata(c.Request.Context(), r.Namespace, r.Env, key, r.FeatureMetadata, operator)
+	if err != nil {
+		writeSaveError(c, err)
+		return
+	}
+	c.JSON(200, item)
+}
+
+// writeSaveError answers a version conflict with 409 and the current state, so
+// the caller can merge and retry. Invalid metadata or rollback targets are 400,
+// a write only a change request may make 403, a missing flag 404, a write the
+// flag's state or its prerequisites do not allow 409.
+func writeSaveError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAuditNotUpdate),
+		errors.Is(err, service.ErrInvalidMetadata):
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrProtectedEnv):
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrFeatureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrFeatureArchived),
+		errors.Is(err, service.ErrFeatureAlreadyArchived),
+		errors.Is(err, service.ErrFeatureNotArchived),
+		errors.Is(err, service.ErrFeatureInUse),
+		errors.Is(err, service.ErrPrerequisite):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	var conflict *service.VersionConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(409, resp.VersionConflictResponse{
+			Error:          err.Error(),
+			CurrentVersion: conflict.Current,
+			CurrentValue:   conflict.CurrentValue,
+		})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
+
 func (h *FeatureHandler) HealthCheck(c *gin.Context) {
 	if err := h.service.Health(c.Request.Context()); err != nil {
 		c.JSON(503, gin.H{"status": "unhealthy", "error": err.Error()})
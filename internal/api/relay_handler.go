@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	v1 "mizuflow/pkg/api/v1"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RelayKeyProvider interface {
+	ListAPIKeys(ctx context.Context, env string) ([]string, error)
+}
+
+type RelayHandler struct {
+	keys RelayKeyProvider
+}
+
+func NewRelayHandler(keys RelayKeyProvider) *RelayHandler {
+	return &RelayHandler{keys: keys}
+}
+
+// ListKeys serves the hashed SDK keys of the env the relay key belongs to.
+func (h *RelayHandler) ListKeys(c *gin.Context) {
+	env := c.Query("env")
+	keys, err := h.keys.ListAPIKeys(c.Request.Context(), env)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	res := v1.RelayKeys{Env: env, KeyHashes: make([]string, 0, len(keys))}
+	for _, key := range keys {
+		res.KeyHashes = append(res.KeyHashes, v1.HashAPIKey(key))
+	}
+	c.JSON(200, res)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/repository"
+	"mizuflow/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RolloutProvider interface {
+	CreateRollout(ctx context.Context, r req.CreateRolloutRequest, operator string) (*resp.RolloutItem, error)
+	ListRollouts(ctx context.Context, filter repository.RolloutFilter) ([]resp.RolloutItem, error)
+	PauseRollout(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error)
+	ResumeRollout(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error)
+	AbortRollout(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error)
+}
+
+type RolloutHandler struct {
+	service RolloutProvider
+	changes ChangeGate
+}
+
+func NewRolloutHandler(service RolloutProvider, changes ChangeGate) *RolloutHandler {
+	return &RolloutHandler{service: service, changes: changes}
+}
+
+// CreateRollout starts a plan, or in a protected environment opens a change
+// request that starts it once approved and answers 202.
+func (h *RolloutHandler) CreateRollout(c *gin.Context) {
+	var r req.CreateRolloutRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	if h.changes.Protects(r.Env) {
+		change, err := h.changes.ProposeRollout(c.Request.Context(), r, operator)
+		if err != nil {
+			writeRolloutError(c, err)
+			return
+		}
+		c.JSON(202, resp.CreateRolloutResponse{ChangeRequest: change})
+		return
+	}
+	item, err := h.service.CreateRollout(c.Request.Context(), r, operator)
+	if err != nil {
+		writeRolloutError(c, err)
+		return
+	}
+	c.JSON(200, resp.CreateRolloutResponse{RolloutItem: item})
+}
+
+// writeRolloutError answers invalid steps or rules with 400, a write only a
+// change request may make with 403, a missing flag with 404 and an archived
+// flag or one with an active plan with 409.
+func writeRolloutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRollout):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProtectedEnv):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrFeatureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRolloutActive),
+		errors.Is(err, service.ErrFeatureArchived):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// ListRollouts lists the running and paused plans, all of them with include_done=true.
+func (h *RolloutHandler) ListRollouts(c *gin.Context) {
+	items, err := h.service.ListRollouts(c.Request.Context(), repository.RolloutFilter{
+		Namespace:   c.Query("namespace"),
+		Env:         c.Query("env"),
+		Key:         c.Query("key"),
+		IncludeDone: c.Query("include_done") == "true",
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, items)
+}
+
+func (h *RolloutHandler) PauseRollout(c *gin.Context) {
+	h.transition(c, h.service.PauseRollout)
+}
+
+func (h *RolloutHandler) ResumeRollout(c *gin.Context) {
+	h.transition(c, h.service.ResumeRollout)
+}
+
+func (h *RolloutHandler) AbortRollout(c *gin.Context) {
+	h.transition(c, h.service.AbortRollout)
+}
+
+func (h *RolloutHandler) transition(c *gin.Context, fn func(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	item, err := fn(c.Request.Context(), id, operator)
+	switch {
+	case errors.Is(err, service.ErrRolloutNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRolloutState):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.JSON(200, item)
+	}
+}
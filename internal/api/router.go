@@ -9,7 +9,7 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-func RegisterRoutes(featureHandler *FeatureHandler, streamHandler *StreamHandler, authHandler *AuthHandler, sdkRepo repository.SDKRepository, rdb *redis.Client, requestsPerSecond int, env string) *gin.Engine {
+func RegisterRoutes(featureHandler *FeatureHandler, streamHandler *StreamHandler, authHandler *AuthHandler, exposureHandler *ExposureHandler, relayHandler *RelayHandler, scheduleHandler *ScheduleHandler, rolloutHandler *RolloutHandler, changeRequestHandler *ChangeRequestHandler, sdkRepo repository.SDKRepository, relayRepo repository.RelayKeyRepository, rdb *redis.Client, requestsPerSecond int, env string) *gin.Engine {
 	r := gin.New()
 
 	// Determine if we should bypass auth (e.g. for load testing)
@@ -51,12 +51,21 @@ func RegisterRoutes(featureHandler *FeatureHandler, streamHandler *StreamHandler
 	{
 		stream.GET("/watch", streamHandler.WatchFeature)
 		stream.GET("/snapshot", streamHandler.FetchAll)
+		stream.POST("/exposures", exposureHandler.ReportExposures)
+	}
+
+	// Relay Routes (Protected by relay SDK Key)
+	relay := r.Group("/v1/relay")
+	relay.Use(middleware.RelayAuthMiddleware(relayRepo))
+	{
+		relay.GET("/keys", relayHandler.ListKeys)
 	}
 
 	admin := r.Group("/v1/admin")
 	admin.Use(middleware.JWTMiddleware(true))
 	{
 		admin.GET("/stream", streamHandler.DashboardWatch)
+		admin.GET("/exposures", exposureHandler.ListExposures)
 	}
 
 	// Protected Routes (Control Plane)
@@ -73,6 +82,22 @@ func RegisterRoutes(featureHandler *FeatureHandler, streamHandler *StreamHandler
 		protected.GET("/feature/:key", featureHandler.GetFeature)
 		protected.GET("/feature/:key/audits", featureHandler.GetFeatureAudits)
 		protected.POST("/feature/:key/rollback", writeLimiter, featureHandler.RollbackFeature)
+		protected.DELETE("/feature/:key", writeLimiter, featureHandler.DeleteFeature)
+		protected.POST("/feature/:key/restore", writeLimiter, featureHandler.RestoreFeature)
+		protected.PUT("/feature/:key/metadata", writeLimiter, featureHandler.UpdateFeatureMetadata)
+		protected.POST("/schedules", writeLimiter, scheduleHandler.CreateSchedule)
+		protected.GET("/schedules", scheduleHandler.ListSchedules)
+		protected.DELETE("/schedules/:id", writeLimiter, scheduleHandler.CancelSchedule)
+		protected.POST("/rollouts", writeLimiter, rolloutHandler.CreateRollout)
+		protected.GET("/rollouts", rolloutHandler.ListRollouts)
+		protected.POST("/rollouts/:id/pause", writeLimiter, rolloutHandler.PauseRollout)
+		protected.POST("/rollouts/:id/resume", writeLimiter, rolloutHandler.ResumeRollout)
+		protected.POST("/rollouts/:id/abort", writeLimiter, rolloutHandler.AbortRollout)
+		protected.GET("/change-requests", changeRequestHandler.ListChangeRequests)
+		protected.GET("/change-requests/:id", changeRequestHandler.GetChangeRequest)
+		protected.POST("/change-requests/:id/approve", writeLimiter, changeRequestHandler.ApproveChangeRequest)
+		protected.POST("/change-requests/:id/reject", writeLimiter, changeRequestHandler.RejectChangeRequest)
+		protected.POST("/change-requests/:id/apply", writeLimiter, changeRequestHandler.ApplyChangeRequest)
 	}
 	return r
 }
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/repository"
+	"mizuflow/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleProvider interface {
+	CreateSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string) (*resp.ScheduleItem, error)
+	ListSchedules(ctx context.Context, filter repository.ScheduleFilter) ([]resp.ScheduleItem, error)
+	CancelSchedule(ctx context.Context, id uint64, operator string) error
+}
+
+type ScheduleHandler struct {
+	service ScheduleProvider
+	changes ChangeGate
+}
+
+func NewScheduleHandler(service ScheduleProvider, changes ChangeGate) *ScheduleHandler {
+	return &ScheduleHandler{service: service, changes: changes}
+}
+
+// CreateSchedule schedules a change, or in a protected environment opens a
+// change request that schedules it once approved and answers 202.
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var r req.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	if h.changes.Protects(r.Env) {
+		change, err := h.changes.ProposeSchedule(c.Request.Context(), r, operator)
+		if err != nil {
+			writeScheduleError(c, err)
+			return
+		}
+		c.JSON(202, resp.CreateScheduleResponse{ChangeRequest: change})
+		return
+	}
+	item, err := h.service.CreateSchedule(c.Request.Context(), r, operator)
+	if err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+	c.JSON(200, resp.CreateScheduleResponse{ScheduleItem: item})
+}
+
+// writeScheduleError answers a change that does not validate with 400, a write
+// only a change request may make with 403 and a missing flag with 404.
+func writeScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSchedule):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrProtectedEnv):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrFeatureNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// ListSchedules lists the pending changes, all of them with include_done=true.
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	items, err := h.service.ListSchedules(c.Request.Context(), repository.ScheduleFilter{
+		Namespace:   c.Query("namespace"),
+		Env:         c.Query("env"),
+		Key:         c.Query("key"),
+		IncludeDone: c.Query("include_done") == "true",
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, items)
+}
+
+func (h *ScheduleHandler) CancelSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	operator := service.GetOperator(c.Request.Context())
+	err = h.service.CancelSchedule(c.Request.Context(), id, operator)
+	switch {
+	case errors.Is(err, service.ErrScheduleNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrScheduleNotPending):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+	default:
+		c.Status(204)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"mizuflow/internal/service"
 	v1 "mizuflow/pkg/api/v1"
 	"mizuflow/pkg/logger"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -92,6 +93,9 @@ func (h *StreamHandler) WatchFeature(c *gin.Context) {
 	} else {
 		c.SSEvent("reset", "revision_too_old")
 	}
+	// flush the compensation right away; the ping tells the client the stream gets through
+	c.SSEvent("ping", "pong")
+	c.Writer.Flush()
 
 	c.Stream(func(w io.Writer) bool {
 		select {
@@ -100,7 +104,7 @@ func (h *StreamHandler) WatchFeature(c *gin.Context) {
 				return false
 			}
 
-			if msg.Type == "ping" {
+			if msg.Type == service.MessageTypePing {
 				c.SSEvent("ping", "pong")
 				return true
 			}
@@ -109,6 +113,12 @@ func (h *StreamHandler) WatchFeature(c *gin.Context) {
 				return true
 			}
 
+			if msg.Type == service.MessageTypeReset {
+				// the provider replaced its data wholesale, the client refetches and reconnects
+				c.SSEvent("reset", "resync")
+				return false
+			}
+
 			// filter replicated messages
 			if msg.Revision <= maxSentRev {
 				return true
@@ -140,6 +150,7 @@ func (h *StreamHandler) DashboardWatch(c *gin.Context) {
 		Send:       clientChan,
 		Namespaces: map[string]bool{"*": true},
 		Env:        c.Query("env"),
+		Events:     make(chan service.AdminEvent, 32),
 	}
 
 	h.hub.Register <- client
@@ -152,12 +163,15 @@ func (h *StreamHandler) DashboardWatch(c *gin.Context) {
 			if !ok {
 				return false
 			}
-			if msg.Type == "ping" {
+			if msg.Type == service.MessageTypePing {
 				c.SSEvent("ping", "pong")
 				return true
 			}
 			c.SSEvent("message", msg)
 			return true
+		case event := <-client.Events:
+			c.SSEvent(event.Type, event.Data)
+			return true
 		case <-c.Request.Context().Done():
 			return false
 		}
@@ -180,6 +194,39 @@ func (h *StreamHandler) FetchAll(c *gin.Context) {
 
 	features, rev := h.service.GetAllFeatures(c.Request.Context())
 
+	// the ETag is the revision of the data a client holds; nothing newer, nothing to send
+	if c.GetHeader("If-None-Match") == revisionETag(rev) {
+		c.Header("ETag", revisionETag(rev))
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if sinceRev, err := strconv.ParseInt(c.Query("since_rev"), 10, 64); err == nil {
+		// an empty buffer only proves nothing changed if the client is already at the cache revision
+		if messages, ok := h.service.GetCompensation(sinceRev); ok && (len(messages) > 0 || sinceRev >= rev) {
+			changes := make([]v1.Message, 0, len(messages))
+			latest := sinceRev
+			for _, msg := range messages {
+				latest = max(latest, msg.Revision)
+				if env != "" && msg.Env != env {
+					continue
+				}
+				if len(allowedNamespaces) > 0 && !allowedNamespaces[msg.Namespace] {
+					continue
+				}
+				changes = append(changes, msg)
+			}
+			c.Header("ETag", revisionETag(latest))
+			c.JSON(200, resp.SnapshotResponse{
+				Changes:     changes,
+				Incremental: true,
+				Revision:    latest,
+			})
+			return
+		}
+		// since_rev fell out of the buffer, fall back to the full snapshot
+	}
+
 	// Filter features based on env and namespace
 	var filtered []v1.FeatureFlag
 	if env == "" && namespacesStr == "" {
@@ -197,8 +244,13 @@ func (h *StreamHandler) FetchAll(c *gin.Context) {
 		}
 	}
 
+	c.Header("ETag", revisionETag(rev))
 	c.JSON(200, resp.SnapshotResponse{
 		Data:     filtered,
 		Revision: rev,
 	})
 }
+
+func revisionETag(rev int64) string {
+	return `"` + strconv.FormatInt(rev, 10) + `"`
+}
@@ -16,6 +16,7 @@ type Config struct {
 	Stream    StreamConfig    `mapstructure:"stream"`
 	Auth      AuthConfig      `mapstructure:"auth"`
 	RateLimit RateLimitConfig `mapstructure:"ratelimit"`
+	Approvals ApprovalConfig  `mapstructure:"approvals"`
 }
 
 type ServerConfig struct {
@@ -39,10 +40,13 @@ type EtcdConfig struct {
 }
 
 type WorkersConfig struct {
-	OutboxInterval       time.Duration `mapstructure:"outbox_interval"`
-	ReconcilerInterval   time.Duration `mapstructure:"reconciler_interval"`
-	ReconcilerBatchSize  int           `mapstructure:"reconciler_batch_size"`
-	ReconcilerBatchDelay time.Duration `mapstructure:"reconciler_batch_delay"`
+	OutboxInterval        time.Duration `mapstructure:"outbox_interval"`
+	ReconcilerInterval    time.Duration `mapstructure:"reconciler_interval"`
+	ReconcilerBatchSize   int           `mapstructure:"reconciler_batch_size"`
+	ReconcilerBatchDelay  time.Duration `mapstructure:"reconciler_batch_delay"`
+	ExposureFlushInterval time.Duration `mapstructure:"exposure_flush_interval"`
+	SchedulerInterval     time.Duration `mapstructure:"scheduler_interval"`
+	RolloutInterval       time.Duration `mapstructure:"rollout_interval"`
 }
 
 type StreamConfig struct {
@@ -59,6 +63,12 @@ type RateLimitConfig struct {
 	RequestsPerSecond int `mapstructure:"requests_per_second"`
 }
 
+// ApprovalConfig protects environments: a flag write to one of them becomes a
+// change request, applied once it has the given number of approvals.
+type ApprovalConfig struct {
+	Environments map[string]int `mapstructure:"environments"`
+}
+
 func Load() *Config {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -83,3 +93,90 @@ func Load() *Config {
 
 	return &cfg
 }
+
+// RelayConfig configures cmd/relay. It is read from relay.yaml and MIZU_ env
+// variables, e.g. MIZU_UPSTREAM_API_KEY.
+type RelayConfig struct {
+	Relay    RelayServerConfig `mapstructure:"relay"`
+	Upstream UpstreamConfig    `mapstructure:"upstream"`
+	Stream   StreamConfig      `mapstructure:"stream"`
+}
+
+type RelayServerConfig struct {
+	Environment        string `mapstructure:"environment"`
+	Port               string `mapstructure:"port"`
+	CacheDir           string `mapstructure:"cache_dir"`
+	RevisionBufferSize int    `mapstructure:"revision_buffer_size"`
+}
+
+type UpstreamConfig struct {
+	Endpoints          []string      `mapstructure:"endpoints"`
+	APIKey             string        `mapstructure:"api_key"`
+	Env                string        `mapstructure:"env"`
+	Namespaces         []string      `mapstructure:"namespaces"`
+	KeyRefreshInterval time.Duration `mapstructure:"key_refresh_interval"`
+}
+
+func LoadRelay() *RelayConfig {
+	v := viper.New()
+	v.SetConfigName("relay")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")
+	v.AddConfigPath("./config")
+
+	v.SetEnvPrefix("MIZU")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			panic(err)
+		}
+	}
+
+	var cfg RelayConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		panic(err)
+	}
+
+	return &cfg
+}
+
+// SidecarConfig configures cmd/sidecar. It is read from sidecar.yaml and MIZU_
+// env variables, e.g. MIZU_UPSTREAM_API_KEY.
+type SidecarConfig struct {
+	Sidecar  SidecarServerConfig `mapstructure:"sidecar"`
+	Upstream UpstreamConfig      `mapstructure:"upstream"`
+}
+
+type SidecarServerConfig struct {
+	Environment       string        `mapstructure:"environment"`
+	Port              string        `mapstructure:"port"`
+	CacheFile         string        `mapstructure:"cache_file"`
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+}
+
+func LoadSidecar() *SidecarConfig {
+	v := viper.New()
+	v.SetConfigName("sidecar")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(".")
+	v.AddConfigPath("./config")
+
+	v.SetEnvPrefix("MIZU")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			panic(err)
+		}
+	}
+
+	var cfg SidecarConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		panic(err)
+	}
+
+	return &cfg
+}
@@ -0,0 +1,443 @@
+package ctl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	v1 "mizuflow/pkg/api/v1"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// refreshMargin renews the access token shortly before it expires, so a
+// command does not fail halfway.
+const refreshMargin = 30 * time.Second
+
+var ErrNotLoggedIn = errors.New("not logged in, run mizuctl login")
+
+// APIError is a non-2xx answer of the control plane.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.Status, e.Message)
+}
+
+// Client calls the control plane endpoints of internal/api with the session
+// of the last login, refreshing its tokens as they expire.
+type Client struct {
+	server      string
+	sessionPath string
+	httpClient  *http.Client
+	session     *Session
+}
+
+// NewClient loads the session at sessionPath; a session of another server is ignored.
+func NewClient(server, sessionPath string) (*Client, error) {
+	session, err := loadSession(sessionPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	server = strings.TrimRight(server, "/")
+	if session != nil && session.Server != server {
+		session = nil
+	}
+	return &Client{
+		server:      server,
+		sessionPath: sessionPath,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		session:     session,
+	}, nil
+}
+
+// Username is the user of the session, empty when logged out.
+func (c *Client) Username() string {
+	if c.session == nil {
+		return ""
+	}
+	return c.session.Username
+}
+
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	var tokens resp.TokenResp
+	if err := c.send(ctx, http.MethodPost, "/v1/auth/login", nil, req.LoginReq{Username: username, Password: password}, &tokens, ""); err != nil {
+		return err
+	}
+	return c.store(tokens)
+}
+
+// Logout ends the server session and removes the session file.
+func (c *Client) Logout(ctx context.Context) error {
+	if c.session == nil {
+		return ErrNotLoggedIn
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/auth/logout", nil, nil, nil)
+	c.session = nil
+	if rmErr := os.Remove(c.sessionPath); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		return rmErr
+	}
+	return err
+}
+
+// ListOptions narrows ListFeatures; empty fields match every flag.
+type ListOptions struct {
+	Search          string
+	Owner           string
+	Tag             string
+	IncludeArchived bool
+}
+
+func (c *Client) ListFeatures(ctx context.Context, namespace, env string, opts ListOptions) ([]resp.FeatureItem, error) {
+	var items []resp.FeatureItem
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	for name, v := range map[string]string{"search": opts.Search, "owner": opts.Owner, "tag": opts.Tag} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	if opts.IncludeArchived {
+		q.Set("include_archived", "true")
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/features", q, nil, &items)
+	return items, err
+}
+
+func (c *Client) GetFeature(ctx context.Context, namespace, env, key string) (*resp.FeatureItem, error) {
+	var item resp.FeatureItem
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	if err := c.do(ctx, http.MethodGet, "/v1/feature/"+url.PathEscape(key), q, nil, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SetFeature creates or updates a flag. The answer has its new version or, in
+// a protected environment, the change request the write became.
+func (c *Client) SetFeature(ctx context.Context, r req.CreateFeatureRequest) (*resp.CreateFeatureResponse, error) {
+	var out resp.CreateFeatureResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/feature", nil, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateMetadata replaces the metadata of a flag and returns the flag.
+func (c *Client) UpdateMetadata(ctx context.Context, namespace, env, key string, meta req.FeatureMetadata) (*resp.FeatureItem, error) {
+	var item resp.FeatureItem
+	body := req.UpdateFeatureMetadataRequest{Namespace: namespace, Env: env, FeatureMetadata: meta}
+	if err := c.do(ctx, http.MethodPut, "/v1/feature/"+url.PathEscape(key)+"/metadata", nil, body, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Rollback restores a flag to the value before an audit entry; a non-nil
+// expectedVersion makes the server refuse it with a 409 once the flag moved on.
+// In a protected environment the response holds the change request instead.
+func (c *Client) Rollback(ctx context.Context, namespace, env, key string, auditID uint64, expectedVersion *int) (*resp.RollbackFeatureResponse, error) {
+	var out resp.RollbackFeatureResponse
+	body := req.RollbackFeatureRequest{Namespace: namespace, Env: env, AuditID: auditID, ExpectedVersion: expectedVersion}
+	if err := c.do(ctx, http.MethodPost, "/v1/feature/"+url.PathEscape(key)+"/rollback", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteFeature archives a flag, or with hard set deletes it for good.
+func (c *Client) DeleteFeature(ctx context.Context, namespace, env, key string, hard bool) (*resp.DeleteFeatureResponse, error) {
+	var out resp.DeleteFeatureResponse
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	if hard {
+		q.Set("hard", "true")
+	}
+	if err := c.do(ctx, http.MethodDelete, "/v1/feature/"+url.PathEscape(key), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) RestoreFeature(ctx context.Context, namespace, env, key string) (*resp.RestoreFeatureResponse, error) {
+	var out resp.RestoreFeatureResponse
+	body := req.RestoreFeatureRequest{Namespace: namespace, Env: env}
+	if err := c.do(ctx, http.MethodPost, "/v1/feature/"+url.PathEscape(key)+"/restore", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreateSchedule(ctx context.Context, r req.CreateScheduleRequest) (*resp.CreateScheduleResponse, error) {
+	var out resp.CreateScheduleResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/schedules", nil, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSchedules lists the pending changes of env and namespace, of key only
+// when it is set, and with includeDone the finished ones too.
+func (c *Client) ListSchedules(ctx context.Context, namespace, env, key string, includeDone bool) ([]resp.ScheduleItem, error) {
+	var items []resp.ScheduleItem
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	if key != "" {
+		q.Set("key", key)
+	}
+	if includeDone {
+		q.Set("include_done", "true")
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/schedules", q, nil, &items)
+	return items, err
+}
+
+func (c *Client) CancelSchedule(ctx context.Context, id uint64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/schedules/%d", id), nil, nil, nil)
+}
+
+func (c *Client) CreateRollout(ctx context.Context, r req.CreateRolloutRequest) (*resp.CreateRolloutResponse, error) {
+	var out resp.CreateRolloutResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/rollouts", nil, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRollouts lists the running and paused plans of env and namespace, of key
+// only when it is set, and with includeDone the finished ones too.
+func (c *Client) ListRollouts(ctx context.Context, namespace, env, key string, includeDone bool) ([]resp.RolloutItem, error) {
+	var items []resp.RolloutItem
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	if key != "" {
+		q.Set("key", key)
+	}
+	if includeDone {
+		q.Set("include_done", "true")
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/rollouts", q, nil, &items)
+	return items, err
+}
+
+// RolloutAction pauses, resumes or aborts a plan, action being pause, resume or abort.
+func (c *Client) RolloutAction(ctx context.Context, id uint64, action string) (*resp.RolloutItem, error) {
+	var item resp.RolloutItem
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/rollouts/%d/%s", id, action), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ListChangeRequests lists the pending change requests of env and namespace,
+// of key only when it is set, and with includeDone the closed ones too.
+func (c *Client) ListChangeRequests(ctx context.Context, namespace, env, key string, includeDone bool) ([]resp.ChangeRequestItem, error) {
+	var items []resp.ChangeRequestItem
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	if key != "" {
+		q.Set("key", key)
+	}
+	if includeDone {
+		q.Set("include_done", "true")
+	}
+	err := c.do(ctx, http.MethodGet, "/v1/change-requests", q, nil, &items)
+	return items, err
+}
+
+func (c *Client) GetChangeRequest(ctx context.Context, id uint64) (*resp.ChangeRequestItem, error) {
+	var item resp.ChangeRequestItem
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/change-requests/%d", id), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ReviewChangeRequest approves or rejects a change request, action being
+// approve or reject.
+func (c *Client) ReviewChangeRequest(ctx context.Context, id uint64, action, comment string) (*resp.ChangeRequestItem, error) {
+	var item resp.ChangeRequestItem
+	body := req.ReviewChangeRequest{Comment: comment}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/change-requests/%d/%s", id, action), nil, body, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ApplyChangeRequest writes an approved change request.
+func (c *Client) ApplyChangeRequest(ctx context.Context, id uint64) (*resp.ChangeRequestItem, error) {
+	var item resp.ChangeRequestItem
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/change-requests/%d/apply", id), nil, nil, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (c *Client) Audits(ctx context.Context, namespace, env, key string) ([]resp.AuditLogItem, error) {
+	var items []resp.AuditLogItem
+	q := url.Values{"namespace": {namespace}, "env": {env}}
+	err := c.do(ctx, http.MethodGet, "/v1/feature/"+url.PathEscape(key)+"/audits", q, nil, &items)
+	return items, err
+}
+
+// TailAdmin follows the admin stream of env, an empty env follows all of
+// them, and calls fn for every change until ctx is done or the stream ends.
+func (c *Client) TailAdmin(ctx context.Context, env string, fn func(v1.Message)) error {
+	q := url.Values{}
+	if env != "" {
+		q.Set("env", env)
+	}
+	body, err := c.open(ctx, http.MethodGet, "/v1/admin/stream", q, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var event string
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:") && event == "message":
+			var msg v1.Message
+			if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &msg); err != nil {
+				return fmt.Errorf("malformed stream message: %w", err)
+			}
+			fn(msg)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("admin stream closed by the server")
+}
+
+// do sends an authenticated request and decodes the JSON answer into out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	rc, err := c.open(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(rc).Decode(out)
+}
+
+// open sends an authenticated request, refreshing the tokens before it when
+// they are about to expire and once more when the server refuses them.
+func (c *Client) open(ctx context.Context, method, path string, query url.Values, body any) (io.ReadCloser, error) {
+	if c.session == nil {
+		return nil, ErrNotLoggedIn
+	}
+	if time.Until(c.session.ExpiresAt) < refreshMargin {
+		if err := c.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+	rc, err := c.request(ctx, method, path, query, body, c.session.AccessToken)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		if err := c.refresh(ctx); err != nil {
+			return nil, err
+		}
+		rc, err = c.request(ctx, method, path, query, body, c.session.AccessToken)
+	}
+	return rc, err
+}
+
+func (c *Client) refresh(ctx context.Context) error {
+	var tokens resp.TokenResp
+	err := c.send(ctx, http.MethodPost, "/v1/auth/refresh", nil, req.RefreshReq{RefreshToken: c.session.RefreshToken}, &tokens, "")
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		return fmt.Errorf("session expired, run mizuctl login: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	return c.store(tokens)
+}
+
+func (c *Client) store(tokens resp.TokenResp) error {
+	c.session = &Session{
+		Server:       c.server,
+		Username:     tokens.User.Username,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second),
+	}
+	if err := saveSession(c.sessionPath, c.session); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, out any, token string) error {
+	rc, err := c.request(ctx, method, path, query, body, token)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(out)
+}
+
+// request returns the body of a 2xx answer and an *APIError otherwise. Streams
+// are not bound by the client timeout, their ctx ends them.
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body any, token string) (io.ReadCloser, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	r, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	httpClient := c.httpClient
+	if strings.HasSuffix(path, "/stream") {
+		httpClient = &http.Client{Transport: c.httpClient.Transport}
+	}
+	res, err := httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 != 2 {
+		defer res.Body.Close()
+		var e struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+		}
+		return nil, &APIError{Status: res.StatusCode, Message: e.Error}
+	}
+	return res.Body, nil
+}
@@ -0,0 +1,460 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mizuflow/internal/api"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/metrics"
+	"mizuflow/internal/repository"
+	"mizuflow/internal/service"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	logger.InitLogger("test")
+	gin.SetMode(gin.TestMode)
+}
+
+type fakeFeatures struct {
+	api.FeatureProvider
+	mu    sync.Mutex
+	saved []v1.FeatureFlag
+}
+
+func (f *fakeFeatures) ListFeatures(ctx context.Context, filter repository.FeatureFilter, includeArchived bool) ([]resp.FeatureItem, error) {
+	items := []resp.FeatureItem{{Namespace: filter.Namespace, Env: filter.Env, Key: "checkout", Type: constraints.TypeBool, Value: "true", Version: 3, Owner: "payments"}}
+	if includeArchived {
+		items = append(items, resp.FeatureItem{Namespace: filter.Namespace, Env: filter.Env, Key: "legacy", Type: constraints.TypeBool, Value: "false", Version: 5, Archived: true})
+	}
+	if filter.Owner != "" {
+		items = slices.DeleteFunc(items, func(item resp.FeatureItem) bool { return item.Owner != filter.Owner })
+	}
+	return items, nil
+}
+
+func (f *fakeFeatures) UpdateFeatureMetadata(ctx context.Context, namespace, env, key string, meta req.FeatureMetadata, operator string) (*resp.FeatureItem, error) {
+	if key != "checkout" {
+		return nil, service.ErrFeatureNotFound
+	}
+	if meta.Owner == "invalid" {
+		return nil, fmt.Errorf("%w: owner is longer than 64 characters", service.ErrInvalidMetadata)
+	}
+	return &resp.FeatureItem{Namespace: namespace, Env: env, Key: key, Version: 3, Description: meta.Description, Owner: meta.Owner, Tags: meta.Tags, ExpiresAt: meta.ExpiresAt}, nil
+}
+
+func (f *fakeFeatures) GetFeature(ctx context.Context, namespace, env, key string) (*resp.FeatureItem, error) {
+	if key != "checkout" {
+		return nil, service.ErrFeatureNotFound
+	}
+	return &resp.FeatureItem{Namespace: namespace, Env: env, Key: key, Type: constraints.TypeBool, Value: "true", Version: 3}, nil
+}
+
+func (f *fakeFeatures) DeleteFeature(ctx context.Context, namespace, env, key string, hard bool, operator string) (int, error) {
+	if hard {
+		return 0, errors.New("hard delete refused")
+	}
+	return 6, nil
+}
+
+func (f *fakeFeatures) SaveFeature(ctx context.Context, flag v1.FeatureFlag, meta *req.FeatureMetadata, expectedVersion *int, operator string) (int, error) {
+	if flag.Key == "broken" {
+		return 0, errors.New("etcd unavailable")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if expectedVersion != nil && *expectedVersion != len(f.saved) {
+		return 0, &service.VersionConflictError{Expected: *expectedVersion, Current: len(f.saved), CurrentValue: f.saved[len(f.saved)-1].Value}
+	}
+	f.saved = append(f.saved, flag)
+	return len(f.saved), nil
+}
+
+// fakeChanges protects prod
+type fakeChanges struct {
+	api.ChangeGate
+}
+
+func (fakeChanges) Protects(env string) bool { return env == "prod" }
+
+func (fakeChanges) CreateChangeRequest(ctx context.Context, r req.CreateFeatureRequest, operator string) (*resp.ChangeRequestItem, error) {
+	return &resp.ChangeRequestItem{ID: 1, Env: r.Env, Key: r.Key, Action: "update", Value: r.Value, Reason: r.Reason, Status: "pending", RequiredApprovals: 2, CreatedBy: operator}, nil
+}
+
+func (fakeChanges) ProposeDelete(ctx context.Context, namespace, env, key string, hard bool, operator string) (*resp.ChangeRequestItem, error) {
+	return &resp.ChangeRequestItem{ID: 2, Env: env, Key: key, Action: "archive", Status: "pending", RequiredApprovals: 2, CreatedBy: operator}, nil
+}
+
+func (fakeChanges) ProposeSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string) (*resp.ChangeRequestItem, error) {
+	return &resp.ChangeRequestItem{ID: 3, Env: r.Env, Key: r.Key, Action: "schedule", Value: r.Value, Status: "pending", RequiredApprovals: 2, CreatedBy: operator}, nil
+}
+
+type fakeSchedules struct {
+	api.ScheduleProvider
+	created []req.CreateScheduleRequest
+}
+
+func (f *fakeSchedules) CreateSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string) (*resp.ScheduleItem, error) {
+	if r.Value == "yes" {
+		return nil, fmt.Errorf("%w: invalid boolean value", service.ErrInvalidSchedule)
+	}
+	f.created = append(f.created, r)
+	return &resp.ScheduleItem{ID: uint64(len(f.created)), Key: r.Key, Value: r.Value, RunAt: r.RunAt, Status: "pending", CreatedBy: operator}, nil
+}
+
+func (f *fakeSchedules) CancelSchedule(ctx context.Context, id uint64, operator string) error {
+	if id > uint64(len(f.created)) {
+		return service.ErrScheduleNotFound
+	}
+	return nil
+}
+
+// authServer issues tokens like AuthService, rotating the refresh token on
+// every refresh, and guards the feature routes with them.
+type authServer struct {
+	mu        sync.Mutex
+	n         int
+	access    string
+	refresh   string
+	expiresIn int64
+	refreshes int
+}
+
+func (a *authServer) issue(c *gin.Context) {
+	a.n++
+	a.access = fmt.Sprintf("access-%d", a.n)
+	a.refresh = fmt.Sprintf("refresh-%d", a.n)
+	c.JSON(200, resp.TokenResp{AccessToken: a.access, RefreshToken: a.refresh, ExpiresIn: a.expiresIn, User: resp.UserInfo{Username: "alice"}})
+}
+
+func (a *authServer) login(c *gin.Context) {
+	var body req.LoginReq
+	if err := c.ShouldBindJSON(&body); err != nil || body.Password != "secret" {
+		c.JSON(401, gin.H{"error": "invalid username or password"})
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.issue(c)
+}
+
+func (a *authServer) refreshTokens(c *gin.Context) {
+	var body req.RefreshReq
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := c.ShouldBindJSON(&body); err != nil || body.RefreshToken != a.refresh {
+		c.JSON(401, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	a.refreshes++
+	a.issue(c)
+}
+
+func (a *authServer) guard(c *gin.Context) {
+	a.mu.Lock()
+	ok := c.GetHeader("Authorization") == "Bearer "+a.access
+	a.mu.Unlock()
+	if !ok {
+		c.AbortWithStatusJSON(401, gin.H{"error": "Invalid access token"})
+		return
+	}
+	c.Next()
+}
+
+// revoke invalidates the access token as an expiry on the server would.
+func (a *authServer) revoke() {
+	a.mu.Lock()
+	a.access = "revoked"
+	a.mu.Unlock()
+}
+
+func newServer(t *testing.T, auth *authServer) (*fakeFeatures, *service.Hub, *httptest.Server) {
+	features, hub, srv, _ := newServerWithSchedules(t, auth)
+	return features, hub, srv
+}
+
+func newServerWithSchedules(t *testing.T, auth *authServer) (*fakeFeatures, *service.Hub, *httptest.Server, *fakeSchedules) {
+	t.Helper()
+	hub := service.NewHub(metrics.NewPrometheusObserver(), time.Second, 64)
+	go hub.Run()
+	features := &fakeFeatures{}
+	featureHandler := api.NewFeatureHandler(features, fakeChanges{}, hub)
+	schedules := &fakeSchedules{}
+	scheduleHandler := api.NewScheduleHandler(schedules, fakeChanges{})
+	streamHandler := api.NewStreamHandler(nil, hub)
+
+	e := gin.New()
+	e.POST("/v1/auth/login", auth.login)
+	e.POST("/v1/auth/refresh", auth.refreshTokens)
+	protected := e.Group("/v1", auth.guard)
+	protected.GET("/features", featureHandler.ListFeatures)
+	protected.POST("/feature", featureHandler.CreateFeature)
+	protected.GET("/feature/:key", featureHandler.GetFeature)
+	protected.DELETE("/feature/:key", featureHandler.DeleteFeature)
+	protected.PUT("/feature/:key/metadata", featureHandler.UpdateFeatureMetadata)
+	protected.GET("/admin/stream", streamHandler.DashboardWatch)
+	protected.POST("/schedules", scheduleHandler.CreateSchedule)
+	protected.DELETE("/schedules/:id", scheduleHandler.CancelSchedule)
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	return features, hub, srv, schedules
+}
+
+func TestClientSession(t *testing.T) {
+	auth := &authServer{expiresIn: 900}
+	_, _, srv := newServer(t, auth)
+	sessionPath := filepath.Join(t.TempDir(), "mizuctl", "session.json")
+	ctx := context.Background()
+
+	c, err := NewClient(srv.URL, sessionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListFeatures(ctx, "default", "dev", ListOptions{}); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("ListFeatures() before login error = %v, want ErrNotLoggedIn", err)
+	}
+	var apiErr *APIError
+	if err := c.Login(ctx, "alice", "wrong"); !errors.As(err, &apiErr) || apiErr.Status != 401 {
+		t.Fatalf("Login() with a wrong password error = %v, want a 401", err)
+	}
+	if err := c.Login(ctx, "alice", "secret"); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if info, err := os.Stat(sessionPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("session file = %v, %v, want mode 0600", info, err)
+	}
+
+	// a later command picks the session up from the file
+	c, _ = NewClient(srv.URL+"/", sessionPath)
+	items, err := c.ListFeatures(ctx, "default", "dev", ListOptions{})
+	if err != nil || len(items) != 1 || items[0].Key != "checkout" {
+		t.Fatalf("ListFeatures() = %v, %v, want checkout", items, err)
+	}
+
+	// a refused access token is refreshed once and the request retried
+	auth.revoke()
+	if _, err := c.ListFeatures(ctx, "default", "dev", ListOptions{}); err != nil {
+		t.Fatalf("ListFeatures() after revocation error = %v", err)
+	}
+	if auth.refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", auth.refreshes)
+	}
+	saved, _ := loadSession(sessionPath)
+	if saved.RefreshToken != auth.refresh {
+		t.Errorf("saved refresh token = %s, want the rotated %s", saved.RefreshToken, auth.refresh)
+	}
+
+	// another server does not get this session
+	other, _ := NewClient("http://localhost:1", sessionPath)
+	if other.Username() != "" {
+		t.Error("a session must only be used against its own server")
+	}
+}
+
+func TestClientRefreshBeforeExpiry(t *testing.T) {
+	auth := &authServer{expiresIn: 1}
+	_, _, srv := newServer(t, auth)
+	c, _ := NewClient(srv.URL, filepath.Join(t.TempDir(), "session.json"))
+	ctx := context.Background()
+	if err := c.Login(ctx, "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListFeatures(ctx, "default", "dev", ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if auth.refreshes != 1 {
+		t.Errorf("refreshes = %d, want a refresh ahead of the expiry", auth.refreshes)
+	}
+
+	// with the refresh token gone as well, the user has to log in again
+	auth.mu.Lock()
+	auth.refresh = "gone"
+	auth.mu.Unlock()
+	if _, err := c.ListFeatures(ctx, "default", "dev", ListOptions{}); err == nil || !strings.Contains(err.Error(), "login") {
+		t.Errorf("ListFeatures() with an expired session error = %v, want a hint to log in", err)
+	}
+}
+
+func TestClientFeatures(t *testing.T) {
+	auth := &authServer{expiresIn: 900}
+	features, hub, srv := newServer(t, auth)
+	c, _ := NewClient(srv.URL, filepath.Join(t.TempDir(), "session.json"))
+	ctx := context.Background()
+	if err := c.Login(ctx, "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := c.SetFeature(ctx, req.CreateFeatureRequest{Namespace: "default", Env: "dev", Key: "checkout", Value: "false", Type: constraints.TypeBool})
+	if err != nil || out.Version != 1 || out.ChangeRequest != nil {
+		t.Fatalf("SetFeature() = %+v, %v, want version 1", out, err)
+	}
+	if got := features.saved[0]; got.Key != "checkout" || got.Value != "false" || got.Type != constraints.TypeBool {
+		t.Errorf("saved flag = %+v", got)
+	}
+	var apiErr *APIError
+	_, err = c.SetFeature(ctx, req.CreateFeatureRequest{Namespace: "default", Env: "dev", Key: "broken", Value: "x", Type: constraints.TypeString})
+	if !errors.As(err, &apiErr) || apiErr.Status != 500 || apiErr.Message != "etcd unavailable" {
+		t.Errorf("SetFeature() error = %v, want the server error", err)
+	}
+
+	// a write based on an old version is refused with the current one
+	stale := 0
+	_, err = c.SetFeature(ctx, req.CreateFeatureRequest{Namespace: "default", Env: "dev", Key: "checkout", Value: "true", Type: constraints.TypeBool, ExpectedVersion: &stale})
+	if !errors.As(err, &apiErr) || apiErr.Status != 409 || !strings.Contains(apiErr.Message, "current version is 1") {
+		t.Errorf("SetFeature() with a stale version error = %v, want a 409", err)
+	}
+	current := 1
+	if out, err := c.SetFeature(ctx, req.CreateFeatureRequest{Namespace: "default", Env: "dev", Key: "checkout", Value: "true", Type: constraints.TypeBool, ExpectedVersion: &current}); err != nil || out.Version != 2 {
+		t.Errorf("SetFeature() with the current version = %+v, %v, want version 2", out, err)
+	}
+
+	// a write to a protected environment only opens a change request
+	out, err = c.SetFeature(ctx, req.CreateFeatureRequest{Namespace: "default", Env: "prod", Key: "checkout", Value: "true", Type: constraints.TypeBool, Reason: "launch"})
+	if err != nil || out.ChangeRequest == nil || out.ChangeRequest.Reason != "launch" || len(features.saved) != 2 {
+		t.Errorf("SetFeature() to prod = %+v, %v, want a change request and no write", out, err)
+	}
+
+	if item, err := c.GetFeature(ctx, "default", "dev", "checkout"); err != nil || item.Type != constraints.TypeBool {
+		t.Errorf("GetFeature() = %+v, %v, want the bool flag", item, err)
+	}
+	if items, err := c.ListFeatures(ctx, "default", "dev", ListOptions{IncludeArchived: true}); err != nil || len(items) != 2 || !items[1].Archived {
+		t.Errorf("ListFeatures() with archived = %+v, %v, want the archived flag too", items, err)
+	}
+	if items, err := c.ListFeatures(ctx, "default", "dev", ListOptions{Owner: "growth"}); err != nil || len(items) != 0 {
+		t.Errorf("ListFeatures() of another owner = %+v, %v, want none", items, err)
+	}
+	expires := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)
+	item, err := c.UpdateMetadata(ctx, "default", "dev", "checkout", req.FeatureMetadata{Description: "new checkout", Owner: "payments", Tags: []string{"checkout", "q1"}, ExpiresAt: &expires})
+	if err != nil || item.Owner != "payments" || len(item.Tags) != 2 || item.ExpiresAt == nil || !item.ExpiresAt.Equal(expires) || item.Version != 3 {
+		t.Errorf("UpdateMetadata() = %+v, %v", item, err)
+	}
+	if _, err := c.UpdateMetadata(ctx, "default", "dev", "nope", req.FeatureMetadata{Owner: "payments"}); !errors.As(err, &apiErr) || apiErr.Status != 404 {
+		t.Errorf("UpdateMetadata() of an unknown flag error = %v, want a 404", err)
+	}
+	if _, err := c.UpdateMetadata(ctx, "default", "dev", "checkout", req.FeatureMetadata{Owner: "invalid"}); !errors.As(err, &apiErr) || apiErr.Status != 400 {
+		t.Errorf("UpdateMetadata() with invalid metadata error = %v, want a 400", err)
+	}
+	if out, err := c.DeleteFeature(ctx, "default", "dev", "checkout", false); err != nil || out.Version != 6 {
+		t.Errorf("DeleteFeature() = %+v, %v, want version 6", out, err)
+	}
+	if out, err := c.DeleteFeature(ctx, "default", "prod", "checkout", false); err != nil || out.ChangeRequest == nil || out.ChangeRequest.Action != "archive" {
+		t.Errorf("DeleteFeature() in prod = %+v, %v, want an archive change request", out, err)
+	}
+	if _, err := c.DeleteFeature(ctx, "default", "dev", "checkout", true); !errors.As(err, &apiErr) || apiErr.Message != "hard delete refused" {
+		t.Errorf("DeleteFeature(hard) error = %v, want the server error", err)
+	}
+
+	tailCtx, cancel := context.WithCancel(ctx)
+	received := make(chan v1.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.TailAdmin(tailCtx, "dev", func(msg v1.Message) {
+			select {
+			case received <- msg:
+			default:
+			}
+			cancel()
+		})
+	}()
+	// the hub drops messages while nobody is registered, keep publishing
+	for sent := false; !sent; {
+		hub.Broadcast <- v1.Message{Env: "dev", Namespace: "default", Key: "checkout", Value: "true", Revision: 7, Action: constraints.PUT}
+		select {
+		case msg := <-received:
+			if msg.Key != "checkout" || msg.Revision != 7 {
+				t.Errorf("TailAdmin() message = %+v", msg)
+			}
+			sent = true
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("TailAdmin() error = %v after cancel, want nil", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("TailAdmin() did not return after cancel")
+	}
+}
+
+func TestClientSchedules(t *testing.T) {
+	auth := &authServer{expiresIn: 900}
+	_, _, srv, schedules := newServerWithSchedules(t, auth)
+	c, _ := NewClient(srv.URL, filepath.Join(t.TempDir(), "session.json"))
+	ctx := context.Background()
+	if err := c.Login(ctx, "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	runAt := time.Date(2026, 11, 27, 0, 0, 0, 0, time.FixedZone("JST", 9*3600))
+	out, err := c.CreateSchedule(ctx, req.CreateScheduleRequest{Namespace: "default", Env: "dev", Key: "black-friday-banner", Value: "true", RunAt: runAt})
+	if err != nil || out.ScheduleItem == nil || out.ID != 1 || out.Status != "pending" || !out.RunAt.Equal(runAt) {
+		t.Fatalf("CreateSchedule() = %+v, %v", out, err)
+	}
+	if got := schedules.created[0]; got.Type != "" || !got.RunAt.Equal(runAt) {
+		t.Errorf("server got %+v, want no type and the run time", got)
+	}
+	// in a protected environment the change is proposed instead
+	out, err = c.CreateSchedule(ctx, req.CreateScheduleRequest{Namespace: "default", Env: "prod", Key: "black-friday-banner", Value: "true", RunAt: runAt})
+	if err != nil || out.ScheduleItem != nil || out.ChangeRequest == nil || out.ChangeRequest.Action != "schedule" || len(schedules.created) != 1 {
+		t.Errorf("CreateSchedule() in prod = %+v, %v, want a change request and no schedule", out, err)
+	}
+	var apiErr *APIError
+	if _, err := c.CreateSchedule(ctx, req.CreateScheduleRequest{Namespace: "default", Env: "prod", Key: "black-friday-banner", Value: "true"}); !errors.As(err, &apiErr) || apiErr.Status != 400 {
+		t.Errorf("CreateSchedule() without a time error = %v, want a 400", err)
+	}
+	if _, err := c.CreateSchedule(ctx, req.CreateScheduleRequest{Namespace: "default", Env: "dev", Key: "black-friday-banner", Value: "yes", RunAt: runAt}); !errors.As(err, &apiErr) || apiErr.Status != 400 {
+		t.Errorf("CreateSchedule() with an invalid value error = %v, want a 400", err)
+	}
+
+	if err := c.CancelSchedule(ctx, 1); err != nil {
+		t.Errorf("CancelSchedule() error = %v", err)
+	}
+	if err := c.CancelSchedule(ctx, 7); !errors.As(err, &apiErr) || apiErr.Status != 404 {
+		t.Errorf("CancelSchedule() of an unknown change error = %v, want a 404", err)
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		typ, value, want string
+		wantErr          bool
+	}{
+		{constraints.TypeBool, "true", "true", false},
+		{constraints.TypeBool, "yes", "", true},
+		{constraints.TypeNumber, "-2.5", "-2.5", false},
+		{constraints.TypeNumber, "ten", "", true},
+		{constraints.TypeString, " spaced ", " spaced ", false},
+		{constraints.TypeJSON, "{\n  \"a\": [1, 2]\n}\n", `{"a":[1,2]}`, false},
+		{constraints.TypeJSON, "{", "", true},
+		{constraints.TypeStrategy, `{"default_value": "off", "rules": []}`, `{"default_value":"off","rules":[]}`, false},
+		{constraints.TypeStrategy, `{"default": "off"}`, "", true},
+		{"yaml", "a: b", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeValue(tt.typ, tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeValue(%s, %q) = %q, %v, want %q, error %v", tt.typ, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
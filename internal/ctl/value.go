@@ -0,0 +1,45 @@
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"strconv"
+	"strings"
+)
+
+// NormalizeValue checks a value against its flag type before it is sent, and
+// compacts JSON so values read from indented files are stored the way the
+// console stores them.
+func NormalizeValue(typ, value string) (string, error) {
+	switch typ {
+	case constraints.TypeBool:
+		if value != "true" && value != "false" {
+			return "", fmt.Errorf("bool value must be true or false, got %q", value)
+		}
+	case constraints.TypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", fmt.Errorf("invalid number %q", value)
+		}
+	case constraints.TypeJSON, constraints.TypeStrategy:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(strings.TrimSpace(value))); err != nil {
+			return "", fmt.Errorf("invalid %s value: %w", typ, err)
+		}
+		if typ == constraints.TypeStrategy {
+			var strategy v1.FeatureStrategy
+			dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&strategy); err != nil {
+				return "", fmt.Errorf("invalid strategy: %w", err)
+			}
+		}
+		return buf.String(), nil
+	case constraints.TypeString:
+	default:
+		return "", fmt.Errorf("unknown flag type %q", typ)
+	}
+	return value, nil
+}
@@ -0,0 +1,6 @@
+package req
+
+// ReviewChangeRequest approves or rejects a change request.
+type ReviewChangeRequest struct {
+	Comment string `json:"comment"`
+}
@@ -1,21 +1,62 @@
 package req
 
+import "time"
+
 type CreateFeatureRequest struct {
 	Namespace string `json:"namespace" binding:"required"`
 	Env       string `json:"env" binding:"required"`
 	Key       string `json:"key" binding:"required"`
 	Value     string `json:"value" binding:"required"`
 	Type      string `json:"type" binding:"required"`
+	// ExpectedVersion, when set, is the version the change was based on; 0 for a new flag
+	ExpectedVersion *int `json:"expected_version,omitempty"`
+	// Reason explains the change to the approvers of a protected environment
+	Reason string `json:"reason,omitempty"`
+	// any metadata field in the body replaces the metadata of the flag as a whole,
+	// without them it is left alone
+	*FeatureMetadata
+}
+
+// FeatureMetadata describes a flag for the people working with it. It is kept
+// in MySQL only, SDKs never see it.
+type FeatureMetadata struct {
+	Description string   `json:"description"`
+	Owner       string   `json:"owner"` // user or team
+	Tags        []string `json:"tags"`
+	// ExpiresAt is when the flag should be removed, nil for a permanent flag
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// UpdateFeatureMetadataRequest replaces the metadata of a flag.
+type UpdateFeatureMetadataRequest struct {
+	Namespace string `json:"namespace" binding:"required"`
+	Env       string `json:"env" binding:"required"`
+	FeatureMetadata
 }
 
+// GetFeatureRequest is bound from the query; the key comes from the path, a
+// separate uri binding would validate the query fields before they are bound.
 type GetFeatureRequest struct {
 	Namespace string `form:"namespace" binding:"required"`
 	Env       string `form:"env" binding:"required"`
-	Key       string `uri:"key" binding:"required"`
 }
 
 type RollbackFeatureRequest struct {
 	Namespace string `json:"namespace" binding:"required"`
 	Env       string `json:"env" binding:"required"`
 	AuditID   uint64 `json:"audit_id" binding:"required"`
+	// ExpectedVersion, when set, is the version the rollback was based on
+	ExpectedVersion *int `json:"expected_version,omitempty"`
+}
+
+type DeleteFeatureRequest struct {
+	Namespace string `form:"namespace" binding:"required"`
+	Env       string `form:"env" binding:"required"`
+	// Hard removes the record instead of archiving it
+	Hard bool `form:"hard"`
+}
+
+type RestoreFeatureRequest struct {
+	Namespace string `json:"namespace" binding:"required"`
+	Env       string `json:"env" binding:"required"`
 }
@@ -0,0 +1,16 @@
+package req
+
+type CreateRolloutRequest struct {
+	Namespace string `json:"namespace" binding:"required"`
+	Env       string `json:"env" binding:"required"`
+	Key       string `json:"key" binding:"required"`
+	// RuleID names the mod rule of the strategy the plan drives
+	RuleID string        `json:"rule_id" binding:"required"`
+	Steps  []RolloutStep `json:"steps" binding:"required"`
+}
+
+type RolloutStep struct {
+	Percentage int `json:"percentage"`
+	// Duration is how long the step holds, e.g. "6h"; the last step has none
+	Duration string `json:"duration,omitempty"`
+}
@@ -0,0 +1,13 @@
+package req
+
+import "time"
+
+type CreateScheduleRequest struct {
+	Namespace string `json:"namespace" binding:"required"`
+	Env       string `json:"env" binding:"required"`
+	Key       string `json:"key" binding:"required"`
+	Value     string `json:"value" binding:"required"`
+	// Type defaults to the current type of the flag
+	Type  string    `json:"type"`
+	RunAt time.Time `json:"run_at" binding:"required"`
+}
@@ -0,0 +1,40 @@
+package resp
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ChangeRequestItem struct {
+	ID        uint64 `json:"id"`
+	Namespace string `json:"namespace"`
+	Env       string `json:"env"`
+	Key       string `json:"key"`
+	Action    string `json:"action"` // update, archive, delete, restore, schedule or rollout
+	Value     string `json:"value"`
+	Type      string `json:"type"`
+	// Metadata is the metadata the change replaces, absent when it keeps it
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Payload is the scheduled change or rollout plan the change creates
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	OldValue    string          `json:"old_value"`
+	BaseVersion int             `json:"base_version"`
+	// Diff is a line diff of the old and the proposed value, JSON indented
+	Diff              string               `json:"diff"`
+	Reason            string               `json:"reason"`
+	Status            string               `json:"status"` // pending, applying, applied, rejected or failed
+	RequiredApprovals int                  `json:"required_approvals"`
+	Approvals         []ChangeApprovalItem `json:"approvals"`
+	Version           int                  `json:"version,omitempty"`
+	Result            string               `json:"result,omitempty"`
+	CreatedBy         string               `json:"created_by"`
+	UpdatedBy         string               `json:"updated_by,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+}
+
+type ChangeApprovalItem struct {
+	Approver  string    `json:"approver"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
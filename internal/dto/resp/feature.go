@@ -6,8 +6,11 @@ import (
 	v1 "mizuflow/pkg/api/v1"
 )
 
+// CreateFeatureResponse has the new version, or for a protected environment
+// the change request the write became (202).
 type CreateFeatureResponse struct {
-	Version int `json:"version"`
+	Version       int                `json:"version"`
+	ChangeRequest *ChangeRequestItem `json:"change_request,omitempty"`
 }
 
 type GetFeatureResponse struct {
@@ -15,12 +18,34 @@ type GetFeatureResponse struct {
 }
 
 type RollbackFeatureResponse struct {
-	Version int `json:"version"`
+	Version       int                `json:"version"`
+	ChangeRequest *ChangeRequestItem `json:"change_request,omitempty"`
 }
 
+// VersionConflictResponse answers a write with a stale expected_version (409).
+type VersionConflictResponse struct {
+	Error          string `json:"error"`
+	CurrentVersion int    `json:"current_version"`
+	CurrentValue   string `json:"current_value"`
+}
+
+type DeleteFeatureResponse struct {
+	Version       int                `json:"version"`
+	ChangeRequest *ChangeRequestItem `json:"change_request,omitempty"`
+}
+
+type RestoreFeatureResponse struct {
+	Version       int                `json:"version"`
+	ChangeRequest *ChangeRequestItem `json:"change_request,omitempty"`
+}
+
+// SnapshotResponse is either the full set of flags or, when Incremental is set,
+// the changes after the requested since_rev.
 type SnapshotResponse struct {
-	Data     []v1.FeatureFlag `json:"data"`
-	Revision int64            `json:"revision"`
+	Data        []v1.FeatureFlag `json:"data"`
+	Changes     []v1.Message     `json:"changes,omitempty"`
+	Incremental bool             `json:"incremental,omitempty"`
+	Revision    int64            `json:"revision"`
 }
 
 type FeatureItem struct {
@@ -31,8 +56,14 @@ type FeatureItem struct {
 	Type      string    `json:"type"`
 	Version   int       `json:"version"`
 	Value     string    `json:"value"`
+	Archived  bool      `json:"archived"`
 	UpdatedAt time.Time `json:"updated_at"`
 	UpdatedBy string    `json:"updated_by"`
+
+	Description string     `json:"description"`
+	Owner       string     `json:"owner"`
+	Tags        []string   `json:"tags"`
+	ExpiresAt   *time.Time `json:"expires_at"`
 }
 
 type AuditLogItem struct {
@@ -43,6 +74,17 @@ type AuditLogItem struct {
 	OldValue  string    `json:"old_value"`
 	NewValue  string    `json:"new_value"`
 	Type      string    `json:"type"`
+	Action    string    `json:"action"`
 	Operator  string    `json:"operator"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type ExposureItem struct {
+	Env        string           `json:"env"`
+	Namespace  string           `json:"namespace"`
+	Key        string           `json:"key"`
+	Total      int64            `json:"total"`
+	Variants   map[string]int64 `json:"variants"`
+	Reasons    map[string]int64 `json:"reasons"`
+	LastSeenAt time.Time        `json:"last_seen_at"`
+}
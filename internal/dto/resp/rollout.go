@@ -0,0 +1,38 @@
+package resp
+
+import "time"
+
+type RolloutItem struct {
+	ID        uint64        `json:"id"`
+	Namespace string        `json:"namespace"`
+	Env       string        `json:"env"`
+	Key       string        `json:"key"`
+	RuleID    string        `json:"rule_id"`
+	Steps     []RolloutStep `json:"steps"`
+	Step      int           `json:"step"`
+	// Percentage is the threshold the plan has set the rule to
+	Percentage        int        `json:"percentage"`
+	InitialPercentage int        `json:"initial_percentage"`
+	Status            string     `json:"status"` // running, paused, completed, aborted or failed
+	NextAt            *time.Time `json:"next_at,omitempty"`
+	Remaining         string     `json:"remaining,omitempty"`
+	Result            string     `json:"result,omitempty"`
+	// ChangeRequestID is the change request that approved the plan, if any
+	ChangeRequestID uint64    `json:"change_request_id,omitempty"`
+	CreatedBy       string    `json:"created_by"`
+	UpdatedBy       string    `json:"updated_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type RolloutStep struct {
+	Percentage int    `json:"percentage"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+// CreateRolloutResponse is the started plan, or in a protected environment
+// the change request that starts it once approved.
+type CreateRolloutResponse struct {
+	*RolloutItem
+	ChangeRequest *ChangeRequestItem `json:"change_request,omitempty"`
+}
@@ -0,0 +1,28 @@
+package resp
+
+import "time"
+
+type ScheduleItem struct {
+	ID        uint64    `json:"id"`
+	Namespace string    `json:"namespace"`
+	Env       string    `json:"env"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	Type      string    `json:"type"`
+	RunAt     time.Time `json:"run_at"`
+	Status    string    `json:"status"` // pending, applying, applied, failed or canceled
+	Version   int       `json:"version,omitempty"`
+	Result    string    `json:"result,omitempty"`
+	// ChangeRequestID is the change request that approved the change, if any
+	ChangeRequestID uint64    `json:"change_request_id,omitempty"`
+	CreatedBy       string    `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// CreateScheduleResponse is the scheduled change, or in a protected
+// environment the change request that schedules it once approved.
+type CreateScheduleResponse struct {
+	*ScheduleItem
+	ChangeRequest *ChangeRequestItem `json:"change_request,omitempty"`
+}
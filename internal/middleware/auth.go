@@ -30,3 +30,24 @@ func SDKAuthMiddleware(repo repository.SDKRepository, bypassAuth bool) gin.Handl
 		c.Next()
 	}
 }
+
+// RelayAuthMiddleware only admits keys marked as relay keys for the requested env
+func RelayAuthMiddleware(repo repository.RelayKeyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-Mizu-Key")
+		env := c.Query("env")
+
+		if apiKey == "" {
+			c.AbortWithStatusJSON(401, gin.H{"error": "missing API key"})
+			return
+		}
+
+		ok, err := repo.ValidateRelayKey(c.Request.Context(), apiKey, env)
+		if err != nil || !ok {
+			c.AbortWithStatusJSON(403, gin.H{"error": "forbidden"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -10,8 +10,26 @@ type FeatureAudit struct {
 	OldValue  string    `json:"old_value" gorm:"type:text"`
 	NewValue  string    `json:"new_value" gorm:"type:text"`
 	Type      string    `json:"type" gorm:"size:32"`
+	Action    string    `json:"action" gorm:"size:16;default:update"`
 	Operator  string    `json:"operator" gorm:"size:64"`
 	TraceID   string    `json:"trace_id" gorm:"size:36;index"`
 	IP        string    `json:"ip" gorm:"size:45"`
 	CreatedAt time.Time `json:"created_at" gorm:"index"`
 }
+
+// Audit actions; an empty action is stored as an update.
+const (
+	AuditActionUpdate  = "update"
+	AuditActionArchive = "archive"
+	AuditActionRestore = "restore"
+	AuditActionDelete  = "delete"
+	// metadata entries hold the old and new metadata as JSON
+	AuditActionMetadata = "metadata"
+	// a scheduled change whose write was refused, with the value it would have set
+	AuditActionScheduleFailed = "schedule_failed"
+	// change request steps, with the value before and the proposed value
+	AuditActionChangeRequested = "change_requested"
+	AuditActionChangeApproved  = "change_approved"
+	AuditActionChangeRejected  = "change_rejected"
+	AuditActionChangeFailed    = "change_failed"
+)
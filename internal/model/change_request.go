@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// ChangeRequest is a flag write to a protected environment, held until enough
+// users other than its author approved it.
+type ChangeRequest struct {
+	ID        uint64 `json:"id" gorm:"primaryKey"`
+	Namespace string `json:"namespace" gorm:"size:64;index:idx_change_flag"`
+	Env       string `json:"env" gorm:"size:32;index:idx_change_flag"`
+	Key       string `json:"key" gorm:"size:128;index:idx_change_flag"`
+	// Action is what the request does to the flag, one of the ChangeAction
+	// constants
+	Action string `json:"action" gorm:"size:16;default:update"`
+	Value  string `json:"value" gorm:"type:text"`
+	Type   string `json:"type" gorm:"size:32"`
+	// Metadata is the metadata to replace as JSON, empty to leave it alone
+	Metadata string `json:"metadata" gorm:"type:text"`
+	// Payload is the scheduled change or rollout plan to create as JSON
+	Payload string `json:"payload" gorm:"type:text"`
+	// OldValue and BaseVersion are the flag when the change was requested; it
+	// is only applied while the flag is still at BaseVersion
+	OldValue          string `json:"old_value" gorm:"type:text"`
+	BaseVersion       int    `json:"base_version"`
+	Reason            string `json:"reason" gorm:"size:255"`
+	RequiredApprovals int    `json:"required_approvals"`
+	Status            int    `json:"status" gorm:"index"`
+	// Version is the flag version the change produced, Result why it was
+	// rejected or failed, or the scheduled change or rollout plan it created
+	Version   int       `json:"version"`
+	Result    string    `json:"result" gorm:"size:255"`
+	CreatedBy string    `json:"created_by" gorm:"size:64"`
+	UpdatedBy string    `json:"updated_by" gorm:"size:64"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChangeApproval is one user's approval of a change request; a user approves
+// a request once.
+type ChangeApproval struct {
+	ID        uint64    `json:"id" gorm:"primaryKey"`
+	RequestID uint64    `json:"request_id" gorm:"uniqueIndex:idx_approval_user"`
+	Approver  string    `json:"approver" gorm:"size:64;uniqueIndex:idx_approval_user"`
+	Comment   string    `json:"comment" gorm:"size:255"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// An update writes Value and Metadata, a schedule or rollout creates what its
+// Payload holds once approved.
+const (
+	ChangeActionUpdate   = "update"
+	ChangeActionArchive  = "archive"
+	ChangeActionDelete   = "delete"
+	ChangeActionRestore  = "restore"
+	ChangeActionSchedule = "schedule"
+	ChangeActionRollout  = "rollout"
+)
+
+// Like scheduled changes, a request is claimed as applying before its write.
+const (
+	ChangeStatusPending  = 0
+	ChangeStatusApplying = 1
+	ChangeStatusApplied  = 2
+	ChangeStatusRejected = 3
+	ChangeStatusFailed   = 4
+)
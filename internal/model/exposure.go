@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// FeatureExposure counts how often SDKs served a variant of a flag for a given reason.
+type FeatureExposure struct {
+	ID         uint64    `json:"id" gorm:"primaryKey"`
+	Env        string    `json:"env" gorm:"size:32;uniqueIndex:idx_exposure"`
+	Namespace  string    `json:"namespace" gorm:"size:64;uniqueIndex:idx_exposure"`
+	Key        string    `json:"key" gorm:"size:128;uniqueIndex:idx_exposure"`
+	Variant    string    `json:"variant" gorm:"size:64;uniqueIndex:idx_exposure"`
+	Reason     string    `json:"reason" gorm:"size:32;uniqueIndex:idx_exposure"`
+	Hits       int64     `json:"hits"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
@@ -3,13 +3,30 @@ package model
 import "time"
 
 type FeatureMaster struct {
-	ID         uint64    `gorm:"primaryKey" json:"id"`
-	Namespace  string    `gorm:"default:default" json:"namespace"`
-	Env        string    `gorm:"default:dev" json:"env"`
-	Key        string    `json:"key"`
-	Type       string    `json:"type"`
-	Version    int       `json:"version"`
-	CurrentVal string    `json:"value"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	UpdatedBy  string    `json:"updated_by"` // derived
+	ID         uint64 `gorm:"primaryKey" json:"id"`
+	Namespace  string `gorm:"default:default" json:"namespace"`
+	Env        string `gorm:"default:dev" json:"env"`
+	Key        string `json:"key"`
+	Type       string `json:"type"`
+	Version    int    `json:"version"`
+	CurrentVal string `json:"value"`
+	Status     int    `gorm:"default:1" json:"status"`
+	// metadata, edited apart from the value and never published to etcd
+	Description string     `gorm:"size:255" json:"description"`
+	Owner       string     `gorm:"size:64;index" json:"owner"`
+	Tags        []string   `gorm:"serializer:json;type:text" json:"tags"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UpdatedBy   string     `json:"updated_by"` // derived
+}
+
+// Feature status, as stored in feature_master.status. Archived flags are gone
+// from etcd but keep their row, so they can be restored.
+const (
+	FeatureStatusArchived = 0
+	FeatureStatusActive   = 1
+)
+
+func (m *FeatureMaster) Archived() bool {
+	return m.Status == FeatureStatusArchived
 }
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// RolloutPlan raises the mod threshold of one rule of a strategy flag step by
+// step, holding each percentage for the duration of its step.
+type RolloutPlan struct {
+	ID        uint64        `json:"id" gorm:"primaryKey"`
+	Namespace string        `json:"namespace" gorm:"size:64;index:idx_rollout_flag;uniqueIndex:idx_rollout_current,priority:1"`
+	Env       string        `json:"env" gorm:"size:32;index:idx_rollout_flag;uniqueIndex:idx_rollout_current,priority:2"`
+	Key       string        `json:"key" gorm:"size:128;index:idx_rollout_flag;uniqueIndex:idx_rollout_current,priority:3"`
+	RuleID    string        `json:"rule_id" gorm:"size:64"`
+	Steps     []RolloutStep `json:"steps" gorm:"serializer:json;type:text"`
+	// Step is the index of the step in effect
+	Step int `json:"step"`
+	// InitialPercentage is the threshold before the plan, restored on abort
+	InitialPercentage int `json:"initial_percentage"`
+	Status            int `json:"status" gorm:"index:idx_rollout_due,priority:1"`
+	// NextAt is when the next step is due, nil unless the plan is running
+	NextAt *time.Time `json:"next_at" gorm:"index:idx_rollout_due,priority:2"`
+	// Remaining is what was left of the current step when the plan was paused
+	Remaining time.Duration `json:"remaining"`
+	Result    string        `json:"result" gorm:"size:255"`
+	// Current is true while the plan is active and nil after, so the unique
+	// index it is part of allows one active plan per flag
+	Current *bool `json:"-" gorm:"uniqueIndex:idx_rollout_current,priority:4"`
+	// ChangeRequestID is the change request that approved a plan in a
+	// protected environment, 0 for other plans
+	ChangeRequestID uint64    `json:"change_request_id"`
+	CreatedBy       string    `json:"created_by" gorm:"size:64"`
+	UpdatedBy       string    `json:"updated_by" gorm:"size:64"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type RolloutStep struct {
+	Percentage int `json:"percentage"`
+	// Duration holds the step before the next one; the last step has none
+	Duration time.Duration `json:"duration"`
+}
+
+const (
+	RolloutStatusRunning   = 0
+	RolloutStatusPaused    = 1
+	RolloutStatusCompleted = 2
+	RolloutStatusAborted   = 3
+	RolloutStatusFailed    = 4
+)
+
+// Active reports whether the plan still drives its rule.
+func (p *RolloutPlan) Active() bool {
+	return p.Status == RolloutStatusRunning || p.Status == RolloutStatusPaused
+}
+
+// MarkCurrent sets Current from the status before the plan is saved.
+func (p *RolloutPlan) MarkCurrent() {
+	p.Current = nil
+	if p.Active() {
+		current := true
+		p.Current = &current
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// ScheduledChange is a write of a flag value held back until RunAt.
+type ScheduledChange struct {
+	ID        uint64    `json:"id" gorm:"primaryKey"`
+	Namespace string    `json:"namespace" gorm:"size:64;index:idx_schedule_flag"`
+	Env       string    `json:"env" gorm:"size:32;index:idx_schedule_flag"`
+	Key       string    `json:"key" gorm:"size:128;index:idx_schedule_flag"`
+	Value     string    `json:"value" gorm:"type:text"`
+	Type      string    `json:"type" gorm:"size:32"`
+	RunAt     time.Time `json:"run_at" gorm:"index:idx_schedule_due,priority:2"`
+	Status    int       `json:"status" gorm:"index:idx_schedule_due,priority:1"`
+	// Version is the flag version the change produced, Result why it failed
+	Version int    `json:"version"`
+	Result  string `json:"result" gorm:"size:255"`
+	// ChangeRequestID is the change request that approved a change to a
+	// protected environment, 0 for other changes
+	ChangeRequestID uint64    `json:"change_request_id"`
+	CreatedBy       string    `json:"created_by" gorm:"size:64"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// A change is claimed as applying before its write, so a cancel cannot slip in
+// between the write and the status update.
+const (
+	ScheduleStatusPending  = 0
+	ScheduleStatusApplying = 1
+	ScheduleStatusApplied  = 2
+	ScheduleStatusFailed   = 3
+	ScheduleStatusCanceled = 4
+)
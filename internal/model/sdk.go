@@ -6,4 +6,6 @@ type SDKClient struct {
 	APIKey string `gorm:"size:64;not null"`
 	Env    string `gorm:"size:32;default:dev"`
 	Status int    `gorm:"default:1"`
+	// Relay keys may list the keys of their env, so relays can authenticate SDKs.
+	Relay bool `gorm:"not null;default:false"`
 }
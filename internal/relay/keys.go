@@ -0,0 +1,149 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var errKeysNotLoaded = errors.New("sdk key list not loaded")
+
+// KeyCache validates SDK keys against the key list of the upstream servers. The
+// list is refreshed periodically; while upstream is unreachable the last one is
+// kept, in memory and in the cache file. It implements repository.SDKRepository.
+type KeyCache struct {
+	endpoints  []string
+	env        string
+	apiKey     string
+	cacheFile  string
+	httpClient *http.Client
+
+	hashes atomic.Pointer[map[string]struct{}]
+}
+
+func NewKeyCache(endpoints []string, env, apiKey, cacheFile string) *KeyCache {
+	return &KeyCache{
+		endpoints:  endpoints,
+		env:        env,
+		apiKey:     apiKey,
+		cacheFile:  cacheFile,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (k *KeyCache) ValidateAPIKey(ctx context.Context, apiKey, env string) (bool, error) {
+	if env != k.env {
+		return false, nil
+	}
+	hashes := k.hashes.Load()
+	if hashes == nil {
+		return false, errKeysNotLoaded
+	}
+	_, ok := (*hashes)[v1.HashAPIKey(apiKey)]
+	return ok, nil
+}
+
+// Refresh fetches the key list from the first upstream endpoint that answers.
+func (k *KeyCache) Refresh(ctx context.Context) error {
+	var err error
+	for _, endpoint := range k.endpoints {
+		var keys v1.RelayKeys
+		if keys, err = k.fetch(ctx, endpoint); err == nil {
+			k.store(keys)
+			k.save(keys)
+			return nil
+		}
+		logger.Warn("failed to fetch sdk keys", zap.String("endpoint", endpoint), zap.Error(err))
+	}
+	return err
+}
+
+func (k *KeyCache) fetch(ctx context.Context, endpoint string) (v1.RelayKeys, error) {
+	var keys v1.RelayKeys
+	u := fmt.Sprintf("%s/v1/relay/keys?env=%s", endpoint, url.QueryEscape(k.env))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return keys, err
+	}
+	req.Header.Set("X-Mizu-Key", k.apiKey)
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return keys, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return keys, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&keys)
+	return keys, err
+}
+
+func (k *KeyCache) store(keys v1.RelayKeys) {
+	hashes := make(map[string]struct{}, len(keys.KeyHashes))
+	for _, h := range keys.KeyHashes {
+		hashes[h] = struct{}{}
+	}
+	k.hashes.Store(&hashes)
+}
+
+// save persists the hashed keys so a restarted relay can serve before upstream is back.
+func (k *KeyCache) save(keys v1.RelayKeys) {
+	if k.cacheFile == "" {
+		return
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		logger.Error("failed to marshal sdk keys", zap.Error(err))
+		return
+	}
+	tmpFile := k.cacheFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		logger.Error("failed to write sdk key cache", zap.Error(err))
+		return
+	}
+	if err := os.Rename(tmpFile, k.cacheFile); err != nil {
+		logger.Error("failed to rename sdk key cache", zap.Error(err))
+	}
+}
+
+// Load reads the key list persisted by the last successful refresh.
+func (k *KeyCache) Load() error {
+	data, err := os.ReadFile(k.cacheFile)
+	if err != nil {
+		return err
+	}
+	var keys v1.RelayKeys
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if keys.Env != k.env {
+		return fmt.Errorf("sdk key cache is for env %q, not %q", keys.Env, k.env)
+	}
+	k.store(keys)
+	return nil
+}
+
+func (k *KeyCache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Refresh(ctx); err != nil {
+				logger.Warn("failed to refresh sdk keys, keeping the previous list", zap.Error(err))
+			}
+		}
+	}
+}
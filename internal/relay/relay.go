@@ -0,0 +1,150 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mizuflow/client"
+	"mizuflow/internal/api"
+	"mizuflow/internal/metrics"
+	"mizuflow/internal/middleware"
+	"mizuflow/internal/service"
+	"mizuflow/pkg/logger"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	Upstream           []string
+	APIKey             string
+	Env                string
+	Namespaces         []string
+	CacheDir           string
+	KeyRefreshInterval time.Duration
+	HeartbeatInterval  time.Duration
+	HubBufferSize      int
+	RevisionBufferSize int
+}
+
+// Relay holds one upstream watch for its env and namespaces and serves the
+// stream and snapshot endpoints to SDKs with the server protocol, so pods
+// connect to the relay instead of the central servers.
+type Relay struct {
+	cfg      Config
+	hub      *service.Hub
+	store    *Store
+	keys     *KeyCache
+	upstream *client.MizuClient
+}
+
+func New(cfg Config) (*Relay, error) {
+	if len(cfg.Upstream) == 0 || cfg.Env == "" || len(cfg.Namespaces) == 0 {
+		return nil, errors.New("relay needs upstream endpoints, an env and namespaces")
+	}
+	if cfg.KeyRefreshInterval <= 0 {
+		cfg.KeyRefreshInterval = time.Minute
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = 15 * time.Second
+	}
+	if err := os.MkdirAll(cfg.CacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	hub := service.NewHub(metrics.NewPrometheusObserver(), cfg.HeartbeatInterval, cfg.HubBufferSize)
+	store := NewStore(cfg.Env, cfg.Namespaces, cfg.RevisionBufferSize, hub)
+	return &Relay{
+		cfg:   cfg,
+		hub:   hub,
+		store: store,
+		keys:  NewKeyCache(cfg.Upstream, cfg.Env, cfg.APIKey, filepath.Join(cfg.CacheDir, "keys.json")),
+		upstream: client.NewMizuClient(cfg.Upstream[0], cfg.Env, cfg.APIKey, cfg.Namespaces,
+			client.WithEndpoints(cfg.Upstream...),
+			client.WithCacheFile(filepath.Join(cfg.CacheDir, "snapshot.json")),
+			client.WithMirror(store),
+		),
+	}, nil
+}
+
+// Start loads the SDK keys and the flags, from upstream or else from the cache
+// files, and keeps following upstream until ctx is done.
+func (r *Relay) Start(ctx context.Context) error {
+	go r.hub.Run()
+
+	if err := r.keys.Refresh(ctx); err != nil {
+		logger.Warn("failed to fetch sdk keys from upstream, loading the cached list", zap.Error(err))
+		if loadErr := r.keys.Load(); loadErr != nil {
+			return fmt.Errorf("failed to fetch sdk keys: %w, and failed to load them from cache: %w", err, loadErr)
+		}
+	}
+	go r.keys.Run(ctx, r.cfg.KeyRefreshInterval)
+
+	return r.upstream.Start()
+}
+
+func (r *Relay) Close() error {
+	return r.upstream.Close()
+}
+
+func (r *Relay) Handler() *gin.Engine {
+	streamHandler := api.NewStreamHandler(r.store, r.hub)
+
+	e := gin.New()
+	e.Use(
+		middleware.CorsMiddleware(),
+		middleware.RequestID(),
+		middleware.GinZapLogger(),
+		middleware.GinZapRecovery(),
+	)
+	e.GET("/health", r.health)
+	e.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	stream := e.Group("/v1/stream")
+	stream.Use(r.requireReady, middleware.SDKAuthMiddleware(r.keys, false), r.requireScope)
+	{
+		stream.GET("/watch", streamHandler.WatchFeature)
+		stream.GET("/snapshot", streamHandler.FetchAll)
+	}
+	return e
+}
+
+// health stays up while the relay serves a stale snapshot; the state tells
+// whether upstream is reachable.
+func (r *Relay) health(c *gin.Context) {
+	status := 200
+	if !r.store.Ready() {
+		status = 503
+	}
+	c.JSON(status, gin.H{
+		"state":     r.upstream.State().String(),
+		"revision":  r.store.Revision(),
+		"last_sync": r.upstream.LastSync(),
+	})
+}
+
+func (r *Relay) requireReady(c *gin.Context) {
+	if !r.store.Ready() {
+		c.AbortWithStatusJSON(503, gin.H{"error": "relay has no snapshot yet"})
+		return
+	}
+	c.Next()
+}
+
+// requireScope refuses namespaces the relay does not follow; it would serve
+// them as empty instead of sending the client to the servers.
+func (r *Relay) requireScope(c *gin.Context) {
+	for ns := range strings.SplitSeq(c.Query("namespace"), ",") {
+		ns = strings.TrimSpace(ns)
+		if ns != "" && !slices.Contains(r.cfg.Namespaces, ns) {
+			c.AbortWithStatusJSON(404, gin.H{"error": fmt.Sprintf("namespace %s is not relayed", ns)})
+			return
+		}
+	}
+	c.Next()
+}
@@ -0,0 +1,207 @@
+package relay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mizuflow/client"
+	"mizuflow/internal/api"
+	"mizuflow/internal/metrics"
+	"mizuflow/internal/middleware"
+	"mizuflow/internal/service"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	logger.InitLogger("test")
+	gin.SetMode(gin.TestMode)
+}
+
+type fakeKeys map[string]bool
+
+func (k fakeKeys) ValidateAPIKey(ctx context.Context, apiKey, env string) (bool, error) {
+	return k[apiKey], nil
+}
+
+func (k fakeKeys) ValidateRelayKey(ctx context.Context, apiKey, env string) (bool, error) {
+	return apiKey == "relay-key", nil
+}
+
+func (k fakeKeys) ListAPIKeys(ctx context.Context, env string) ([]string, error) {
+	keys := make([]string, 0, len(k))
+	for key := range k {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// newUpstream serves the stream endpoints of a server backed by a Store.
+func newUpstream(t *testing.T, keys fakeKeys) (*Store, *httptest.Server) {
+	t.Helper()
+	hub := service.NewHub(metrics.NewPrometheusObserver(), time.Second, 64)
+	go hub.Run()
+	store := NewStore("dev", []string{"default"}, 100, hub)
+
+	e := gin.New()
+	stream := e.Group("/v1/stream")
+	stream.Use(middleware.SDKAuthMiddleware(keys, false))
+	h := api.NewStreamHandler(store, hub)
+	stream.GET("/watch", h.WatchFeature)
+	stream.GET("/snapshot", h.FetchAll)
+	e.GET("/v1/relay/keys", middleware.RelayAuthMiddleware(keys), api.NewRelayHandler(keys).ListKeys)
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return store, srv
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func put(store *Store, key, value string, rev int64) {
+	store.Apply(v1.Message{Namespace: "default", Env: "dev", Key: key, Value: value, Type: constraints.TypeString, Revision: rev, Action: constraints.PUT})
+}
+
+func TestRelay(t *testing.T) {
+	keys := fakeKeys{"sdk-key": true, "relay-key": true}
+	upstream, upstreamSrv := newUpstream(t, keys)
+	upstream.Reset([]v1.FeatureFlag{{Namespace: "default", Env: "dev", Key: "color", Value: "red", Type: constraints.TypeString, Revision: 1}}, 1)
+
+	cacheDir := t.TempDir()
+	r, err := New(Config{Upstream: []string{upstreamSrv.URL}, APIKey: "relay-key", Env: "dev", Namespaces: []string{"default"}, CacheDir: cacheDir, RevisionBufferSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	relaySrv := httptest.NewServer(r.Handler())
+	defer relaySrv.Close()
+
+	sdk := client.NewMizuClient(relaySrv.URL, "dev", "sdk-key", []string{"default"}, client.WithCacheFile(filepath.Join(t.TempDir(), "cache.json")))
+	if err := sdk.Start(); err != nil {
+		t.Fatalf("sdk Start() error = %v", err)
+	}
+	defer sdk.Close()
+	if got := sdk.GetString("color", "", nil); got != "red" {
+		t.Fatalf("GetString() through the relay = %q, want red", got)
+	}
+
+	put(upstream, "color", "blue", 2)
+	waitFor(t, func() bool { return sdk.GetString("color", "", nil) == "blue" })
+
+	for _, tc := range []struct {
+		key, query string
+		status     int
+	}{
+		{"unknown-key", "env=dev&namespace=default", http.StatusForbidden},
+		{"sdk-key", "env=prod&namespace=default", http.StatusForbidden},
+		{"sdk-key", "env=dev&namespace=payments", http.StatusNotFound},
+		{"sdk-key", "env=dev&namespace=default", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", relaySrv.URL+"/v1/stream/snapshot?"+tc.query, nil)
+		req.Header.Set("X-Mizu-Key", tc.key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("snapshot with key %s and %s = %d, want %d", tc.key, tc.query, resp.StatusCode, tc.status)
+		}
+	}
+
+	// an upstream resync makes the relay reset its clients, which refetch
+	upstream.Reset([]v1.FeatureFlag{{Namespace: "default", Env: "dev", Key: "color", Value: "green", Type: constraints.TypeString, Revision: 10}}, 10)
+	waitFor(t, func() bool { return sdk.GetString("color", "", nil) == "green" })
+
+	// with upstream gone the relay keeps serving what it has
+	upstreamSrv.CloseClientConnections()
+	upstreamSrv.Close()
+	late := client.NewMizuClient(relaySrv.URL, "dev", "sdk-key", []string{"default"}, client.WithCacheFile(filepath.Join(t.TempDir(), "cache.json")))
+	if err := late.Start(); err != nil {
+		t.Fatalf("Start() with upstream down error = %v", err)
+	}
+	defer late.Close()
+	if got := late.GetString("color", "", nil); got != "green" {
+		t.Errorf("GetString() with upstream down = %q, want green", got)
+	}
+
+	// and a restarted relay serves from its cache files
+	r.Close()
+	restarted, err := New(Config{Upstream: []string{upstreamSrv.URL}, APIKey: "relay-key", Env: "dev", Namespaces: []string{"default"}, CacheDir: cacheDir, RevisionBufferSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Start(context.Background()); err != nil {
+		t.Fatalf("Start() from cache error = %v", err)
+	}
+	defer restarted.Close()
+	flags, rev := restarted.store.GetAllFeatures(context.Background())
+	if len(flags) != 1 || flags[0].Value != "green" || rev != 10 {
+		t.Errorf("restarted relay serves %+v at %d, want color=green at 10", flags, rev)
+	}
+	if ok, _ := restarted.keys.ValidateAPIKey(context.Background(), "sdk-key", "dev"); !ok {
+		t.Error("restarted relay should accept keys from its cache")
+	}
+}
+
+func TestStoreCompensation(t *testing.T) {
+	hub := service.NewHub(metrics.NewPrometheusObserver(), time.Second, 64)
+	go hub.Run()
+	store := NewStore("dev", []string{"default"}, 100, hub)
+
+	if _, ok := store.GetCompensation(0); ok {
+		t.Error("a store without snapshot cannot compensate")
+	}
+	store.Reset(nil, 5)
+	put(store, "color", "red", 6)
+
+	if _, ok := store.GetCompensation(3); ok {
+		t.Error("clients behind the snapshot need a reset")
+	}
+	if msgs, ok := store.GetCompensation(5); !ok || len(msgs) != 1 || msgs[0].Revision != 6 {
+		t.Errorf("GetCompensation(5) = %v, %v, want the message at 6", msgs, ok)
+	}
+	if msgs, ok := store.GetCompensation(6); !ok || len(msgs) != 0 {
+		t.Errorf("GetCompensation(6) = %v, %v, want nothing", msgs, ok)
+	}
+}
+
+func TestStoreDoesNotWaitForHub(t *testing.T) {
+	// the hub is not running yet, so nothing takes its broadcasts
+	hub := service.NewHub(metrics.NewPrometheusObserver(), time.Second, 64)
+	store := NewStore("dev", []string{"default", "payments"}, 100, hub)
+
+	done := make(chan struct{})
+	go func() {
+		store.Reset(nil, 5)
+		put(store, "color", "red", 6)
+		store.Reset(nil, 7)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Reset and Apply blocked on the hub")
+	}
+	if store.Revision() != 7 {
+		t.Errorf("Revision() = %d, want 7", store.Revision())
+	}
+}
@@ -0,0 +1,164 @@
+package relay
+
+import (
+	"context"
+	"mizuflow/internal/buffer"
+	"mizuflow/internal/service"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/logger"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Store holds what the relay serves: the upstream flags and the recent messages
+// for last_rev compensation. The upstream client feeds it as a client.Mirror,
+// api.StreamHandler reads it as its StreamProvider.
+type Store struct {
+	env        string
+	namespaces []string
+	bufferSize int
+	hub        *service.Hub
+
+	mu       sync.RWMutex
+	flags    map[string]v1.FeatureFlag // keyed by service.BuildFeatureKey
+	revision int64
+	baseRev  int64 // revision of the last reset, clients behind it need a resync
+	buffer   *buffer.RevisionBuffer
+	ready    bool
+
+	// messages for the hub; forward hands them over, so Reset and Apply never
+	// wait on the hub while the upstream client holds its lock
+	outMu   sync.Mutex
+	out     []v1.Message
+	outWake chan struct{}
+}
+
+func NewStore(env string, namespaces []string, bufferSize int, hub *service.Hub) *Store {
+	s := &Store{
+		env:        env,
+		namespaces: namespaces,
+		bufferSize: bufferSize,
+		hub:        hub,
+		flags:      make(map[string]v1.FeatureFlag),
+		buffer:     buffer.NewRevisionBuffer(bufferSize),
+		outWake:    make(chan struct{}, 1),
+	}
+	go s.forward()
+	return s
+}
+
+// Reset replaces the flags with an upstream snapshot. The messages before it
+// are gone, so connected clients are told to resync.
+func (s *Store) Reset(flags []v1.FeatureFlag, revision int64) {
+	s.mu.Lock()
+	if s.ready && revision == s.revision {
+		// the same revision holds the same flags
+		s.mu.Unlock()
+		return
+	}
+	s.flags = make(map[string]v1.FeatureFlag, len(flags))
+	for _, f := range flags {
+		s.flags[service.BuildFeatureKey(f.Env, f.Namespace, f.Key)] = f
+	}
+	s.revision = revision
+	s.baseRev = revision
+	s.buffer = buffer.NewRevisionBuffer(s.bufferSize)
+	// anchor the buffer at the snapshot so clients at it count as up to date; the
+	// anchor itself is never sent, GetCompensation refuses clients behind it
+	s.buffer.AddMessage(v1.Message{Env: s.env, Revision: revision})
+	wasReady := s.ready
+	s.ready = true
+	s.mu.Unlock()
+
+	logger.Info("relay snapshot replaced", zap.Int("flags", len(flags)), zap.Int64("rev", revision))
+	if !wasReady {
+		return
+	}
+	resets := make([]v1.Message, 0, len(s.namespaces))
+	for _, namespace := range s.namespaces {
+		resets = append(resets, v1.Message{Env: s.env, Namespace: namespace, Type: service.MessageTypeReset, Revision: revision})
+	}
+	s.broadcast(resets...)
+}
+
+// Apply records an upstream message and fans it out to the connected clients.
+func (s *Store) Apply(msg v1.Message) {
+	s.mu.Lock()
+	key := service.BuildFeatureKey(msg.Env, msg.Namespace, msg.Key)
+	switch msg.Action {
+	case constraints.PUT:
+		s.flags[key] = v1.FeatureFlag{
+			Namespace: msg.Namespace,
+			Env:       msg.Env,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Type:      msg.Type,
+			Version:   msg.Version,
+			Revision:  msg.Revision,
+		}
+	case constraints.DELETE:
+		delete(s.flags, key)
+	}
+	s.revision = max(s.revision, msg.Revision)
+	s.buffer.AddMessage(msg)
+	s.mu.Unlock()
+
+	s.broadcast(msg)
+}
+
+// broadcast queues messages for the hub without blocking, in call order.
+func (s *Store) broadcast(msgs ...v1.Message) {
+	s.outMu.Lock()
+	s.out = append(s.out, msgs...)
+	s.outMu.Unlock()
+	select {
+	case s.outWake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Store) forward() {
+	for range s.outWake {
+		s.outMu.Lock()
+		msgs := s.out
+		s.out = nil
+		s.outMu.Unlock()
+		for _, msg := range msgs {
+			s.hub.Broadcast <- msg
+		}
+	}
+}
+
+func (s *Store) GetCompensation(lastRev int64) ([]v1.Message, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.ready || lastRev < s.baseRev {
+		return nil, false
+	}
+	return s.buffer.GetSince(lastRev)
+}
+
+func (s *Store) GetAllFeatures(ctx context.Context) ([]v1.FeatureFlag, int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]v1.FeatureFlag, 0, len(s.flags))
+	for _, f := range s.flags {
+		res = append(res, f)
+	}
+	return res, s.revision
+}
+
+// Ready reports whether the store holds a snapshot, from upstream or from the cache.
+func (s *Store) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ready
+}
+
+func (s *Store) Revision() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revision
+}
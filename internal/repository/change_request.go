@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"mizuflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ChangeRequestInterface interface {
+	Create(ctx context.Context, change *model.ChangeRequest) error
+	Get(ctx context.Context, id uint64) (*model.ChangeRequest, error)
+	List(ctx context.Context, filter ChangeRequestFilter) ([]model.ChangeRequest, error)
+	UpdateStatus(ctx context.Context, id uint64, from, to, version int, result, operator string) (bool, error)
+	AddApproval(ctx context.Context, approval *model.ChangeApproval) error
+	ListApprovals(ctx context.Context, requestIDs ...uint64) ([]model.ChangeApproval, error)
+}
+
+// ChangeRequestFilter narrows List; empty fields match every request
+type ChangeRequestFilter struct {
+	Namespace string
+	Env       string
+	Key       string
+	// IncludeDone lists applied, rejected and failed requests too, not only
+	// the pending and applying ones
+	IncludeDone bool
+}
+
+type ChangeRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewChangeRequestRepository(db *gorm.DB) *ChangeRequestRepository {
+	return &ChangeRequestRepository{db: db}
+}
+
+func (r *ChangeRequestRepository) Create(ctx context.Context, change *model.ChangeRequest) error {
+	return r.db.WithContext(ctx).Create(change).Error
+}
+
+// Get returns nil when the request does not exist
+func (r *ChangeRequestRepository) Get(ctx context.Context, id uint64) (*model.ChangeRequest, error) {
+	var change model.ChangeRequest
+	if err := r.db.WithContext(ctx).First(&change, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &change, nil
+}
+
+func (r *ChangeRequestRepository) List(ctx context.Context, filter ChangeRequestFilter) ([]model.ChangeRequest, error) {
+	var changes []model.ChangeRequest
+	query := r.db.WithContext(ctx)
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	if filter.Env != "" {
+		query = query.Where("env = ?", filter.Env)
+	}
+	if filter.Key != "" {
+		query = query.Where("`key` = ?", filter.Key)
+	}
+	if !filter.IncludeDone {
+		query = query.Where("status IN ?", []int{model.ChangeStatusPending, model.ChangeStatusApplying})
+	}
+	err := query.Order("id DESC").Find(&changes).Error
+	return changes, err
+}
+
+// UpdateStatus moves a request from status from to status to. It reports
+// false when the request was not in status from anymore, e.g. applied or
+// rejected by someone else.
+func (r *ChangeRequestRepository) UpdateStatus(ctx context.Context, id uint64, from, to, version int, result, operator string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&model.ChangeRequest{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]any{
+			"status":     to,
+			"version":    version,
+			"result":     result,
+			"updated_by": operator,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *ChangeRequestRepository) AddApproval(ctx context.Context, approval *model.ChangeApproval) error {
+	return r.db.WithContext(ctx).Create(approval).Error
+}
+
+// ListApprovals returns the approvals of the given requests, oldest first
+func (r *ChangeRequestRepository) ListApprovals(ctx context.Context, requestIDs ...uint64) ([]model.ChangeApproval, error) {
+	var approvals []model.ChangeApproval
+	if len(requestIDs) == 0 {
+		return approvals, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("request_id IN ?", requestIDs).
+		Order("id ASC").Find(&approvals).Error
+	return approvals, err
+}
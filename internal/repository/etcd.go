@@ -94,7 +94,7 @@ func (r *FeatureRepository) SaveFeatureIfNewer(ctx context.Context, key string,
 			return 0, err
 		} else {
 			// If stored Logic Version >= new Logic Version, Do Nothing (Idempotency).
-			if currentFlag.Version >= newValue.Version {
+			if !newer(newValue, currentFlag) {
 				return kv.ModRevision, nil
 			}
 		}
@@ -120,6 +120,59 @@ func (r *FeatureRepository) SaveFeatureIfNewer(ctx context.Context, key string,
 	}
 }
 
+// DeleteFeatureIfNotNewer deletes a feature item unless etcd holds a newer one
+// than the given flag, e.g. a restore or a recreated flag that was published
+// first. (CAS)
+func (r *FeatureRepository) DeleteFeatureIfNotNewer(ctx context.Context, key string, flag v1.FeatureFlag) (int64, error) {
+	const maxRetries = 3
+	var retries int
+
+	for {
+		resp, err := r.client.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if len(resp.Kvs) == 0 {
+			return resp.Header.Revision, nil
+		}
+
+		var currentFlag v1.FeatureFlag
+		kv := resp.Kvs[0]
+		if err := json.Unmarshal(kv.Value, &currentFlag); err != nil {
+			return 0, err
+		}
+		if newer(currentFlag, flag) {
+			return kv.ModRevision, nil
+		}
+
+		txn := r.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpDelete(key))
+
+		tResp, err := txn.Commit()
+		if err != nil {
+			return 0, err
+		}
+		if tResp.Succeeded {
+			return tResp.Header.Revision, nil
+		}
+		retries++
+		if retries > maxRetries {
+			return 0, errors.New("max retries exceeded for DeleteFeatureIfNotNewer")
+		}
+	}
+}
+
+// newer reports whether a is a later write to a key than b. The generation
+// comes first: a recreated flag is at version 1 again but has a higher one.
+// Values stored without a generation count as generation 0.
+func newer(a, b v1.FeatureFlag) bool {
+	if a.Generation != b.Generation {
+		return a.Generation > b.Generation
+	}
+	return a.Version > b.Version
+}
+
 // WatchFeature sets up a watch on a given prefix in etcd.
 func (r *FeatureRepository) WatchFeature(ctx context.Context, prefix string) clientv3.WatchChan {
 	return r.client.Watch(ctx, prefix, clientv3.WithPrefix())
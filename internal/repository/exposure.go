@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"mizuflow/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExposureInterface interface {
+	Increment(ctx context.Context, exposures []model.FeatureExposure) error
+	List(ctx context.Context, env, namespace, key string) ([]model.FeatureExposure, error)
+}
+
+type ExposureRepository struct {
+	db *gorm.DB
+}
+
+func NewExposureRepository(db *gorm.DB) *ExposureRepository {
+	return &ExposureRepository{db: db}
+}
+
+// Increment adds the hit counts to the stored rows, creating missing ones.
+func (r *ExposureRepository) Increment(ctx context.Context, exposures []model.FeatureExposure) error {
+	if len(exposures) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			"hits":         gorm.Expr("hits + VALUES(hits)"),
+			"last_seen_at": gorm.Expr("GREATEST(last_seen_at, VALUES(last_seen_at))"),
+		}),
+	}).CreateInBatches(exposures, 100).Error
+}
+
+func (r *ExposureRepository) List(ctx context.Context, env, namespace, key string) ([]model.FeatureExposure, error) {
+	var exposures []model.FeatureExposure
+	query := r.db.WithContext(ctx)
+	if env != "" {
+		query = query.Where("env = ?", env)
+	}
+	if namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+	if key != "" {
+		query = query.Where("`key` = ?", key)
+	}
+	err := query.Order("namespace ASC, `key` ASC").Find(&exposures).Error
+	return exposures, err
+}
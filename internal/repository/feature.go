@@ -6,19 +6,31 @@ import (
 	"mizuflow/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // FeatureInterface defines the interface for feature master data persistence
 type FeatureInterface interface {
 	GetByKey(ctx context.Context, namespace, env, key string) (*model.FeatureMaster, error)
+	GetByKeyForUpdate(ctx context.Context, namespace, env, key string) (*model.FeatureMaster, error)
 	GetAll(ctx context.Context) ([]*model.FeatureMaster, error)
-	List(ctx context.Context, namespace, env, search string) ([]*model.FeatureMaster, error)
+	List(ctx context.Context, filter FeatureFilter) ([]*model.FeatureMaster, error)
 	ListByPage(ctx context.Context, offset, limit int) ([]*model.FeatureMaster, error)
 	Save(ctx context.Context, master *model.FeatureMaster) error
+	Delete(ctx context.Context, master *model.FeatureMaster) error
 	Rollback(ctx context.Context, namespace, env, key string, version int) (*model.FeatureMaster, error)
 	WithTx(tx *gorm.DB) any
 }
 
+// FeatureFilter narrows List; empty fields match every record
+type FeatureFilter struct {
+	Namespace string
+	Env       string
+	Search    string // part of the key
+	Owner     string
+	Tag       string
+}
+
 // FeatureMasterRepository implementation of FeatureInterface for MySQL
 type FeatureMasterRepository struct {
 	db *gorm.DB
@@ -41,6 +53,21 @@ func (r *FeatureMasterRepository) GetByKey(ctx context.Context, namespace, env,
 	return &feature, nil
 }
 
+// GetByKeyForUpdate reads the record with a row lock held until the surrounding
+// transaction ends, so concurrent writers of a flag take turns
+func (r *FeatureMasterRepository) GetByKeyForUpdate(ctx context.Context, namespace, env, key string) (*model.FeatureMaster, error) {
+	var feature model.FeatureMaster
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("namespace = ? AND env = ? AND `key` = ?", namespace, env, key).First(&feature).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &feature, nil
+}
+
 func (r *FeatureMasterRepository) GetAll(ctx context.Context) ([]*model.FeatureMaster, error) {
 	var features []*model.FeatureMaster
 	err := r.db.WithContext(ctx).Find(&features).Error
@@ -53,18 +80,24 @@ func (r *FeatureMasterRepository) ListByPage(ctx context.Context, offset, limit
 	return features, err
 }
 
-func (r *FeatureMasterRepository) List(ctx context.Context, namespace, env, search string) ([]*model.FeatureMaster, error) {
+func (r *FeatureMasterRepository) List(ctx context.Context, filter FeatureFilter) ([]*model.FeatureMaster, error) {
 	var features []*model.FeatureMaster
 	query := r.db.WithContext(ctx)
 
-	if namespace != "" {
-		query = query.Where("namespace = ?", namespace)
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
 	}
-	if env != "" {
-		query = query.Where("env = ?", env)
+	if filter.Env != "" {
+		query = query.Where("env = ?", filter.Env)
 	}
-	if search != "" {
-		query = query.Where("`key` LIKE ?", "%"+search+"%")
+	if filter.Search != "" {
+		query = query.Where("`key` LIKE ?", "%"+filter.Search+"%")
+	}
+	if filter.Owner != "" {
+		query = query.Where("owner = ?", filter.Owner)
+	}
+	if filter.Tag != "" {
+		query = query.Where("JSON_CONTAINS(tags, JSON_QUOTE(?))", filter.Tag)
 	}
 
 	err := query.Order("updated_at DESC").Find(&features).Error
@@ -76,6 +109,11 @@ func (r *FeatureMasterRepository) Save(ctx context.Context, master *model.Featur
 	return r.db.WithContext(ctx).Save(master).Error
 }
 
+// Delete removes the feature master record for good
+func (r *FeatureMasterRepository) Delete(ctx context.Context, master *model.FeatureMaster) error {
+	return r.db.WithContext(ctx).Delete(master).Error
+}
+
 // Rollback restores a feature version. Here 'version' is interpreted as the Audit ID to restore from.
 func (r *FeatureMasterRepository) Rollback(ctx context.Context, namespace, env, key string, version int) (*model.FeatureMaster, error) {
 	// Find the audit log corresponding to the version (Assuming version matches Audit ID)
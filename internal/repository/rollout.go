@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"mizuflow/internal/model"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// ErrActiveRollout is returned by Create when the flag has an active plan.
+var ErrActiveRollout = errors.New("flag already has an active rollout plan")
+
+type RolloutInterface interface {
+	Create(ctx context.Context, plan *model.RolloutPlan) error
+	Get(ctx context.Context, id uint64) (*model.RolloutPlan, error)
+	List(ctx context.Context, filter RolloutFilter) ([]model.RolloutPlan, error)
+	FetchDue(ctx context.Context, now time.Time, limit int) ([]model.RolloutPlan, error)
+	Update(ctx context.Context, plan *model.RolloutPlan, status, step int) (bool, error)
+}
+
+// RolloutFilter narrows List; empty fields match every plan
+type RolloutFilter struct {
+	Namespace string
+	Env       string
+	Key       string
+	// IncludeDone lists completed, aborted and failed plans too
+	IncludeDone bool
+}
+
+type RolloutRepository struct {
+	db *gorm.DB
+}
+
+func NewRolloutRepository(db *gorm.DB) *RolloutRepository {
+	return &RolloutRepository{db: db}
+}
+
+func (r *RolloutRepository) Create(ctx context.Context, plan *model.RolloutPlan) error {
+	plan.MarkCurrent()
+	err := r.db.WithContext(ctx).Create(plan).Error
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrActiveRollout
+	}
+	return err
+}
+
+// Get returns nil when the plan does not exist
+func (r *RolloutRepository) Get(ctx context.Context, id uint64) (*model.RolloutPlan, error) {
+	var plan model.RolloutPlan
+	if err := r.db.WithContext(ctx).First(&plan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *RolloutRepository) List(ctx context.Context, filter RolloutFilter) ([]model.RolloutPlan, error) {
+	var plans []model.RolloutPlan
+	query := r.db.WithContext(ctx)
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	if filter.Env != "" {
+		query = query.Where("env = ?", filter.Env)
+	}
+	if filter.Key != "" {
+		query = query.Where("`key` = ?", filter.Key)
+	}
+	if !filter.IncludeDone {
+		query = query.Where("status IN ?", []int{model.RolloutStatusRunning, model.RolloutStatusPaused})
+	}
+	err := query.Order("id DESC").Find(&plans).Error
+	return plans, err
+}
+
+// FetchDue returns the running plans whose next step is due
+func (r *RolloutRepository) FetchDue(ctx context.Context, now time.Time, limit int) ([]model.RolloutPlan, error) {
+	var plans []model.RolloutPlan
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_at <= ?", model.RolloutStatusRunning, now).
+		Order("next_at ASC, id ASC").Limit(limit).Find(&plans).Error
+	return plans, err
+}
+
+// Update saves the plan if its row is still at the given status and step, so
+// the worker and an operator cannot both move it from the same state. It
+// reports false when someone else did.
+func (r *RolloutRepository) Update(ctx context.Context, plan *model.RolloutPlan, status, step int) (bool, error) {
+	plan.MarkCurrent()
+	res := r.db.WithContext(ctx).Model(&model.RolloutPlan{}).
+		Where("id = ? AND status = ? AND step = ?", plan.ID, status, step).
+		Select("step", "status", "next_at", "remaining", "result", "current", "updated_by").
+		Updates(plan)
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"mizuflow/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ScheduleInterface interface {
+	Create(ctx context.Context, change *model.ScheduledChange) error
+	Get(ctx context.Context, id uint64) (*model.ScheduledChange, error)
+	List(ctx context.Context, filter ScheduleFilter) ([]model.ScheduledChange, error)
+	FetchDue(ctx context.Context, now time.Time, limit int) ([]model.ScheduledChange, error)
+	FetchStale(ctx context.Context, before time.Time, limit int) ([]model.ScheduledChange, error)
+	UpdateStatus(ctx context.Context, id uint64, from, to, version int, result string) (bool, error)
+}
+
+// ScheduleFilter narrows List; empty fields match every change
+type ScheduleFilter struct {
+	Namespace string
+	Env       string
+	Key       string
+	// IncludeDone lists applied, failed and canceled changes too, not only the
+	// pending and applying ones
+	IncludeDone bool
+}
+
+type ScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) Create(ctx context.Context, change *model.ScheduledChange) error {
+	return r.db.WithContext(ctx).Create(change).Error
+}
+
+// Get returns nil when the change does not exist
+func (r *ScheduleRepository) Get(ctx context.Context, id uint64) (*model.ScheduledChange, error) {
+	var change model.ScheduledChange
+	if err := r.db.WithContext(ctx).First(&change, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &change, nil
+}
+
+func (r *ScheduleRepository) List(ctx context.Context, filter ScheduleFilter) ([]model.ScheduledChange, error) {
+	var changes []model.ScheduledChange
+	query := r.db.WithContext(ctx)
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	if filter.Env != "" {
+		query = query.Where("env = ?", filter.Env)
+	}
+	if filter.Key != "" {
+		query = query.Where("`key` = ?", filter.Key)
+	}
+	if !filter.IncludeDone {
+		query = query.Where("status IN ?", []int{model.ScheduleStatusPending, model.ScheduleStatusApplying})
+	}
+	err := query.Order("run_at ASC, id ASC").Find(&changes).Error
+	return changes, err
+}
+
+// FetchDue returns the pending changes whose time has come, oldest first
+func (r *ScheduleRepository) FetchDue(ctx context.Context, now time.Time, limit int) ([]model.ScheduledChange, error) {
+	var changes []model.ScheduledChange
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND run_at <= ?", model.ScheduleStatusPending, now).
+		Order("run_at ASC, id ASC").Limit(limit).Find(&changes).Error
+	return changes, err
+}
+
+// FetchStale returns the changes still applying that were claimed before before
+func (r *ScheduleRepository) FetchStale(ctx context.Context, before time.Time, limit int) ([]model.ScheduledChange, error) {
+	var changes []model.ScheduledChange
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND updated_at < ?", model.ScheduleStatusApplying, before).
+		Order("id ASC").Limit(limit).Find(&changes).Error
+	return changes, err
+}
+
+// UpdateStatus moves a change from status from to status to. It reports false
+// when the change was not in status from anymore, e.g. claimed or canceled by
+// someone else.
+func (r *ScheduleRepository) UpdateStatus(ctx context.Context, id uint64, from, to, version int, result string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&model.ScheduledChange{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]any{
+			"status":  to,
+			"version": version,
+			"result":  result,
+		})
+	return res.RowsAffected > 0, res.Error
+}
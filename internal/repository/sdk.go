@@ -13,6 +13,12 @@ type SDKRepository interface {
 	ValidateAPIKey(ctx context.Context, apiKey, env string) (bool, error)
 }
 
+// RelayKeyRepository lets relays authenticate SDKs without the database
+type RelayKeyRepository interface {
+	ValidateRelayKey(ctx context.Context, apiKey, env string) (bool, error)
+	ListAPIKeys(ctx context.Context, env string) ([]string, error)
+}
+
 // SDKKeyRepository implementation
 type SDKKeyRepository struct {
 	db *gorm.DB
@@ -37,3 +43,20 @@ func (r *SDKKeyRepository) ValidateAPIKey(ctx context.Context, apiKey, env strin
 	}
 	return true, nil
 }
+
+func (r *SDKKeyRepository) ValidateRelayKey(ctx context.Context, apiKey, env string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.SDKClient{}).
+		Where("api_key = ? AND env = ? AND status = 1 AND relay = ?", apiKey, env, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListAPIKeys returns the active keys of env
+func (r *SDKKeyRepository) ListAPIKeys(ctx context.Context, env string) ([]string, error) {
+	var keys []string
+	err := r.db.WithContext(ctx).Model(&model.SDKClient{}).
+		Where("env = ? AND status = 1", env).
+		Pluck("api_key", &keys).Error
+	return keys, err
+}
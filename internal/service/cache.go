@@ -21,7 +21,8 @@ func (c *FeatureCache) Update(f v1.FeatureFlag) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data[f.Key] = f
+	// keyed like etcd so that Delete, which only sees the etcd key, finds the flag
+	c.data[BuildFeatureKey(f.Env, f.Namespace, f.Key)] = f
 	if f.Revision > c.revision {
 		c.revision = f.Revision
 	}
@@ -0,0 +1,563 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var ErrChangeRequestNotFound = errors.New("change request not found")
+var ErrChangeRequestState = errors.New("change request state does not allow this")
+var ErrSelfApproval = errors.New("the author of a change request cannot approve it")
+
+// ChangeRequestService holds flag writes to protected environments until
+// enough users other than the author approved them. Every step is audited on
+// the flag, the write itself by the feature service. Besides value updates a
+// request archives, deletes or restores a flag, or creates a scheduled change
+// or rollout plan allowed to write to the environment.
+type ChangeRequestService struct {
+	repo       repository.ChangeRequestInterface
+	auditRepo  repository.AuditInterface
+	features   featureWriter
+	schedules  *ScheduleService
+	rollouts   *RolloutService
+	protection Protection
+}
+
+func NewChangeRequestService(repo repository.ChangeRequestInterface, auditRepo repository.AuditInterface, features *FeatureService, schedules *ScheduleService, rollouts *RolloutService) *ChangeRequestService {
+	return &ChangeRequestService{
+		repo:       repo,
+		auditRepo:  auditRepo,
+		features:   features,
+		schedules:  schedules,
+		rollouts:   rollouts,
+		protection: features.protection,
+	}
+}
+
+// Protects reports whether writes to env need approvals.
+func (s *ChangeRequestService) Protects(env string) bool {
+	return s.protection.Protects(env)
+}
+
+// CreateChangeRequest validates a write like SaveFeature would and keeps it,
+// with the flag as it is now, for review.
+func (s *ChangeRequestService) CreateChangeRequest(ctx context.Context, r req.CreateFeatureRequest, operator string) (*resp.ChangeRequestItem, error) {
+	if err := s.features.validatePayload(r.Type, r.Value); err != nil {
+		return nil, err
+	}
+	var metadata string
+	if r.FeatureMetadata != nil {
+		meta, err := normalizeMetadata(*r.FeatureMetadata)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := json.Marshal(meta)
+		metadata = string(data)
+	}
+
+	var oldValue string
+	var baseVersion int
+	current, err := s.features.GetFeature(ctx, r.Namespace, r.Env, r.Key)
+	switch {
+	case errors.Is(err, ErrFeatureNotFound):
+	case err != nil:
+		return nil, err
+	case current.Archived:
+		return nil, ErrFeatureArchived
+	default:
+		oldValue, baseVersion = current.Value, current.Version
+	}
+	if r.ExpectedVersion != nil && *r.ExpectedVersion != baseVersion {
+		return nil, &VersionConflictError{Expected: *r.ExpectedVersion, Current: baseVersion, CurrentValue: oldValue}
+	}
+
+	return s.propose(ctx, &model.ChangeRequest{
+		Namespace:   r.Namespace,
+		Env:         r.Env,
+		Key:         r.Key,
+		Action:      model.ChangeActionUpdate,
+		Value:       r.Value,
+		Type:        r.Type,
+		Metadata:    metadata,
+		OldValue:    oldValue,
+		BaseVersion: baseVersion,
+		Reason:      r.Reason,
+	}, operator)
+}
+
+// ProposeRollback requests the value of an update audit entry back, like
+// RollbackFeature would write it.
+func (s *ChangeRequestService) ProposeRollback(ctx context.Context, r req.RollbackFeatureRequest, key, operator string) (*resp.ChangeRequestItem, error) {
+	audit, err := s.features.rollbackTarget(ctx, r.Namespace, r.Env, key, uint(r.AuditID))
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateChangeRequest(ctx, req.CreateFeatureRequest{
+		Namespace:       r.Namespace,
+		Env:             r.Env,
+		Key:             key,
+		Value:           audit.OldValue,
+		Type:            audit.Type,
+		ExpectedVersion: r.ExpectedVersion,
+		Reason:          fmt.Sprintf("rollback to audit entry %d", r.AuditID),
+	}, operator)
+}
+
+// ProposeDelete requests to archive the flag, or with hard set to remove it.
+func (s *ChangeRequestService) ProposeDelete(ctx context.Context, namespace, env, key string, hard bool, operator string) (*resp.ChangeRequestItem, error) {
+	current, err := s.features.GetFeature(ctx, namespace, env, key)
+	if err != nil {
+		return nil, err
+	}
+	action := model.ChangeActionDelete
+	if !hard {
+		if current.Archived {
+			return nil, ErrFeatureAlreadyArchived
+		}
+		action = model.ChangeActionArchive
+	}
+	return s.propose(ctx, &model.ChangeRequest{
+		Namespace:   namespace,
+		Env:         env,
+		Key:         key,
+		Action:      action,
+		Type:        current.Type,
+		OldValue:    current.Value,
+		BaseVersion: current.Version,
+	}, operator)
+}
+
+// ProposeRestore requests an archived flag back with the value it had.
+func (s *ChangeRequestService) ProposeRestore(ctx context.Context, namespace, env, key, operator string) (*resp.ChangeRequestItem, error) {
+	current, err := s.features.GetFeature(ctx, namespace, env, key)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Archived {
+		return nil, ErrFeatureNotArchived
+	}
+	return s.propose(ctx, &model.ChangeRequest{
+		Namespace:   namespace,
+		Env:         env,
+		Key:         key,
+		Action:      model.ChangeActionRestore,
+		Value:       current.Value,
+		Type:        current.Type,
+		BaseVersion: current.Version,
+	}, operator)
+}
+
+// ProposeSchedule validates a scheduled change like CreateSchedule and
+// requests to schedule it. It is validated again when the request is applied,
+// its run_at may have passed by then.
+func (s *ChangeRequestService) ProposeSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string) (*resp.ChangeRequestItem, error) {
+	r, err := s.schedules.prepareSchedule(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	change := &model.ChangeRequest{
+		Namespace: r.Namespace,
+		Env:       r.Env,
+		Key:       r.Key,
+		Action:    model.ChangeActionSchedule,
+		Value:     r.Value,
+		Type:      r.Type,
+	}
+	if current, err := s.features.GetFeature(ctx, r.Namespace, r.Env, r.Key); err == nil {
+		change.OldValue, change.BaseVersion = current.Value, current.Version
+	}
+	payload, _ := json.Marshal(r)
+	change.Payload = string(payload)
+	return s.propose(ctx, change, operator)
+}
+
+// ProposeRollout validates a plan like CreateRollout and requests to start
+// it. Once started, the steps of the plan need no further approval.
+func (s *ChangeRequestService) ProposeRollout(ctx context.Context, r req.CreateRolloutRequest, operator string) (*resp.ChangeRequestItem, error) {
+	if _, _, err := s.rollouts.checkRollout(ctx, r); err != nil {
+		return nil, err
+	}
+	current, err := s.features.GetFeature(ctx, r.Namespace, r.Env, r.Key)
+	if err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(r)
+	return s.propose(ctx, &model.ChangeRequest{
+		Namespace:   r.Namespace,
+		Env:         r.Env,
+		Key:         r.Key,
+		Action:      model.ChangeActionRollout,
+		Type:        current.Type,
+		Payload:     string(payload),
+		BaseVersion: current.Version,
+	}, operator)
+}
+
+// propose keeps a validated change for review.
+func (s *ChangeRequestService) propose(ctx context.Context, change *model.ChangeRequest, operator string) (*resp.ChangeRequestItem, error) {
+	change.Reason = truncate(change.Reason, 255)
+	change.RequiredApprovals = s.protection.Required(change.Env)
+	change.Status = model.ChangeStatusPending
+	change.CreatedBy = operator
+	if err := s.repo.Create(ctx, change); err != nil {
+		logger.Error("failed to create change request", zap.String("key", change.Key), zap.Error(err))
+		return nil, err
+	}
+	s.audit(ctx, change, model.AuditActionChangeRequested, operator)
+	logger.Info("change request created", zap.Uint64("id", change.ID), zap.String("key", change.Key), zap.String("env", change.Env), zap.String("action", change.Action))
+	item := changeRequestItem(change, nil)
+	return &item, nil
+}
+
+func (s *ChangeRequestService) ListChangeRequests(ctx context.Context, filter repository.ChangeRequestFilter) ([]resp.ChangeRequestItem, error) {
+	changes, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, 0, len(changes))
+	for _, c := range changes {
+		ids = append(ids, c.ID)
+	}
+	approvals, err := s.repo.ListApprovals(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	byRequest := make(map[uint64][]model.ChangeApproval, len(changes))
+	for _, a := range approvals {
+		byRequest[a.RequestID] = append(byRequest[a.RequestID], a)
+	}
+	items := make([]resp.ChangeRequestItem, 0, len(changes))
+	for i := range changes {
+		items = append(items, changeRequestItem(&changes[i], byRequest[changes[i].ID]))
+	}
+	return items, nil
+}
+
+func (s *ChangeRequestService) GetChangeRequest(ctx context.Context, id uint64) (*resp.ChangeRequestItem, error) {
+	change, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.item(ctx, change)
+}
+
+// ApproveChangeRequest adds the approval of operator, who must not be the
+// author and approves a request once.
+func (s *ChangeRequestService) ApproveChangeRequest(ctx context.Context, id uint64, comment, operator string) (*resp.ChangeRequestItem, error) {
+	change, err := s.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if operator == change.CreatedBy {
+		return nil, ErrSelfApproval
+	}
+	approvals, err := s.repo.ListApprovals(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range approvals {
+		if a.Approver == operator {
+			return nil, fmt.Errorf("%w: already approved by %s", ErrChangeRequestState, operator)
+		}
+	}
+	if err := s.repo.AddApproval(ctx, &model.ChangeApproval{RequestID: id, Approver: operator, Comment: truncate(comment, 255)}); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, change, model.AuditActionChangeApproved, operator)
+	logger.Info("change request approved", zap.Uint64("id", id), zap.String("operator", operator))
+	return s.item(ctx, change)
+}
+
+// RejectChangeRequest closes a pending request; its author may reject it to
+// withdraw it.
+func (s *ChangeRequestService) RejectChangeRequest(ctx context.Context, id uint64, comment, operator string) (*resp.ChangeRequestItem, error) {
+	change, err := s.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := "rejected by " + operator
+	if comment != "" {
+		result += ": " + comment
+	}
+	ok, err := s.repo.UpdateStatus(ctx, id, model.ChangeStatusPending, model.ChangeStatusRejected, 0, truncate(result, 255), operator)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: no longer pending", ErrChangeRequestState)
+	}
+	s.audit(ctx, change, model.AuditActionChangeRejected, operator)
+	logger.Info("change request rejected", zap.Uint64("id", id), zap.String("operator", operator))
+	return s.GetChangeRequest(ctx, id)
+}
+
+// ApplyChangeRequest carries out an approved request. Updates, archives,
+// deletes and restores only write while the flag is still at the version the
+// request was based on; schedules and rollouts are validated again. A failure
+// is audited and leaves the request failed; the change has to be requested
+// again.
+func (s *ChangeRequestService) ApplyChangeRequest(ctx context.Context, id uint64, operator string) (*resp.ChangeRequestItem, error) {
+	change, err := s.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := s.repo.ListApprovals(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(approvals) < change.RequiredApprovals {
+		return nil, fmt.Errorf("%w: %d of %d approvals", ErrChangeRequestState, len(approvals), change.RequiredApprovals)
+	}
+	var meta *req.FeatureMetadata
+	if change.Metadata != "" {
+		meta = &req.FeatureMetadata{}
+		if err := json.Unmarshal([]byte(change.Metadata), meta); err != nil {
+			return nil, fmt.Errorf("malformed metadata of change request %d: %w", id, err)
+		}
+	}
+	ok, err := s.repo.UpdateStatus(ctx, id, model.ChangeStatusPending, model.ChangeStatusApplying, 0, "", operator)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: no longer pending", ErrChangeRequestState)
+	}
+
+	ctx = context.WithValue(ctx, "TraceID", changeTraceID(id))
+	var version int
+	var result string
+	switch change.Action {
+	case model.ChangeActionArchive, model.ChangeActionDelete:
+		version, err = s.features.deleteFeature(ctx, change.Namespace, change.Env, change.Key, change.Action == model.ChangeActionDelete, &change.BaseVersion, operator)
+	case model.ChangeActionRestore:
+		version, err = s.features.restoreFeature(ctx, change.Namespace, change.Env, change.Key, &change.BaseVersion, operator)
+	case model.ChangeActionSchedule:
+		result, err = s.applySchedule(ctx, change, operator)
+	case model.ChangeActionRollout:
+		result, err = s.applyRollout(ctx, change, operator)
+	default:
+		version, err = s.features.SaveFeature(ctx, v1.FeatureFlag{
+			Namespace: change.Namespace,
+			Env:       change.Env,
+			Key:       change.Key,
+			Value:     change.Value,
+			Type:      change.Type,
+		}, meta, &change.BaseVersion, operator)
+	}
+
+	status := model.ChangeStatusApplied
+	if err != nil {
+		status, result = model.ChangeStatusFailed, truncate(err.Error(), 255)
+		logger.Warn("change request failed", zap.Uint64("id", id), zap.String("key", change.Key), zap.Error(err))
+		s.audit(ctx, change, model.AuditActionChangeFailed, operator)
+	} else {
+		logger.Info("change request applied", zap.Uint64("id", id), zap.String("key", change.Key), zap.Int("version", version))
+	}
+	if _, updateErr := s.repo.UpdateStatus(ctx, id, model.ChangeStatusApplying, status, version, result, operator); updateErr != nil {
+		logger.Error("failed to record change request result", zap.Uint64("id", id), zap.Error(updateErr))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetChangeRequest(ctx, id)
+}
+
+func (s *ChangeRequestService) applySchedule(ctx context.Context, change *model.ChangeRequest, operator string) (string, error) {
+	var r req.CreateScheduleRequest
+	if err := json.Unmarshal([]byte(change.Payload), &r); err != nil {
+		return "", fmt.Errorf("malformed payload of change request %d: %w", change.ID, err)
+	}
+	r, err := s.schedules.prepareSchedule(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	item, err := s.schedules.createSchedule(ctx, r, operator, change.ID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("scheduled change %d", item.ID), nil
+}
+
+func (s *ChangeRequestService) applyRollout(ctx context.Context, change *model.ChangeRequest, operator string) (string, error) {
+	var r req.CreateRolloutRequest
+	if err := json.Unmarshal([]byte(change.Payload), &r); err != nil {
+		return "", fmt.Errorf("malformed payload of change request %d: %w", change.ID, err)
+	}
+	item, err := s.rollouts.createRollout(ctx, r, operator, change.ID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("rollout plan %d", item.ID), nil
+}
+
+func (s *ChangeRequestService) get(ctx context.Context, id uint64) (*model.ChangeRequest, error) {
+	change, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if change == nil {
+		return nil, ErrChangeRequestNotFound
+	}
+	return change, nil
+}
+
+func (s *ChangeRequestService) pending(ctx context.Context, id uint64) (*model.ChangeRequest, error) {
+	change, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if change.Status != model.ChangeStatusPending {
+		return nil, fmt.Errorf("%w: request is %s", ErrChangeRequestState, changeStatusName(change.Status))
+	}
+	return change, nil
+}
+
+func (s *ChangeRequestService) item(ctx context.Context, change *model.ChangeRequest) (*resp.ChangeRequestItem, error) {
+	approvals, err := s.repo.ListApprovals(ctx, change.ID)
+	if err != nil {
+		return nil, err
+	}
+	item := changeRequestItem(change, approvals)
+	return &item, nil
+}
+
+// audit records a step of the request on the flag; all steps of a request
+// share its trace id.
+func (s *ChangeRequestService) audit(ctx context.Context, change *model.ChangeRequest, action, operator string) {
+	err := s.auditRepo.Create(ctx, &model.FeatureAudit{
+		Namespace: change.Namespace,
+		Env:       change.Env,
+		Key:       change.Key,
+		OldValue:  change.OldValue,
+		NewValue:  change.Value,
+		Type:      change.Type,
+		Action:    action,
+		Operator:  operator,
+		TraceID:   changeTraceID(change.ID),
+	})
+	if err != nil {
+		logger.Error("failed to create feature audit", zap.String("key", change.Key), zap.String("action", action), zap.Error(err))
+	}
+}
+
+func changeTraceID(id uint64) string {
+	return fmt.Sprintf("change-%d", id)
+}
+
+func changeRequestItem(c *model.ChangeRequest, approvals []model.ChangeApproval) resp.ChangeRequestItem {
+	item := resp.ChangeRequestItem{
+		ID:                c.ID,
+		Namespace:         c.Namespace,
+		Env:               c.Env,
+		Key:               c.Key,
+		Action:            c.Action,
+		Value:             c.Value,
+		Type:              c.Type,
+		OldValue:          c.OldValue,
+		BaseVersion:       c.BaseVersion,
+		Diff:              lineDiff(c.OldValue, c.Value),
+		Reason:            c.Reason,
+		Status:            changeStatusName(c.Status),
+		RequiredApprovals: c.RequiredApprovals,
+		Approvals:         make([]resp.ChangeApprovalItem, 0, len(approvals)),
+		Version:           c.Version,
+		Result:            c.Result,
+		CreatedBy:         c.CreatedBy,
+		UpdatedBy:         c.UpdatedBy,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
+	}
+	if item.Action == "" {
+		item.Action = model.ChangeActionUpdate
+	}
+	if c.Metadata != "" {
+		item.Metadata = json.RawMessage(c.Metadata)
+	}
+	if c.Payload != "" {
+		item.Payload = json.RawMessage(c.Payload)
+	}
+	for _, a := range approvals {
+		item.Approvals = append(item.Approvals, resp.ChangeApprovalItem{Approver: a.Approver, Comment: a.Comment, CreatedAt: a.CreatedAt})
+	}
+	return item
+}
+
+func changeStatusName(status int) string {
+	switch status {
+	case model.ChangeStatusPending:
+		return "pending"
+	case model.ChangeStatusApplying:
+		return "applying"
+	case model.ChangeStatusApplied:
+		return "applied"
+	case model.ChangeStatusRejected:
+		return "rejected"
+	case model.ChangeStatusFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// lineDiff marks the lines only in old with "- ", those only in new with "+ "
+// and common ones with two spaces. JSON values are indented first, so a
+// strategy diffs rule by rule.
+func lineDiff(old, new string) string {
+	a, b := diffLines(old), diffLines(new)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+func diffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[") {
+		var buf bytes.Buffer
+		if json.Indent(&buf, []byte(s), "", "  ") == nil {
+			s = buf.String()
+		}
+	}
+	return strings.Split(s, "\n")
+}
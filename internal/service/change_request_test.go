@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	"mizuflow/pkg/constraints"
+)
+
+type mockChangeRequestRepo struct {
+	changes   map[uint64]*model.ChangeRequest
+	approvals []model.ChangeApproval
+	nextID    uint64
+}
+
+func (m *mockChangeRequestRepo) Create(ctx context.Context, change *model.ChangeRequest) error {
+	m.nextID++
+	change.ID = m.nextID
+	c := *change
+	m.changes[c.ID] = &c
+	return nil
+}
+
+func (m *mockChangeRequestRepo) Get(ctx context.Context, id uint64) (*model.ChangeRequest, error) {
+	if c, ok := m.changes[id]; ok {
+		cp := *c
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (m *mockChangeRequestRepo) List(ctx context.Context, filter repository.ChangeRequestFilter) ([]model.ChangeRequest, error) {
+	var out []model.ChangeRequest
+	for id := m.nextID; id >= 1; id-- {
+		if c := m.changes[id]; c != nil && (filter.IncludeDone || c.Status == model.ChangeStatusPending) {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockChangeRequestRepo) UpdateStatus(ctx context.Context, id uint64, from, to, version int, result, operator string) (bool, error) {
+	c := m.changes[id]
+	if c == nil || c.Status != from {
+		return false, nil
+	}
+	c.Status, c.Version, c.Result, c.UpdatedBy = to, version, result, operator
+	return true, nil
+}
+
+func (m *mockChangeRequestRepo) AddApproval(ctx context.Context, approval *model.ChangeApproval) error {
+	m.approvals = append(m.approvals, *approval)
+	return nil
+}
+
+func (m *mockChangeRequestRepo) ListApprovals(ctx context.Context, requestIDs ...uint64) ([]model.ChangeApproval, error) {
+	var out []model.ChangeApproval
+	for _, a := range m.approvals {
+		for _, id := range requestIDs {
+			if a.RequestID == id {
+				out = append(out, a)
+			}
+		}
+	}
+	return out, nil
+}
+
+func newTestChangeRequestService() (*ChangeRequestService, *mockChangeRequestRepo, *mockAuditRepo, *fakeFeatureWriter) {
+	repo := &mockChangeRequestRepo{changes: map[uint64]*model.ChangeRequest{}}
+	audits := &mockAuditRepo{}
+	features := &fakeFeatureWriter{flags: map[string]*resp.FeatureItem{
+		"banner": {Key: "banner", Type: constraints.TypeBool, Value: "false", Version: 4},
+		"legacy": {Key: "legacy", Type: constraints.TypeBool, Value: "false", Version: 2, Archived: true},
+	}}
+	svc := &ChangeRequestService{
+		repo:       repo,
+		auditRepo:  audits,
+		features:   features,
+		protection: Protection{"prod": 2},
+	}
+	return svc, repo, audits, features
+}
+
+func TestChangeRequestService_Create(t *testing.T) {
+	svc, _, audits, features := newTestChangeRequestService()
+	ctx := context.Background()
+	stale := 3
+
+	tests := []struct {
+		name        string
+		r           req.CreateFeatureRequest
+		wantBase    int
+		wantOldDiff bool
+		wantErr     bool
+	}{
+		{name: "existing flag", r: req.CreateFeatureRequest{Env: "prod", Key: "banner", Value: "true", Type: constraints.TypeBool, Reason: "launch"}, wantBase: 4, wantOldDiff: true},
+		{name: "new flag", r: req.CreateFeatureRequest{Env: "prod", Key: "sale", Value: "30", Type: constraints.TypeNumber}, wantBase: 0},
+		{name: "invalid value", r: req.CreateFeatureRequest{Env: "prod", Key: "banner", Value: "yes", Type: constraints.TypeBool}, wantErr: true},
+		{name: "archived flag", r: req.CreateFeatureRequest{Env: "prod", Key: "legacy", Value: "true", Type: constraints.TypeBool}, wantErr: true},
+		{name: "stale expected version", r: req.CreateFeatureRequest{Env: "prod", Key: "banner", Value: "true", Type: constraints.TypeBool, ExpectedVersion: &stale}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := svc.CreateChangeRequest(ctx, tt.r, "alice")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateChangeRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if item.Status != "pending" || item.BaseVersion != tt.wantBase || item.RequiredApprovals != 2 || item.CreatedBy != "alice" {
+				t.Errorf("CreateChangeRequest() = %+v", item)
+			}
+			if !strings.Contains(item.Diff, "+ "+tt.r.Value) || strings.Contains(item.Diff, "- false") != tt.wantOldDiff {
+				t.Errorf("diff = %q", item.Diff)
+			}
+		})
+	}
+
+	if len(features.operators) != 0 {
+		t.Errorf("flag written %d times, want no write before approval", len(features.operators))
+	}
+	if len(audits.audits) != 2 || audits.audits[0].Action != model.AuditActionChangeRequested || audits.audits[0].Operator != "alice" {
+		t.Errorf("audits = %+v, want the two created requests", audits.audits)
+	}
+	if !svc.Protects("prod") || !svc.Protects("PROD") || svc.Protects("dev") {
+		t.Error("Protects() should only hold for prod")
+	}
+}
+
+func TestChangeRequestService_Review(t *testing.T) {
+	svc, repo, audits, features := newTestChangeRequestService()
+	ctx := context.Background()
+
+	item, err := svc.CreateChangeRequest(ctx, req.CreateFeatureRequest{Env: "prod", Key: "banner", Value: "true", Type: constraints.TypeBool}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := item.ID
+
+	if _, err := svc.ApproveChangeRequest(ctx, id, "", "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("ApproveChangeRequest() by the author error = %v, want ErrSelfApproval", err)
+	}
+	if _, err := svc.ApproveChangeRequest(ctx, id, "lgtm", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ApproveChangeRequest(ctx, id, "", "bob"); !errors.Is(err, ErrChangeRequestState) {
+		t.Errorf("second ApproveChangeRequest() by bob error = %v, want ErrChangeRequestState", err)
+	}
+	if _, err := svc.ApplyChangeRequest(ctx, id, "alice"); !errors.Is(err, ErrChangeRequestState) || len(features.operators) != 0 {
+		t.Errorf("ApplyChangeRequest() with 1 of 2 approvals error = %v, want ErrChangeRequestState", err)
+	}
+	item, err = svc.ApproveChangeRequest(ctx, id, "", "carol")
+	if err != nil || len(item.Approvals) != 2 || item.Approvals[0].Comment != "lgtm" {
+		t.Fatalf("ApproveChangeRequest() = %+v, %v", item, err)
+	}
+
+	item, err = svc.ApplyChangeRequest(ctx, id, "alice")
+	if err != nil || item.Status != "applied" || item.Version != 5 {
+		t.Fatalf("ApplyChangeRequest() = %+v, %v, want applied as version 5", item, err)
+	}
+	if features.flags["banner"].Value != "true" || features.traceIDs[0] != "change-1" {
+		t.Errorf("banner = %s written with trace %q", features.flags["banner"].Value, features.traceIDs[0])
+	}
+	if _, err := svc.ApplyChangeRequest(ctx, id, "alice"); !errors.Is(err, ErrChangeRequestState) {
+		t.Errorf("second ApplyChangeRequest() error = %v, want ErrChangeRequestState", err)
+	}
+
+	// a request based on an old version fails instead of overwriting
+	stale, _ := svc.CreateChangeRequest(ctx, req.CreateFeatureRequest{Env: "prod", Key: "banner", Value: "false", Type: constraints.TypeBool}, "alice")
+	features.flags["banner"].Version++
+	svc.ApproveChangeRequest(ctx, stale.ID, "", "bob")
+	svc.ApproveChangeRequest(ctx, stale.ID, "", "carol")
+	var conflict *VersionConflictError
+	if _, err := svc.ApplyChangeRequest(ctx, stale.ID, "bob"); !errors.As(err, &conflict) {
+		t.Errorf("ApplyChangeRequest() of a stale request error = %v, want a version conflict", err)
+	}
+	if c := repo.changes[stale.ID]; c.Status != model.ChangeStatusFailed || c.Result == "" {
+		t.Errorf("stale request = %+v, want failed with the conflict", c)
+	}
+
+	rejected, _ := svc.CreateChangeRequest(ctx, req.CreateFeatureRequest{Env: "prod", Key: "banner", Value: "false", Type: constraints.TypeBool}, "alice")
+	if item, err := svc.RejectChangeRequest(ctx, rejected.ID, "not now", "bob"); err != nil || item.Status != "rejected" || item.Result != "rejected by bob: not now" {
+		t.Errorf("RejectChangeRequest() = %+v, %v", item, err)
+	}
+	if _, err := svc.ApproveChangeRequest(ctx, rejected.ID, "", "carol"); !errors.Is(err, ErrChangeRequestState) {
+		t.Errorf("ApproveChangeRequest() of a rejected request error = %v, want ErrChangeRequestState", err)
+	}
+	if _, err := svc.GetChangeRequest(ctx, 99); !errors.Is(err, ErrChangeRequestNotFound) {
+		t.Errorf("GetChangeRequest() of an unknown request error = %v, want ErrChangeRequestNotFound", err)
+	}
+
+	var actions []string
+	for _, a := range audits.audits {
+		actions = append(actions, a.Operator+":"+a.Action)
+	}
+	want := []string{
+		"alice:change_requested", "bob:change_approved", "carol:change_approved",
+		"alice:change_requested", "bob:change_approved", "carol:change_approved", "bob:change_failed",
+		"alice:change_requested", "bob:change_rejected",
+	}
+	if strings.Join(actions, " ") != strings.Join(want, " ") {
+		t.Errorf("audits = %v, want %v", actions, want)
+	}
+}
+
+func TestChangeRequestService_Actions(t *testing.T) {
+	svc, repo, _, features := newTestChangeRequestService()
+	ctx := context.Background()
+	now := time.Date(2026, 11, 26, 12, 0, 0, 0, time.UTC)
+	schedules, scheduleRepo, _, _ := newTestScheduleService(now)
+	rollouts, rolloutRepo, rolloutFeatures, _ := newTestRolloutService(&now)
+	features.flags["checkout"] = rolloutFeatures.flags["checkout"]
+	schedules.features, rollouts.features = features, features
+	svc.schedules, svc.rollouts = schedules, rollouts
+	features.auditRepo = &mockFindAuditRepo{audits: map[uint]*model.FeatureAudit{
+		7: {Env: "prod", Key: "banner", OldValue: "true", Type: constraints.TypeBool, Action: model.AuditActionUpdate},
+	}}
+
+	// approve has two other users approve the request and applies it
+	approve := func(t *testing.T, item *resp.ChangeRequestItem, err error) *resp.ChangeRequestItem {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		svc.ApproveChangeRequest(ctx, item.ID, "", "bob")
+		svc.ApproveChangeRequest(ctx, item.ID, "", "carol")
+		applied, err := svc.ApplyChangeRequest(ctx, item.ID, "bob")
+		if err != nil || applied.Status != "applied" {
+			t.Fatalf("ApplyChangeRequest(%s) = %+v, %v, want applied", item.Action, applied, err)
+		}
+		return applied
+	}
+
+	item, err := svc.ProposeDelete(ctx, "", "prod", "banner", false, "alice")
+	if err == nil && (item.Action != model.ChangeActionArchive || item.BaseVersion != 4 || len(features.operators) != 0) {
+		t.Errorf("ProposeDelete() = %+v, want an archive based on version 4 and no write", item)
+	}
+	if applied := approve(t, item, err); !features.flags["banner"].Archived || applied.Version != 5 {
+		t.Errorf("archive applied as %+v, banner = %+v", applied, features.flags["banner"])
+	}
+	if _, err := svc.ProposeDelete(ctx, "", "prod", "banner", false, "alice"); !errors.Is(err, ErrFeatureAlreadyArchived) {
+		t.Errorf("ProposeDelete() of an archived flag error = %v, want ErrFeatureAlreadyArchived", err)
+	}
+
+	item, err = svc.ProposeRestore(ctx, "", "prod", "banner", "alice")
+	if applied := approve(t, item, err); features.flags["banner"].Archived || applied.Version != 6 {
+		t.Errorf("restore applied as %+v, banner = %+v", applied, features.flags["banner"])
+	}
+	if _, err := svc.ProposeRestore(ctx, "", "prod", "banner", "alice"); !errors.Is(err, ErrFeatureNotArchived) {
+		t.Errorf("ProposeRestore() of an active flag error = %v, want ErrFeatureNotArchived", err)
+	}
+
+	item, err = svc.ProposeRollback(ctx, req.RollbackFeatureRequest{Env: "prod", AuditID: 7}, "banner", "alice")
+	if err == nil && (item.Action != model.ChangeActionUpdate || item.Value != "true" || item.Reason != "rollback to audit entry 7") {
+		t.Errorf("ProposeRollback() = %+v, want an update back to true", item)
+	}
+	if approve(t, item, err); features.flags["banner"].Value != "true" {
+		t.Errorf("rollback applied, banner = %s, want true", features.flags["banner"].Value)
+	}
+
+	// the scheduled change and the plan write to prod without asking again
+	item, err = svc.ProposeSchedule(ctx, req.CreateScheduleRequest{Env: "prod", Key: "banner", Value: "false", RunAt: now.Add(time.Hour)}, "alice")
+	if err == nil && (item.Action != model.ChangeActionSchedule || item.Type != constraints.TypeBool || len(item.Payload) == 0) {
+		t.Errorf("ProposeSchedule() = %+v, want a schedule with its type filled in", item)
+	}
+	applied := approve(t, item, err)
+	change := scheduleRepo.changes[1]
+	if applied.Result != "scheduled change 1" || change == nil || change.ChangeRequestID != item.ID {
+		t.Fatalf("schedule applied as %+v, scheduled %+v", applied, change)
+	}
+	schedules.apply(ctx, change)
+	if features.flags["banner"].Value != "false" || scheduleRepo.changes[1].Status != model.ScheduleStatusApplied {
+		t.Errorf("approved scheduled change = %+v, banner = %s", scheduleRepo.changes[1], features.flags["banner"].Value)
+	}
+
+	steps := []req.RolloutStep{{Percentage: 10, Duration: "1h"}, {Percentage: 100}}
+	item, err = svc.ProposeRollout(ctx, req.CreateRolloutRequest{Env: "prod", Key: "checkout", RuleID: "canary", Steps: steps}, "alice")
+	applied = approve(t, item, err)
+	plan := rolloutRepo.plans[1]
+	if applied.Result != "rollout plan 1" || plan == nil || plan.ChangeRequestID != item.ID || canaryThreshold(t, features) != "10" {
+		t.Fatalf("rollout applied as %+v, plan %+v", applied, plan)
+	}
+	now = now.Add(time.Hour)
+	rollouts.advanceDue(ctx)
+	if canaryThreshold(t, features) != "100" {
+		t.Errorf("canary threshold = %s after the approved plan advanced, want 100", canaryThreshold(t, features))
+	}
+
+	// an archive based on an old version fails instead of archiving
+	item, _ = svc.ProposeDelete(ctx, "", "prod", "banner", false, "alice")
+	features.flags["banner"].Version++
+	svc.ApproveChangeRequest(ctx, item.ID, "", "bob")
+	svc.ApproveChangeRequest(ctx, item.ID, "", "carol")
+	var conflict *VersionConflictError
+	if _, err := svc.ApplyChangeRequest(ctx, item.ID, "bob"); !errors.As(err, &conflict) || features.flags["banner"].Archived {
+		t.Errorf("ApplyChangeRequest() of a stale archive error = %v, want a version conflict", err)
+	}
+	if c := repo.changes[item.ID]; c.Status != model.ChangeStatusFailed {
+		t.Errorf("stale archive = %+v, want failed", c)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	old := `{"rules":[{"id":"canary","values":["5"]}]}`
+	new := `{"rules":[{"id":"canary","values":["25"]}]}`
+	diff := lineDiff(old, new)
+	if !strings.Contains(diff, `-         "5"`) || !strings.Contains(diff, `+         "25"`) || !strings.Contains(diff, `    "id": "canary",`) {
+		t.Errorf("lineDiff() =\n%s", diff)
+	}
+	if got := lineDiff("", "true"); got != "+ true\n" {
+		t.Errorf("lineDiff() of a new flag = %q", got)
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// maxPendingExposures bounds the rows buffered between two flushes
+const maxPendingExposures = 10000
+
+// column sizes of model.FeatureExposure; one oversize row fails the whole flush
+const (
+	maxExposureNamespace = 64
+	maxExposureKey       = 128
+	maxExposureVariant   = 64
+	maxExposureReason    = 32
+)
+
+type exposureKey struct {
+	env       string
+	namespace string
+	key       string
+	variant   string
+	reason    string
+}
+
+// ExposureService merges SDK exposure reports in memory and periodically adds
+// them to the per flag and env counters in MySQL.
+type ExposureService struct {
+	repo     repository.ExposureInterface
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[exposureKey]*model.FeatureExposure
+	dropped int64
+}
+
+func NewExposureService(repo repository.ExposureInterface, interval time.Duration) *ExposureService {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &ExposureService{
+		repo:     repo,
+		interval: interval,
+		pending:  make(map[exposureKey]*model.FeatureExposure),
+	}
+}
+
+// Record adds a report to the pending counters. Entries outside namespaces, the
+// scope the SDK reported for, or too long for their columns are counted as
+// dropped; variants are only samples of the value and get truncated.
+func (s *ExposureService) Record(report v1.ExposureReport, namespaces map[string]bool) {
+	seen := time.UnixMilli(report.WindowEnd)
+	if report.WindowEnd <= 0 {
+		seen = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped += report.Dropped
+	for _, e := range report.Entries {
+		if e.Count <= 0 || e.Key == "" {
+			continue
+		}
+		if !namespaces[e.Namespace] || !fits(e.Namespace, maxExposureNamespace) || !fits(e.Key, maxExposureKey) || !fits(e.Reason, maxExposureReason) {
+			s.dropped += e.Count
+			continue
+		}
+		e.Variant = truncate(strings.ToValidUTF8(e.Variant, ""), maxExposureVariant)
+		k := exposureKey{env: report.Env, namespace: e.Namespace, key: e.Key, variant: e.Variant, reason: e.Reason}
+		row, ok := s.pending[k]
+		if !ok {
+			if len(s.pending) >= maxPendingExposures {
+				s.dropped += e.Count
+				continue
+			}
+			row = &model.FeatureExposure{Env: report.Env, Namespace: e.Namespace, Key: e.Key, Variant: e.Variant, Reason: e.Reason}
+			s.pending[k] = row
+		}
+		row.Hits += e.Count
+		if seen.After(row.LastSeenAt) {
+			row.LastSeenAt = seen
+		}
+	}
+}
+
+// fits reports whether s is valid UTF-8 and fits a VARCHAR(n) column.
+func fits(s string, n int) bool {
+	return utf8.ValidString(s) && utf8.RuneCountInString(s) <= n
+}
+
+func (s *ExposureService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	logger.Info("exposure flusher started", zap.Duration("interval", s.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			// best effort, the server is shutting down
+			s.flush(context.Background())
+			logger.Info("exposure flusher stopped")
+			return
+		case <-ticker.C:
+			s.flush(ctx)
+		}
+	}
+}
+
+func (s *ExposureService) flush(ctx context.Context) {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	rows := make([]model.FeatureExposure, 0, len(s.pending))
+	for _, row := range s.pending {
+		rows = append(rows, *row)
+	}
+	s.pending = make(map[exposureKey]*model.FeatureExposure)
+	dropped := s.dropped
+	s.dropped = 0
+	s.mu.Unlock()
+
+	if dropped > 0 {
+		logger.Warn("exposures dropped before reaching the server", zap.Int64("count", dropped))
+	}
+	if err := s.repo.Increment(ctx, rows); err != nil {
+		logger.Error("failed to persist exposures", zap.Int("rows", len(rows)), zap.Error(err))
+	}
+}
+
+// ListExposures aggregates the stored counters per flag.
+func (s *ExposureService) ListExposures(ctx context.Context, env, namespace, key string) ([]resp.ExposureItem, error) {
+	rows, err := s.repo.List(ctx, env, namespace, key)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[exposureKey]*resp.ExposureItem)
+	for _, row := range rows {
+		k := exposureKey{env: row.Env, namespace: row.Namespace, key: row.Key}
+		item, ok := items[k]
+		if !ok {
+			item = &resp.ExposureItem{
+				Env:       row.Env,
+				Namespace: row.Namespace,
+				Key:       row.Key,
+				Variants:  make(map[string]int64),
+				Reasons:   make(map[string]int64),
+			}
+			items[k] = item
+		}
+		item.Total += row.Hits
+		item.Variants[row.Variant] += row.Hits
+		item.Reasons[row.Reason] += row.Hits
+		if row.LastSeenAt.After(item.LastSeenAt) {
+			item.LastSeenAt = row.LastSeenAt
+		}
+	}
+
+	result := make([]resp.ExposureItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Env != result[j].Env {
+			return result[i].Env < result[j].Env
+		}
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"mizuflow/internal/model"
+	v1 "mizuflow/pkg/api/v1"
+	"strings"
+	"testing"
+)
+
+type mockExposureRepo struct {
+	rows []model.FeatureExposure
+}
+
+func (m *mockExposureRepo) Increment(ctx context.Context, exposures []model.FeatureExposure) error {
+	m.rows = append(m.rows, exposures...)
+	return nil
+}
+
+func (m *mockExposureRepo) List(ctx context.Context, env, namespace, key string) ([]model.FeatureExposure, error) {
+	return m.rows, nil
+}
+
+func TestExposureService_AggregatesPerFlag(t *testing.T) {
+	repo := &mockExposureRepo{}
+	svc := NewExposureService(repo, 0)
+	scope := map[string]bool{"checkout": true}
+
+	svc.Record(v1.ExposureReport{Env: "prod", WindowEnd: 1000, Entries: []v1.ExposureEntry{
+		{Namespace: "checkout", Key: "new-ui", Variant: "true", Reason: "TARGET_MATCH", Count: 3},
+		{Namespace: "checkout", Key: "new-ui", Variant: "false", Reason: "DEFAULT", Count: 2},
+	}}, scope)
+	svc.Record(v1.ExposureReport{Env: "prod", WindowEnd: 2000, Entries: []v1.ExposureEntry{
+		{Namespace: "checkout", Key: "new-ui", Variant: "true", Reason: "TARGET_MATCH", Count: 4},
+		{Namespace: "checkout", Key: "limit", Variant: "10", Reason: "DEFAULT", Count: 1},
+	}}, scope)
+	svc.flush(context.Background())
+
+	if len(repo.rows) != 3 {
+		t.Fatalf("flushed %d rows, want 3 merged rows", len(repo.rows))
+	}
+
+	items, err := svc.ListExposures(context.Background(), "prod", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[1].Key != "new-ui" {
+		t.Fatalf("ListExposures() = %+v", items)
+	}
+	item := items[1]
+	if item.Total != 9 || item.Variants["true"] != 7 || item.Reasons["DEFAULT"] != 2 {
+		t.Errorf("new-ui aggregate = %+v", item)
+	}
+	if item.LastSeenAt.UnixMilli() != 2000 {
+		t.Errorf("new-ui last seen = %v, want the latest window end", item.LastSeenAt)
+	}
+}
+
+func TestExposureService_RecordValidates(t *testing.T) {
+	repo := &mockExposureRepo{}
+	svc := NewExposureService(repo, 0)
+
+	svc.Record(v1.ExposureReport{Env: "prod", Entries: []v1.ExposureEntry{
+		{Namespace: "checkout", Key: "theme", Variant: strings.Repeat("é", 100), Reason: "DEFAULT", Count: 1},
+		{Namespace: "payments", Key: "limit", Variant: "10", Reason: "DEFAULT", Count: 2},
+		{Namespace: "checkout", Key: strings.Repeat("k", 129), Variant: "true", Reason: "DEFAULT", Count: 3},
+		{Namespace: "checkout", Key: "new-ui", Variant: "true", Reason: strings.Repeat("R", 33), Count: 4},
+	}}, map[string]bool{"checkout": true})
+
+	if svc.dropped != 9 {
+		t.Errorf("dropped = %d, want the entries outside the scope or too long", svc.dropped)
+	}
+	svc.flush(context.Background())
+	if len(repo.rows) != 1 || repo.rows[0].Variant != strings.Repeat("é", 64) {
+		t.Fatalf("flushed %+v, want the theme row with its variant cut to 64 characters", repo.rows)
+	}
+}
@@ -10,10 +10,15 @@ import (
 	"mizuflow/internal/repository"
 	v1 "mizuflow/pkg/api/v1"
 	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/semver"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"mizuflow/internal/dto/req"
 	"mizuflow/internal/dto/resp"
 
 	"mizuflow/pkg/logger"
@@ -26,6 +31,44 @@ import (
 var ErrAuditNotMatch = errors.New("audit record key mismatch")
 var ErrEtcdUnhealthy = errors.New("etcd unhealthy")
 var ErrMysqlUnhealthy = errors.New("mysql unhealthy")
+var ErrFeatureNotFound = errors.New("feature not found")
+var ErrFeatureArchived = errors.New("feature is archived, restore it first")
+var ErrAuditNotUpdate = errors.New("only update audit entries can be rolled back")
+var ErrFeatureAlreadyArchived = errors.New("feature is already archived")
+var ErrFeatureNotArchived = errors.New("feature is not archived")
+var ErrFeatureInUse = errors.New("feature is in use")
+var ErrPrerequisite = errors.New("invalid prerequisites")
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// VersionConflictError rejects a write made against a version of the flag that
+// is no longer current. Current is 0 when the flag does not exist.
+type VersionConflictError struct {
+	Expected     int
+	Current      int
+	CurrentValue string
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict: expected version %d, current version is %d", e.Expected, e.Current)
+}
+
+// checkExpectedVersion compares the locked master record, nil when the flag
+// does not exist, with the version the writer based its change on. A nil
+// expected version skips the check.
+func checkExpectedVersion(master *model.FeatureMaster, expected *int) error {
+	if expected == nil {
+		return nil
+	}
+	var current int
+	var value string
+	if master != nil {
+		current, value = master.Version, master.CurrentVal
+	}
+	if current != *expected {
+		return &VersionConflictError{Expected: *expected, Current: current, CurrentValue: value}
+	}
+	return nil
+}
 
 const FeatureRootPrefix = "/mizuflow/"
 
@@ -42,13 +85,14 @@ type FeatureService struct {
 	buffer      *buffer.RevisionBuffer
 	cache       *FeatureCache
 	hub         *Hub
+	protection  Protection
 }
 
 type Transactional interface {
 	WithTx(tx *gorm.DB) any
 }
 
-func NewFeatureService(db *gorm.DB, etcdRepo *repository.FeatureRepository, mysqlRepo repository.AuditInterface, featureRepo repository.FeatureInterface, outboxRepo repository.OutboxInterface, hub *Hub) *FeatureService {
+func NewFeatureService(db *gorm.DB, etcdRepo *repository.FeatureRepository, mysqlRepo repository.AuditInterface, featureRepo repository.FeatureInterface, outboxRepo repository.OutboxInterface, hub *Hub, protection Protection) *FeatureService {
 	return &FeatureService{
 		db:          db,
 		etcdRepo:    etcdRepo,
@@ -58,6 +102,7 @@ func NewFeatureService(db *gorm.DB, etcdRepo *repository.FeatureRepository, mysq
 		hub:         hub,
 		buffer:      buffer.NewRevisionBuffer(1000),
 		cache:       NewFeatureCache(),
+		protection:  protection,
 	}
 }
 
@@ -65,10 +110,21 @@ func (s *FeatureService) GetCompensation(lastRev int64) ([]v1.Message, bool) {
 	return s.buffer.GetSince(lastRev)
 }
 
-func (s *FeatureService) SaveFeature(ctx context.Context, flag v1.FeatureFlag, operator string) (int, error) {
+// SaveFeature creates or updates a flag, replacing its metadata too when meta is
+// set. With expectedVersion set the write only succeeds while the flag is at
+// that version, 0 meaning it must not exist yet. It does not check Protection:
+// approved change requests are applied through it, callers check first.
+func (s *FeatureService) SaveFeature(ctx context.Context, flag v1.FeatureFlag, meta *req.FeatureMetadata, expectedVersion *int, operator string) (int, error) {
 	if err := s.validatePayload(flag.Type, flag.Value); err != nil {
 		return 0, err
 	}
+	if meta != nil {
+		normalized, err := normalizeMetadata(*meta)
+		if err != nil {
+			return 0, err
+		}
+		meta = &normalized
+	}
 
 	var lastestVersion int
 	var outboxID uint64
@@ -77,17 +133,22 @@ func (s *FeatureService) SaveFeature(ctx context.Context, flag v1.FeatureFlag, o
 
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		txFeature := s.featureRepo.WithTx(tx).(repository.FeatureInterface)
-		txAudit := s.auditRepo.WithTx(tx).(repository.AuditInterface)
-		txOutbox := s.outboxRepo.WithTx(tx).(repository.OutboxInterface)
 
-		// maintain master record
-		master, err := txFeature.GetByKey(ctx, flag.Namespace, flag.Env, flag.Key)
+		// maintain master record, locked so the version check holds until commit
+		master, err := txFeature.GetByKeyForUpdate(ctx, flag.Namespace, flag.Env, flag.Key)
 
 		if err != nil {
 			logger.Error("failed to get feature master", zap.String("key", flag.Key), zap.Error(err))
 			return err
 		}
+		if err := checkExpectedVersion(master, expectedVersion); err != nil {
+			return err
+		}
+		if err := s.validatePrerequisites(ctx, txFeature, flag); err != nil {
+			return err
+		}
 		var oldValue string
+		var oldMeta req.FeatureMetadata
 
 		if master == nil {
 			master = &model.FeatureMaster{
@@ -97,64 +158,378 @@ func (s *FeatureService) SaveFeature(ctx context.Context, flag v1.FeatureFlag, o
 				Type:       flag.Type,
 				Version:    1,
 				CurrentVal: flag.Value,
+				Status:     model.FeatureStatusActive,
 			}
+		} else if master.Archived() {
+			return ErrFeatureArchived
 		} else {
 			oldValue = master.CurrentVal
+			oldMeta = metadataOf(master)
 			master.Version++
 			master.CurrentVal = flag.Value
 			master.Type = flag.Type
 		}
+		if meta != nil {
+			applyMetadata(master, *meta)
+		}
 		txFeature.Save(ctx, master)
 		lastestVersion = master.Version
-		// record audit logging
-		audit := &model.FeatureAudit{
+		flag.Version = lastestVersion
+		flag.Generation = master.ID
+
+		if meta != nil {
+			if err := s.recordMetadataChange(ctx, tx, master, oldMeta, operator, traceID); err != nil {
+				return err
+			}
+		}
+		outboxID, err = s.recordChange(ctx, tx, &model.FeatureAudit{
 			Namespace: flag.Namespace,
 			Env:       flag.Env,
 			Key:       flag.Key,
 			OldValue:  oldValue,
 			NewValue:  flag.Value,
 			Type:      flag.Type,
+			Action:    model.AuditActionUpdate,
 			Operator:  operator,
 			TraceID:   traceID,
+		}, outboxPayload{FeatureFlag: flag})
+		return err
+	})
+
+	var conflict *VersionConflictError
+	if errors.Is(err, ErrFeatureArchived) || errors.Is(err, ErrPrerequisite) || errors.As(err, &conflict) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, errors.New("feature save failed")
+	}
+
+	go s.syncToEtcd(outboxID, outboxPayload{FeatureFlag: flag})
+	return lastestVersion, nil
+}
+
+// DeleteFeature archives a flag, or with hard set removes its record. Either
+// way its etcd key is deleted through the outbox, so SDKs drop it.
+func (s *FeatureService) DeleteFeature(ctx context.Context, namespace, env, key string, hard bool, operator string) (int, error) {
+	if err := s.protection.Check(env); err != nil {
+		return 0, err
+	}
+	return s.deleteFeature(ctx, namespace, env, key, hard, nil, operator)
+}
+
+// deleteFeature is DeleteFeature without the protection check, for approved
+// change requests, which also pass the version they were based on.
+func (s *FeatureService) deleteFeature(ctx context.Context, namespace, env, key string, hard bool, expectedVersion *int, operator string) (int, error) {
+	var version int
+	var outboxID uint64
+	var payload outboxPayload
+	traceID, _ := ctx.Value("TraceID").(string)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txFeature := s.featureRepo.WithTx(tx).(repository.FeatureInterface)
+
+		master, err := txFeature.GetByKeyForUpdate(ctx, namespace, env, key)
+		if err != nil {
+			logger.Error("failed to get feature master", zap.String("key", key), zap.Error(err))
+			return err
 		}
-		if err := txAudit.Create(ctx, audit); err != nil {
-			logger.Error("failed to create feature audit", zap.String("key", flag.Key), zap.Error(err))
+		if master == nil {
+			return ErrFeatureNotFound
+		}
+		if err := checkExpectedVersion(master, expectedVersion); err != nil {
+			return err
+		}
+		if master.Archived() && !hard {
+			return ErrFeatureAlreadyArchived
+		}
+		// checked under the row lock: a writer adding this flag as prerequisite
+		// locks it too, so no dependent appears before the commit
+		if err := s.checkNotPrerequisite(ctx, txFeature, namespace, env, key); err != nil {
 			return err
 		}
 
-		// create outbox event
-		flag.Version = lastestVersion
-		pBytes, _ := json.Marshal(flag)
-		event := &model.OutboxTask{
-			Key:     flag.Key,
-			Payload: string(pBytes),
-			Status:  model.StatusPending,
-			TraceID: traceID,
-		}
-		if err := txOutbox.Create(ctx, event); err != nil {
-			logger.Error("failed to create outbox event", zap.String("key", flag.Key), zap.Error(err))
+		master.Version++
+		action := model.AuditActionArchive
+		if hard {
+			action = model.AuditActionDelete
+			err = txFeature.Delete(ctx, master)
+		} else {
+			master.Status = model.FeatureStatusArchived
+			err = txFeature.Save(ctx, master)
+		}
+		if err != nil {
+			logger.Error("failed to remove feature master", zap.String("key", key), zap.Error(err))
 			return err
 		}
-		outboxID = uint64(event.ID)
+		version = master.Version
 
-		return nil
+		payload = outboxPayload{
+			FeatureFlag: v1.FeatureFlag{Namespace: namespace, Env: env, Key: key, Type: master.Type, Version: version, Generation: master.ID},
+			Deleted:     true,
+		}
+		outboxID, err = s.recordChange(ctx, tx, &model.FeatureAudit{
+			Namespace: namespace,
+			Env:       env,
+			Key:       key,
+			OldValue:  master.CurrentVal,
+			Type:      master.Type,
+			Action:    action,
+			Operator:  operator,
+			TraceID:   traceID,
+		}, payload)
+		return err
 	})
+	if err != nil {
+		return 0, err
+	}
 
+	// a flag recreated right after a hard delete has a new generation, so this
+	// delete leaves it alone even if its put is published first
+	go s.syncToEtcd(outboxID, payload)
+	return version, nil
+}
+
+// RestoreFeature brings an archived flag back with the value it had.
+func (s *FeatureService) RestoreFeature(ctx context.Context, namespace, env, key, operator string) (int, error) {
+	if err := s.protection.Check(env); err != nil {
+		return 0, err
+	}
+	return s.restoreFeature(ctx, namespace, env, key, nil, operator)
+}
+
+// restoreFeature is RestoreFeature without the protection check, for approved
+// change requests, which also pass the version they were based on.
+func (s *FeatureService) restoreFeature(ctx context.Context, namespace, env, key string, expectedVersion *int, operator string) (int, error) {
+	var flag v1.FeatureFlag
+	var outboxID uint64
+	traceID, _ := ctx.Value("TraceID").(string)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txFeature := s.featureRepo.WithTx(tx).(repository.FeatureInterface)
+
+		master, err := txFeature.GetByKeyForUpdate(ctx, namespace, env, key)
+		if err != nil {
+			logger.Error("failed to get feature master", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		if master == nil {
+			return ErrFeatureNotFound
+		}
+		if err := checkExpectedVersion(master, expectedVersion); err != nil {
+			return err
+		}
+		if !master.Archived() {
+			return ErrFeatureNotArchived
+		}
+		flag = v1.FeatureFlag{Namespace: namespace, Env: env, Key: key, Value: master.CurrentVal, Type: master.Type, Generation: master.ID}
+		// its prerequisites may have been archived meanwhile
+		if err := s.validatePrerequisites(ctx, txFeature, flag); err != nil {
+			return err
+		}
+
+		master.Version++
+		master.Status = model.FeatureStatusActive
+		if err := txFeature.Save(ctx, master); err != nil {
+			logger.Error("failed to restore feature master", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		flag.Version = master.Version
+
+		outboxID, err = s.recordChange(ctx, tx, &model.FeatureAudit{
+			Namespace: namespace,
+			Env:       env,
+			Key:       key,
+			NewValue:  master.CurrentVal,
+			Type:      master.Type,
+			Action:    model.AuditActionRestore,
+			Operator:  operator,
+			TraceID:   traceID,
+		}, outboxPayload{FeatureFlag: flag})
+		return err
+	})
 	if err != nil {
-		return 0, errors.New("feature save failed")
+		return 0, err
 	}
 
-	go s.syncToEtcd(outboxID, flag)
-	return lastestVersion, nil
+	go s.syncToEtcd(outboxID, outboxPayload{FeatureFlag: flag})
+	return flag.Version, nil
+}
+
+// UpdateFeatureMetadata replaces the metadata of a flag. Only an audit entry is
+// written: the value, its version and etcd stay as they are.
+func (s *FeatureService) UpdateFeatureMetadata(ctx context.Context, namespace, env, key string, meta req.FeatureMetadata, operator string) (*resp.FeatureItem, error) {
+	meta, err := normalizeMetadata(meta)
+	if err != nil {
+		return nil, err
+	}
+	traceID, _ := ctx.Value("TraceID").(string)
+
+	var master *model.FeatureMaster
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txFeature := s.featureRepo.WithTx(tx).(repository.FeatureInterface)
+
+		master, err = txFeature.GetByKeyForUpdate(ctx, namespace, env, key)
+		if err != nil {
+			logger.Error("failed to get feature master", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		if master == nil {
+			return ErrFeatureNotFound
+		}
+		oldMeta := metadataOf(master)
+		applyMetadata(master, meta)
+		if err := txFeature.Save(ctx, master); err != nil {
+			logger.Error("failed to save feature metadata", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		return s.recordMetadataChange(ctx, tx, master, oldMeta, operator, traceID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return featureItem(master), nil
+}
+
+// recordMetadataChange audits the metadata of master when it differs from old.
+func (s *FeatureService) recordMetadataChange(ctx context.Context, tx *gorm.DB, master *model.FeatureMaster, old req.FeatureMetadata, operator, traceID string) error {
+	oldJSON, _ := json.Marshal(old)
+	newJSON, _ := json.Marshal(metadataOf(master))
+	if string(oldJSON) == string(newJSON) {
+		return nil
+	}
+	txAudit := s.auditRepo.WithTx(tx).(repository.AuditInterface)
+	err := txAudit.Create(ctx, &model.FeatureAudit{
+		Namespace: master.Namespace,
+		Env:       master.Env,
+		Key:       master.Key,
+		OldValue:  string(oldJSON),
+		NewValue:  string(newJSON),
+		Type:      master.Type,
+		Action:    model.AuditActionMetadata,
+		Operator:  operator,
+		TraceID:   traceID,
+	})
+	if err != nil {
+		logger.Error("failed to create feature audit", zap.String("key", master.Key), zap.Error(err))
+	}
+	return err
+}
+
+// Metadata limits, matching the feature_master columns.
+const (
+	maxDescriptionLen = 255
+	maxOwnerLen       = 64
+	maxTags           = 20
+	maxTagLen         = 32
+)
+
+// normalizeMetadata trims the fields, sorts and dedupes the tags and stores the
+// expiry in UTC seconds, so equal metadata compares and audits the same.
+func normalizeMetadata(meta req.FeatureMetadata) (req.FeatureMetadata, error) {
+	out := req.FeatureMetadata{
+		Description: strings.TrimSpace(meta.Description),
+		Owner:       strings.TrimSpace(meta.Owner),
+	}
+	if utf8.RuneCountInString(out.Description) > maxDescriptionLen {
+		return out, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidMetadata, maxDescriptionLen)
+	}
+	if utf8.RuneCountInString(out.Owner) > maxOwnerLen {
+		return out, fmt.Errorf("%w: owner is longer than %d characters", ErrInvalidMetadata, maxOwnerLen)
+	}
+	for _, tag := range meta.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(out.Tags, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLen {
+			return out, fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidMetadata, tag, maxTagLen)
+		}
+		out.Tags = append(out.Tags, tag)
+	}
+	if len(out.Tags) > maxTags {
+		return out, fmt.Errorf("%w: a flag has at most %d tags", ErrInvalidMetadata, maxTags)
+	}
+	slices.Sort(out.Tags)
+	if meta.ExpiresAt != nil {
+		at := meta.ExpiresAt.UTC().Truncate(time.Second)
+		out.ExpiresAt = &at
+	}
+	return out, nil
+}
+
+func metadataOf(m *model.FeatureMaster) req.FeatureMetadata {
+	meta := req.FeatureMetadata{Description: m.Description, Owner: m.Owner}
+	if len(m.Tags) > 0 {
+		meta.Tags = m.Tags
+	}
+	if m.ExpiresAt != nil {
+		at := m.ExpiresAt.UTC()
+		meta.ExpiresAt = &at
+	}
+	return meta
+}
+
+func applyMetadata(m *model.FeatureMaster, meta req.FeatureMetadata) {
+	m.Description = meta.Description
+	m.Owner = meta.Owner
+	m.Tags = meta.Tags
+	m.ExpiresAt = meta.ExpiresAt
 }
 
-func (s *FeatureService) syncToEtcd(outboxID uint64, flag v1.FeatureFlag) {
+// recordChange writes the audit entry and the outbox task of a change, within
+// the transaction that updates the master record, and returns the task id.
+func (s *FeatureService) recordChange(ctx context.Context, tx *gorm.DB, audit *model.FeatureAudit, payload outboxPayload) (uint64, error) {
+	txAudit := s.auditRepo.WithTx(tx).(repository.AuditInterface)
+	txOutbox := s.outboxRepo.WithTx(tx).(repository.OutboxInterface)
+
+	// record audit logging
+	if err := txAudit.Create(ctx, audit); err != nil {
+		logger.Error("failed to create feature audit", zap.String("key", audit.Key), zap.Error(err))
+		return 0, err
+	}
+
+	// create outbox event
+	pBytes, _ := json.Marshal(payload)
+	event := &model.OutboxTask{
+		Key:     audit.Key,
+		Payload: string(pBytes),
+		Status:  model.StatusPending,
+		TraceID: audit.TraceID,
+	}
+	if err := txOutbox.Create(ctx, event); err != nil {
+		logger.Error("failed to create outbox event", zap.String("key", audit.Key), zap.Error(err))
+		return 0, err
+	}
+	return uint64(event.ID), nil
+}
+
+// outboxPayload is the JSON of an outbox task: the flag to put into etcd or,
+// with Deleted set, the flag whose key to delete. Tasks written before
+// deletions existed decode as puts.
+type outboxPayload struct {
+	v1.FeatureFlag
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// publish applies an outbox payload to etcd. Both directions compare
+// generations and versions, so a task that arrives late cannot undo a newer
+// change, not even one to a flag recreated after a hard delete.
+func publish(ctx context.Context, etcdRepo *repository.FeatureRepository, payload outboxPayload) error {
 	// construct key with namespace and env
 	// e.g., /mizuflow/dev/default/features/my-feature
-	fullKey := BuildFeatureKey(flag.Env, flag.Namespace, flag.Key)
-	_, err := s.etcdRepo.SaveFeatureIfNewer(context.Background(), fullKey, flag)
-	if err != nil {
-		logger.Warn("failed to sync feature to etcd", zap.String("key", flag.Key), zap.Error(err))
+	fullKey := BuildFeatureKey(payload.Env, payload.Namespace, payload.Key)
+	var err error
+	if payload.Deleted {
+		_, err = etcdRepo.DeleteFeatureIfNotNewer(ctx, fullKey, payload.FeatureFlag)
+	} else {
+		_, err = etcdRepo.SaveFeatureIfNewer(ctx, fullKey, payload.FeatureFlag)
+	}
+	return err
+}
+
+func (s *FeatureService) syncToEtcd(outboxID uint64, payload outboxPayload) {
+	if err := publish(context.Background(), s.etcdRepo, payload); err != nil {
+		logger.Warn("failed to sync feature to etcd", zap.String("key", payload.Key), zap.Error(err))
 		return
 	}
 	_ = s.outboxRepo.UpdateStatus(context.Background(), outboxID, model.StatusCompleted, 0)
@@ -178,11 +553,20 @@ func (s *FeatureService) validatePayload(typeStr, value string) error {
 		if strategy.DefaultValue == "" {
 			return errors.New("strategy must have a default value")
 		}
-		for _, rule := range strategy.Rules {
-			if rule.Attribute == "" || rule.Operator == "" {
-				return errors.New("strategy rule must have attribute and operator")
+		seen := make(map[string]bool, len(strategy.Prerequisites))
+		for _, p := range strategy.Prerequisites {
+			if p.Key == "" {
+				return errors.New("prerequisite must have a key")
+			}
+			if seen[p.Key] {
+				return fmt.Errorf("duplicate prerequisite %q", p.Key)
+			}
+			seen[p.Key] = true
+		}
+		for i, rule := range strategy.Rules {
+			if err := validateRule(rule); err != nil {
+				return fmt.Errorf("invalid rule #%d: %w", i, err)
 			}
-			// todo further validation can be added here
 		}
 	case constraints.TypeJSON:
 		if !json.Valid([]byte(value)) {
@@ -197,50 +581,286 @@ func (s *FeatureService) validatePayload(typeStr, value string) error {
 	return nil
 }
 
+// validatePrerequisites checks that the prerequisites of a strategy exist in the
+// same env and namespace and that saving it does not close a dependency cycle.
+func (s *FeatureService) validatePrerequisites(ctx context.Context, repo repository.FeatureInterface, flag v1.FeatureFlag) error {
+	keys := prerequisiteKeys(flag.Type, flag.Value)
+	if len(keys) == 0 {
+		return nil
+	}
+	masters, err := repo.List(ctx, repository.FeatureFilter{Namespace: flag.Namespace, Env: flag.Env})
+	if err != nil {
+		logger.Error("failed to list features for prerequisite check", zap.String("key", flag.Key), zap.Error(err))
+		return err
+	}
+	graph := make(map[string][]string, len(masters)+1)
+	for _, m := range masters {
+		if m.Archived() {
+			continue
+		}
+		graph[m.Key] = prerequisiteKeys(m.Type, m.CurrentVal)
+	}
+	graph[flag.Key] = keys
+
+	for _, key := range keys {
+		if key == flag.Key {
+			return fmt.Errorf("%w: flag cannot be its own prerequisite", ErrPrerequisite)
+		}
+		if _, ok := graph[key]; !ok {
+			return fmt.Errorf("%w: prerequisite %q does not exist in %s/%s", ErrPrerequisite, key, flag.Env, flag.Namespace)
+		}
+	}
+	if cycle := findCycle(graph, flag.Key); cycle != nil {
+		return fmt.Errorf("%w: prerequisite cycle: %s", ErrPrerequisite, strings.Join(cycle, " -> "))
+	}
+	// lock the prerequisites, so a delete of one of them waits for this write
+	// and then sees it as a dependent
+	for _, key := range keys {
+		m, err := repo.GetByKeyForUpdate(ctx, flag.Namespace, flag.Env, key)
+		if err != nil {
+			return err
+		}
+		if m == nil || m.Archived() {
+			return fmt.Errorf("%w: prerequisite %q does not exist in %s/%s", ErrPrerequisite, key, flag.Env, flag.Namespace)
+		}
+	}
+	return nil
+}
+
+// checkNotPrerequisite refuses to remove a flag that active flags depend on.
+func (s *FeatureService) checkNotPrerequisite(ctx context.Context, repo repository.FeatureInterface, namespace, env, key string) error {
+	masters, err := repo.List(ctx, repository.FeatureFilter{Namespace: namespace, Env: env})
+	if err != nil {
+		logger.Error("failed to list features for prerequisite check", zap.String("key", key), zap.Error(err))
+		return err
+	}
+	var dependents []string
+	for _, m := range masters {
+		if !m.Archived() && slices.Contains(prerequisiteKeys(m.Type, m.CurrentVal), key) {
+			dependents = append(dependents, m.Key)
+		}
+	}
+	if len(dependents) > 0 {
+		slices.Sort(dependents)
+		return fmt.Errorf("%w: %s is a prerequisite of %s", ErrFeatureInUse, key, strings.Join(dependents, ", "))
+	}
+	return nil
+}
+
+func prerequisiteKeys(typeStr, value string) []string {
+	if typeStr != constraints.TypeStrategy {
+		return nil
+	}
+	var strategy v1.FeatureStrategy
+	if err := json.Unmarshal([]byte(value), &strategy); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(strategy.Prerequisites))
+	for _, p := range strategy.Prerequisites {
+		keys = append(keys, p.Key)
+	}
+	return keys
+}
+
+// findCycle returns a dependency path from start back to itself, if any. The
+// stored flags form no cycle, so a new one has to pass through start.
+func findCycle(graph map[string][]string, start string) []string {
+	visited := make(map[string]bool)
+	var walk func(key string, path []string) []string
+	walk = func(key string, path []string) []string {
+		for _, next := range graph[key] {
+			if next == start {
+				return append(path, next)
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if cycle := walk(next, append(path, next)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return walk(start, []string{start})
+}
+
+// maxConditionDepth bounds the nesting of condition groups in a single rule
+const maxConditionDepth = 8
+
+func validateRule(rule v1.Rule) error {
+	if rule.Rollout != nil {
+		if err := validateRollout(rule.Rollout); err != nil {
+			return err
+		}
+		// a rollout without a condition applies to everyone
+		if !rule.HasCondition() {
+			return nil
+		}
+	}
+	if len(rule.Conditions) > 0 && (rule.Attribute != "" || rule.Operator != "" || len(rule.Values) > 0) {
+		return errors.New("strategy rule must use either attribute/operator or conditions, not both")
+	}
+	return validateCondition(rule.Condition(), 0)
+}
+
+func validateRollout(rollout *v1.Rollout) error {
+	// an empty bucket_by buckets by the SDK targeting key
+	if len(rollout.Variants) == 0 {
+		return errors.New("rollout must have at least one variant")
+	}
+	total := 0
+	seen := make(map[string]bool, len(rollout.Variants))
+	for _, variant := range rollout.Variants {
+		if variant.Weight < 0 {
+			return fmt.Errorf("variant %q has a negative weight", variant.Value)
+		}
+		if seen[variant.Value] {
+			return fmt.Errorf("duplicate variant %q", variant.Value)
+		}
+		seen[variant.Value] = true
+		total += variant.Weight
+	}
+	if total != 100 {
+		return fmt.Errorf("variant weights must add up to 100, got %d", total)
+	}
+	return nil
+}
+
+func validateCondition(cond v1.Condition, depth int) error {
+	if depth >= maxConditionDepth {
+		return fmt.Errorf("conditions nested deeper than %d levels", maxConditionDepth)
+	}
+	if cond.IsGroup() {
+		if cond.Attribute != "" || cond.Operator != "" || len(cond.Values) > 0 {
+			return errors.New("condition group must not have attribute or operator")
+		}
+		switch cond.Combinator {
+		case "", constraints.CombinatorAnd, constraints.CombinatorOr:
+		default:
+			return fmt.Errorf("unknown combinator %q", cond.Combinator)
+		}
+		for _, sub := range cond.Conditions {
+			if err := validateCondition(sub, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if cond.Attribute == "" || cond.Operator == "" {
+		return errors.New("strategy rule must have attribute and operator")
+	}
+	values := cond.Values
+	switch cond.Operator {
+	case constraints.OpIn, constraints.OpNotIn, constraints.OpContains, constraints.OpStartsWith, constraints.OpEndsWith:
+		if len(values) == 0 {
+			return fmt.Errorf("operator %s requires at least one value", cond.Operator)
+		}
+	case constraints.OpEq, constraints.OpNeq:
+		if len(values) != 1 {
+			return fmt.Errorf("operator %s requires exactly one value", cond.Operator)
+		}
+	case constraints.OpMod:
+		if len(values) != 1 {
+			return fmt.Errorf("operator %s requires exactly one value", cond.Operator)
+		}
+		threshold, err := strconv.Atoi(values[0])
+		if err != nil || threshold < 0 || threshold > 100 {
+			return errors.New("mod threshold must be an integer between 0 and 100")
+		}
+	case constraints.OpGt, constraints.OpGte, constraints.OpLt, constraints.OpLte:
+		if len(values) != 1 {
+			return fmt.Errorf("operator %s requires exactly one value", cond.Operator)
+		}
+		if _, err := strconv.ParseFloat(values[0], 64); err != nil {
+			if _, err := time.Parse(time.RFC3339, values[0]); err != nil {
+				return fmt.Errorf("operator %s requires a numeric or RFC3339 timestamp value", cond.Operator)
+			}
+		}
+	case constraints.OpSemverGt, constraints.OpSemverLt:
+		if len(values) != 1 {
+			return fmt.Errorf("operator %s requires exactly one value", cond.Operator)
+		}
+		if _, err := semver.Parse(values[0]); err != nil {
+			return fmt.Errorf("invalid version %q", values[0])
+		}
+	case constraints.OpSemverRange:
+		if len(values) != 2 {
+			return fmt.Errorf("operator %s requires a lower and an upper version", cond.Operator)
+		}
+		lower, err := semver.Parse(values[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", values[0])
+		}
+		upper, err := semver.Parse(values[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", values[1])
+		}
+		if semver.Compare(lower, upper) >= 0 {
+			return errors.New("semver_range lower bound must be below upper bound")
+		}
+	case constraints.OpRegex:
+		if len(values) != 1 {
+			return fmt.Errorf("operator %s requires exactly one value", cond.Operator)
+		}
+		if _, err := regexp.Compile(values[0]); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", cond.Operator)
+	}
+	return nil
+}
+
 func (s *FeatureService) GetFeature(ctx context.Context, namespace, env, key string) (*resp.FeatureItem, error) {
 	m, err := s.featureRepo.GetByKey(ctx, namespace, env, key)
 	if err != nil {
 		return nil, err
 	}
 	if m == nil {
-		return nil, errors.New("feature not found")
+		return nil, ErrFeatureNotFound
 	}
 
-	return &resp.FeatureItem{
-		ID:        m.ID,
-		Namespace: m.Namespace,
-		Env:       m.Env,
-		Key:       m.Key,
-		Type:      m.Type,
-		Version:   m.Version,
-		Value:     m.CurrentVal,
-		UpdatedAt: m.UpdatedAt,
-		UpdatedBy: m.UpdatedBy,
-	}, nil
-}
-
-func (s *FeatureService) ListFeatures(ctx context.Context, namespace, env, search string) ([]resp.FeatureItem, error) {
-	masters, err := s.featureRepo.List(ctx, namespace, env, search)
+	return featureItem(m), nil
+}
+
+// ListFeatures leaves archived flags out unless includeArchived is set.
+func (s *FeatureService) ListFeatures(ctx context.Context, filter repository.FeatureFilter, includeArchived bool) ([]resp.FeatureItem, error) {
+	masters, err := s.featureRepo.List(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 	items := make([]resp.FeatureItem, 0, len(masters))
 	for _, m := range masters {
-		items = append(items, resp.FeatureItem{
-			ID:        m.ID,
-			Namespace: m.Namespace,
-			Env:       m.Env,
-			Key:       m.Key,
-			Type:      m.Type,
-			Version:   m.Version,
-			Value:     m.CurrentVal,
-			UpdatedAt: m.UpdatedAt,
-			UpdatedBy: m.UpdatedBy,
-		})
+		if m.Archived() && !includeArchived {
+			continue
+		}
+		items = append(items, *featureItem(m))
 	}
 	return items, nil
 }
 
+func featureItem(m *model.FeatureMaster) *resp.FeatureItem {
+	meta := metadataOf(m)
+	return &resp.FeatureItem{
+		ID:          m.ID,
+		Namespace:   m.Namespace,
+		Env:         m.Env,
+		Key:         m.Key,
+		Type:        m.Type,
+		Version:     m.Version,
+		Value:       m.CurrentVal,
+		Archived:    m.Archived(),
+		UpdatedAt:   m.UpdatedAt,
+		UpdatedBy:   m.UpdatedBy,
+		Description: meta.Description,
+		Owner:       meta.Owner,
+		Tags:        meta.Tags,
+		ExpiresAt:   meta.ExpiresAt,
+	}
+}
+
 func (s *FeatureService) GetFeatureAudits(ctx context.Context, namespace, env, key string) ([]resp.AuditLogItem, error) {
 	audits, err := s.auditRepo.ListByKey(ctx, namespace, env, key)
 	if err != nil {
@@ -256,6 +876,7 @@ func (s *FeatureService) GetFeatureAudits(ctx context.Context, namespace, env, k
 			OldValue:  a.OldValue,
 			NewValue:  a.NewValue,
 			Type:      a.Type,
+			Action:    a.Action,
 			Operator:  a.Operator,
 			CreatedAt: a.CreatedAt,
 		})
@@ -269,24 +890,28 @@ func (s *FeatureService) GetAllFeatures(ctx context.Context) ([]v1.FeatureFlag,
 	return snapshot, rev
 }
 
-func (s *FeatureService) RollbackFeature(ctx context.Context, namespace, env, key string, auditID uint, operator string) (int, error) {
-	audit, err := s.auditRepo.FindByID(ctx, auditID)
-	if err != nil {
+// RollbackFeature restores the value a flag had before the given audit entry.
+// Without expectedVersion it is checked against the version read here, so a
+// change landing meanwhile is not overwritten either.
+func (s *FeatureService) RollbackFeature(ctx context.Context, namespace, env, key string, auditID uint, expectedVersion *int, operator string) (int, error) {
+	if err := s.protection.Check(env); err != nil {
 		return 0, err
 	}
-	// Security Check: Ensure the audit log belongs to the context we are operating on
-	if audit.Key != key || audit.Env != env || audit.Namespace != namespace {
-		return 0, fmt.Errorf("audit record mismatch: valid for %s/%s/%s only", audit.Env, audit.Namespace, audit.Key)
-	}
-
-	// Fetch current version for CAS protection
-	master, err := s.featureRepo.GetByKey(ctx, namespace, env, key)
+	audit, err := s.rollbackTarget(ctx, namespace, env, key, auditID)
 	if err != nil {
 		return 0, err
 	}
-	var currentVersion int
-	if master != nil {
-		currentVersion = master.Version
+
+	if expectedVersion == nil {
+		master, err := s.featureRepo.GetByKey(ctx, namespace, env, key)
+		if err != nil {
+			return 0, err
+		}
+		var currentVersion int
+		if master != nil {
+			currentVersion = master.Version
+		}
+		expectedVersion = &currentVersion
 	}
 
 	logger.Info("rolling back feature", zap.String("key", key), zap.String("from_val", audit.NewValue), zap.String("to_val", audit.OldValue))
@@ -297,8 +922,24 @@ func (s *FeatureService) RollbackFeature(ctx context.Context, namespace, env, ke
 		Key:       key,
 		Value:     audit.OldValue,
 		Type:      audit.Type,
-		Version:   currentVersion, // Use current version to enforce CAS
-	}, operator)
+	}, nil, expectedVersion, operator)
+}
+
+// rollbackTarget loads the audit entry a rollback of the flag restores.
+func (s *FeatureService) rollbackTarget(ctx context.Context, namespace, env, key string, auditID uint) (*model.FeatureAudit, error) {
+	audit, err := s.auditRepo.FindByID(ctx, auditID)
+	if err != nil {
+		return nil, err
+	}
+	// Security Check: Ensure the audit log belongs to the context we are operating on
+	if audit.Key != key || audit.Env != env || audit.Namespace != namespace {
+		return nil, fmt.Errorf("audit record mismatch: valid for %s/%s/%s only", audit.Env, audit.Namespace, audit.Key)
+	}
+	// other entries hold metadata JSON or no value at all in OldValue
+	if audit.Action != "" && audit.Action != model.AuditActionUpdate {
+		return nil, fmt.Errorf("%w: entry %d is a %s", ErrAuditNotUpdate, auditID, audit.Action)
+	}
+	return audit, nil
 }
 
 func (s *FeatureService) Health(ctx context.Context) error {
@@ -388,6 +1029,7 @@ func (s *FeatureService) Run(ctx context.Context) {
 						Action:    constraints.PUT,
 						UpdatedAt: time.Now().UnixMilli(),
 					}
+					flag.Revision = ev.Kv.ModRevision
 					s.cache.Update(flag)
 				}
 				// update buffer
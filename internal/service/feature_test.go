@@ -2,15 +2,22 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"mizuflow/internal/buffer"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/model"
 	"mizuflow/internal/repository"
 	v1 "mizuflow/pkg/api/v1"
 	"mizuflow/pkg/constraints"
 	"mizuflow/pkg/logger"
 
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -87,7 +94,7 @@ func TestValidatePayload(t *testing.T) {
 				}
 			}()
 
-			_, err := svc.SaveFeature(context.Background(), tt.flag, "test-op")
+			_, err := svc.SaveFeature(context.Background(), tt.flag, nil, nil, "test-op")
 
 			if tt.wantErr {
 				if err == nil {
@@ -100,6 +107,73 @@ func TestValidatePayload(t *testing.T) {
 	}
 }
 
+func TestValidatePayload_RuleOperators(t *testing.T) {
+	svc := &FeatureService{}
+
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{name: "in", rules: `[{"attribute":"country","operator":"in","value":["JP","KR"]}]`},
+		{name: "semver range", rules: `[{"attribute":"v","operator":"semver_range","value":["1.0.0","2.0.0"]}]`},
+		{name: "regex", rules: `[{"attribute":"email","operator":"regex","value":["@example\\.com$"]}]`},
+		{name: "numeric", rules: `[{"attribute":"age","operator":"gte","value":["18"]}]`},
+		{name: "timestamp", rules: `[{"attribute":"signup_at","operator":"lt","value":["2026-01-01T00:00:00Z"]}]`},
+		{name: "unknown operator", rules: `[{"attribute":"country","operator":"inn","value":["JP"]}]`, wantErr: true},
+		{name: "eq without value", rules: `[{"attribute":"country","operator":"eq","value":[]}]`, wantErr: true},
+		{name: "neq with two values", rules: `[{"attribute":"country","operator":"neq","value":["JP","KR"]}]`, wantErr: true},
+		{name: "mod out of range", rules: `[{"attribute":"uid","operator":"mod","value":["120"]}]`, wantErr: true},
+		{name: "non-numeric gt", rules: `[{"attribute":"age","operator":"gt","value":["old"]}]`, wantErr: true},
+		{name: "invalid version", rules: `[{"attribute":"v","operator":"semver_gt","value":["1.x"]}]`, wantErr: true},
+		{name: "inverted range", rules: `[{"attribute":"v","operator":"semver_range","value":["2.0.0","1.0.0"]}]`, wantErr: true},
+		{name: "invalid regex", rules: `[{"attribute":"email","operator":"regex","value":["("]}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := `{"default_value":"off","rules":` + tt.rules + `}`
+			err := svc.validatePayload(constraints.TypeStrategy, value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePayload_RuleShapes(t *testing.T) {
+	svc := &FeatureService{}
+
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{name: "and group", rules: `[{"conditions":[{"attribute":"country","operator":"in","value":["JP"]},{"attribute":"v","operator":"semver_gt","value":["3.2.0"]}],"result":"on"}]`},
+		{name: "nested or group", rules: `[{"combinator":"or","negate":true,"conditions":[{"attribute":"a","operator":"eq","value":["1"]},{"conditions":[{"attribute":"b","operator":"eq","value":["2"]}]}],"result":"on"}]`},
+		{name: "inline and conditions", rules: `[{"attribute":"a","operator":"eq","value":["1"],"conditions":[{"attribute":"b","operator":"eq","value":["2"]}]}]`, wantErr: true},
+		{name: "unknown combinator", rules: `[{"combinator":"xor","conditions":[{"attribute":"a","operator":"eq","value":["1"]}]}]`, wantErr: true},
+		{name: "invalid nested operator", rules: `[{"conditions":[{"attribute":"a","operator":"like","value":["1"]}]}]`, wantErr: true},
+		{name: "empty nested condition", rules: `[{"conditions":[{}]}]`, wantErr: true},
+		{name: "rollout", rules: `[{"rollout":{"bucket_by":"uid","variants":[{"value":"A","weight":50},{"value":"B","weight":30},{"value":"C","weight":20}]}}]`},
+		{name: "rollout behind condition", rules: `[{"attribute":"country","operator":"in","value":["JP"],"rollout":{"bucket_by":"uid","variants":[{"value":"on","weight":100}]}}]`},
+		{name: "rollout weights below 100", rules: `[{"rollout":{"bucket_by":"uid","variants":[{"value":"A","weight":50},{"value":"B","weight":30}]}}]`, wantErr: true},
+		{name: "rollout negative weight", rules: `[{"rollout":{"bucket_by":"uid","variants":[{"value":"A","weight":110},{"value":"B","weight":-10}]}}]`, wantErr: true},
+		{name: "rollout duplicate variant", rules: `[{"rollout":{"bucket_by":"uid","variants":[{"value":"A","weight":50},{"value":"A","weight":50}]}}]`, wantErr: true},
+		{name: "rollout bucketed by targeting key", rules: `[{"rollout":{"variants":[{"value":"A","weight":100}]}}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := `{"default_value":"off","rules":` + tt.rules + `}`
+			err := svc.validatePayload(constraints.TypeStrategy, value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestSyncToEtcd_Failure(t *testing.T) {
 	// Ensure robustness against Etcd failure (Transactional Outbox)
 	mockKV := &MockKV{
@@ -123,7 +197,7 @@ func TestSyncToEtcd_Failure(t *testing.T) {
 	}
 
 	// Should Log Warn but not Panic/Fail
-	svc.syncToEtcd(123, flag)
+	svc.syncToEtcd(123, outboxPayload{FeatureFlag: flag})
 }
 
 func TestGetCompensation_DelegatesToBuffer(t *testing.T) {
@@ -139,3 +213,392 @@ func TestGetCompensation_DelegatesToBuffer(t *testing.T) {
 		t.Error("Delegation to buffer failed")
 	}
 }
+
+// mockFeatureRepo partially implements repository.FeatureInterface
+type mockFeatureRepo struct {
+	repository.FeatureInterface
+	masters []*model.FeatureMaster
+}
+
+func (m *mockFeatureRepo) List(ctx context.Context, filter repository.FeatureFilter) ([]*model.FeatureMaster, error) {
+	var out []*model.FeatureMaster
+	for _, f := range m.masters {
+		if f.Namespace == filter.Namespace && f.Env == filter.Env {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockFeatureRepo) GetByKeyForUpdate(ctx context.Context, namespace, env, key string) (*model.FeatureMaster, error) {
+	for _, f := range m.masters {
+		if f.Namespace == namespace && f.Env == env && f.Key == key {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestValidatePrerequisites(t *testing.T) {
+	strategy := func(prereqs ...string) string {
+		var b strings.Builder
+		b.WriteString(`{"default_value":"off","prerequisites":[`)
+		for i, key := range prereqs {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(`{"key":"` + key + `","value":"true"}`)
+		}
+		b.WriteString(`]}`)
+		return b.String()
+	}
+	master := func(key, typ, value string) *model.FeatureMaster {
+		return &model.FeatureMaster{Namespace: "default", Env: "dev", Key: key, Type: typ, CurrentVal: value, Status: model.FeatureStatusActive}
+	}
+	archived := master("old", constraints.TypeStrategy, strategy("a"))
+	archived.Status = model.FeatureStatusArchived
+	repo := &mockFeatureRepo{masters: []*model.FeatureMaster{
+		master("a", constraints.TypeBool, "true"),
+		master("b", constraints.TypeStrategy, strategy("a")),
+		master("c", constraints.TypeStrategy, strategy("b")),
+		{Namespace: "payments", Env: "dev", Key: "p", Type: constraints.TypeBool, CurrentVal: "true", Status: model.FeatureStatusActive},
+		archived,
+	}}
+	svc := &FeatureService{featureRepo: repo}
+
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr string
+	}{
+		{name: "no prerequisites", key: "a", value: strategy()},
+		{name: "existing prerequisite", key: "d", value: strategy("c")},
+		{name: "missing prerequisite", key: "d", value: strategy("missing"), wantErr: "does not exist"},
+		{name: "prerequisite of another namespace", key: "d", value: strategy("p"), wantErr: "does not exist"},
+		{name: "self reference", key: "d", value: strategy("d"), wantErr: "own prerequisite"},
+		{name: "direct cycle", key: "a", value: strategy("b"), wantErr: "a -> b -> a"},
+		{name: "indirect cycle", key: "a", value: strategy("c"), wantErr: "a -> c -> b -> a"},
+		{name: "update without cycle", key: "c", value: strategy("a", "b")},
+		{name: "archived prerequisite", key: "d", value: strategy("old"), wantErr: "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := v1.FeatureFlag{Namespace: "default", Env: "dev", Key: tt.key, Type: constraints.TypeStrategy, Value: tt.value}
+			err := svc.validatePrerequisites(context.Background(), repo, flag)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validatePrerequisites() error = %v", err)
+			}
+			if tt.wantErr != "" && (!errors.Is(err, ErrPrerequisite) || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validatePrerequisites() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := svc.validatePayload(constraints.TypeStrategy, strategy("a", "a")); err == nil {
+		t.Error("validatePayload() should reject duplicate prerequisites")
+	}
+}
+
+func TestCheckNotPrerequisite(t *testing.T) {
+	strategy := func(prereq string) string {
+		return `{"default_value":"off","prerequisites":[{"key":"` + prereq + `","value":"true"}]}`
+	}
+	repo := &mockFeatureRepo{masters: []*model.FeatureMaster{
+		{Namespace: "default", Env: "dev", Key: "a", Type: constraints.TypeBool, CurrentVal: "true", Status: model.FeatureStatusActive},
+		{Namespace: "default", Env: "dev", Key: "c", Type: constraints.TypeStrategy, CurrentVal: strategy("a"), Status: model.FeatureStatusActive},
+		{Namespace: "default", Env: "dev", Key: "b", Type: constraints.TypeStrategy, CurrentVal: strategy("a"), Status: model.FeatureStatusActive},
+		{Namespace: "default", Env: "dev", Key: "x", Type: constraints.TypeStrategy, CurrentVal: strategy("y"), Status: model.FeatureStatusArchived},
+	}}
+	svc := &FeatureService{featureRepo: repo}
+
+	err := svc.checkNotPrerequisite(context.Background(), repo, "default", "dev", "a")
+	if !errors.Is(err, ErrFeatureInUse) || !strings.Contains(err.Error(), "prerequisite of b, c") {
+		t.Errorf("checkNotPrerequisite(a) error = %v, want it to name b and c", err)
+	}
+	if err := svc.checkNotPrerequisite(context.Background(), repo, "default", "dev", "y"); err != nil {
+		t.Errorf("checkNotPrerequisite(y) error = %v, archived dependents do not count", err)
+	}
+	if err := svc.checkNotPrerequisite(context.Background(), repo, "payments", "dev", "a"); err != nil {
+		t.Errorf("checkNotPrerequisite() in another namespace error = %v", err)
+	}
+}
+
+func TestOutboxPayload(t *testing.T) {
+	// tasks written before deletions existed carry a bare flag
+	var legacy outboxPayload
+	if err := json.Unmarshal([]byte(`{"namespace":"default","env":"dev","key":"a","value":"true","version":3,"type":"bool"}`), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Deleted || legacy.Key != "a" || legacy.Version != 3 {
+		t.Errorf("legacy payload = %+v, want a put of a at version 3", legacy)
+	}
+
+	data, _ := json.Marshal(outboxPayload{FeatureFlag: v1.FeatureFlag{Namespace: "default", Env: "dev", Key: "a", Version: 4}, Deleted: true})
+	var deleted outboxPayload
+	if err := json.Unmarshal(data, &deleted); err != nil || !deleted.Deleted || deleted.Version != 4 {
+		t.Errorf("payload round trip = %+v, %v, want a delete at version 4", deleted, err)
+	}
+}
+
+// memEtcd is an in-memory etcd with the gets and compare-and-swap
+// transactions the feature repository uses.
+type memEtcd struct {
+	clientv3.KV
+	clientv3.Watcher
+	rev int64
+	kvs map[string]*mvccpb.KeyValue
+}
+
+func newMemEtcd() *memEtcd {
+	return &memEtcd{kvs: make(map[string]*mvccpb.KeyValue)}
+}
+
+func (m *memEtcd) Close() error { return nil }
+
+func (m *memEtcd) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	out := &clientv3.GetResponse{Header: &pb.ResponseHeader{Revision: m.rev}}
+	if kv, ok := m.kvs[key]; ok {
+		out.Kvs = []*mvccpb.KeyValue{kv}
+	}
+	return out, nil
+}
+
+func (m *memEtcd) Txn(ctx context.Context) clientv3.Txn {
+	return &memTxn{etcd: m}
+}
+
+type memTxn struct {
+	etcd *memEtcd
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+func (t *memTxn) If(cs ...clientv3.Cmp) clientv3.Txn   { t.cmps = cs; return t }
+func (t *memTxn) Then(ops ...clientv3.Op) clientv3.Txn { t.ops = ops; return t }
+func (t *memTxn) Else(ops ...clientv3.Op) clientv3.Txn { return t }
+
+func (t *memTxn) Commit() (*clientv3.TxnResponse, error) {
+	m := t.etcd
+	for _, c := range t.cmps {
+		var modRev, createRev int64
+		if kv, ok := m.kvs[string(c.KeyBytes())]; ok {
+			modRev, createRev = kv.ModRevision, kv.CreateRevision
+		}
+		cmp := pb.Compare(c)
+		if (c.Target == pb.Compare_MOD && modRev != cmp.GetModRevision()) ||
+			(c.Target == pb.Compare_CREATE && createRev != cmp.GetCreateRevision()) {
+			return &clientv3.TxnResponse{Header: &pb.ResponseHeader{Revision: m.rev}}, nil
+		}
+	}
+	m.rev++
+	for _, op := range t.ops {
+		key := string(op.KeyBytes())
+		switch {
+		case op.IsPut():
+			kv := &mvccpb.KeyValue{Key: op.KeyBytes(), Value: op.ValueBytes(), CreateRevision: m.rev, ModRevision: m.rev}
+			if old, ok := m.kvs[key]; ok {
+				kv.CreateRevision = old.CreateRevision
+			}
+			m.kvs[key] = kv
+		case op.IsDelete():
+			delete(m.kvs, key)
+		}
+	}
+	return &clientv3.TxnResponse{Header: &pb.ResponseHeader{Revision: m.rev}, Succeeded: true}, nil
+}
+
+func TestPublish_DeleteThenRecreate(t *testing.T) {
+	flag := v1.FeatureFlag{Namespace: "default", Env: "dev", Key: "banner", Type: constraints.TypeBool}
+	old := flag
+	old.Value, old.Version, old.Generation = "false", 2, 7
+	// the hard delete of the old record, then the first save of its successor
+	hardDelete := outboxPayload{FeatureFlag: old, Deleted: true}
+	hardDelete.Version = 3
+	recreated := flag
+	recreated.Value, recreated.Version, recreated.Generation = "true", 1, 8
+
+	tests := []struct {
+		name  string
+		tasks []outboxPayload
+	}{
+		{name: "in order", tasks: []outboxPayload{hardDelete, {FeatureFlag: recreated}}},
+		{name: "recreate published first", tasks: []outboxPayload{{FeatureFlag: recreated}, hardDelete}},
+		{name: "stale put of the old record", tasks: []outboxPayload{hardDelete, {FeatureFlag: recreated}, {FeatureFlag: old}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etcd := newMemEtcd()
+			repo := repository.NewFeatureRepository(etcd)
+			key := BuildFeatureKey(flag.Env, flag.Namespace, flag.Key)
+			ctx := context.Background()
+			if _, err := repo.SaveFeatureIfNewer(ctx, key, old); err != nil {
+				t.Fatal(err)
+			}
+			for _, task := range tt.tasks {
+				if err := publish(ctx, repo, task); err != nil {
+					t.Fatalf("publish(%+v) error = %v", task, err)
+				}
+			}
+
+			kv, ok := etcd.kvs[key]
+			if !ok {
+				t.Fatal("recreated flag was deleted from etcd")
+			}
+			var got v1.FeatureFlag
+			if err := json.Unmarshal(kv.Value, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != recreated {
+				t.Errorf("etcd holds %+v, want the recreated %+v", got, recreated)
+			}
+		})
+	}
+
+	// a value stored before generations existed is still deleted
+	etcd := newMemEtcd()
+	repo := repository.NewFeatureRepository(etcd)
+	key := BuildFeatureKey(flag.Env, flag.Namespace, flag.Key)
+	legacy := old
+	legacy.Generation = 0
+	repo.SaveFeatureIfNewer(context.Background(), key, legacy)
+	if err := publish(context.Background(), repo, hardDelete); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := etcd.kvs[key]; ok {
+		t.Error("delete left a flag without generation in etcd")
+	}
+}
+
+func TestCheckExpectedVersion(t *testing.T) {
+	version := func(v int) *int { return &v }
+	master := &model.FeatureMaster{Key: "a", Version: 3, CurrentVal: "true"}
+
+	tests := []struct {
+		name     string
+		master   *model.FeatureMaster
+		expected *int
+		current  int
+		conflict bool
+	}{
+		{name: "no expectation", master: master},
+		{name: "current version", master: master, expected: version(3)},
+		{name: "stale version", master: master, expected: version(2), current: 3, conflict: true},
+		{name: "create", expected: version(0)},
+		{name: "create over an existing flag", master: master, expected: version(0), current: 3, conflict: true},
+		{name: "update of a missing flag", expected: version(3), conflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExpectedVersion(tt.master, tt.expected)
+			var conflict *VersionConflictError
+			if got := errors.As(err, &conflict); got != tt.conflict {
+				t.Fatalf("checkExpectedVersion() error = %v, want conflict %v", err, tt.conflict)
+			}
+			if tt.conflict && conflict.Current != tt.current {
+				t.Errorf("conflict current version = %d, want %d", conflict.Current, tt.current)
+			}
+			if tt.conflict && tt.master != nil && conflict.CurrentValue != tt.master.CurrentVal {
+				t.Errorf("conflict current value = %q, want %q", conflict.CurrentValue, tt.master.CurrentVal)
+			}
+		})
+	}
+}
+
+// mockFindAuditRepo partially implements repository.AuditInterface
+type mockFindAuditRepo struct {
+	repository.AuditInterface
+	audits map[uint]*model.FeatureAudit
+}
+
+func (m *mockFindAuditRepo) FindByID(ctx context.Context, id uint) (*model.FeatureAudit, error) {
+	return m.audits[id], nil
+}
+
+func TestRollbackFeature_OnlyUpdates(t *testing.T) {
+	audit := func(action, oldValue string) *model.FeatureAudit {
+		return &model.FeatureAudit{Namespace: "default", Env: "dev", Key: "a", Type: constraints.TypeBool, Action: action, OldValue: oldValue}
+	}
+	svc := &FeatureService{auditRepo: &mockFindAuditRepo{audits: map[uint]*model.FeatureAudit{
+		1: audit(model.AuditActionUpdate, "maybe"),
+		2: audit(model.AuditActionMetadata, `{"owner":"payments"}`),
+		3: audit(model.AuditActionArchive, "true"),
+		4: audit(model.AuditActionRestore, ""),
+		5: audit(model.AuditActionDelete, "true"),
+		6: audit(model.AuditActionScheduleFailed, ""),
+		7: audit(model.AuditActionChangeRequested, "true"),
+	}}}
+	version := 3
+
+	for id := uint(1); id <= 7; id++ {
+		_, err := svc.RollbackFeature(context.Background(), "default", "dev", "a", id, &version, "alice")
+		if id == 1 {
+			// an update entry gets as far as validating the value it restores
+			if err == nil || errors.Is(err, ErrAuditNotUpdate) {
+				t.Errorf("RollbackFeature(update) error = %v, want the invalid value refused", err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrAuditNotUpdate) {
+			t.Errorf("RollbackFeature(audit %d) error = %v, want ErrAuditNotUpdate", id, err)
+		}
+	}
+}
+
+func TestFeatureService_ProtectedEnv(t *testing.T) {
+	svc := &FeatureService{protection: NewProtection(map[string]int{"Prod": 2})}
+	ctx := context.Background()
+
+	_, rollbackErr := svc.RollbackFeature(ctx, "default", "prod", "a", 1, nil, "alice")
+	_, deleteErr := svc.DeleteFeature(ctx, "default", "prod", "a", false, "alice")
+	_, restoreErr := svc.RestoreFeature(ctx, "default", "prod", "a", "alice")
+	// metadata edits leave the value alone and are not checked
+	for name, err := range map[string]error{"rollback": rollbackErr, "delete": deleteErr, "restore": restoreErr} {
+		if !errors.Is(err, ErrProtectedEnv) {
+			t.Errorf("%s in a protected env error = %v, want ErrProtectedEnv", name, err)
+		}
+	}
+}
+
+func TestNormalizeMetadata(t *testing.T) {
+	local := time.Date(2027, 3, 1, 9, 30, 15, 500, time.FixedZone("JST", 9*3600))
+
+	got, err := normalizeMetadata(req.FeatureMetadata{
+		Description: "  new checkout flow ",
+		Owner:       " payments",
+		Tags:        []string{"q1", " checkout", "", "q1"},
+		ExpiresAt:   &local,
+	})
+	if err != nil {
+		t.Fatalf("normalizeMetadata() error = %v", err)
+	}
+	if got.Description != "new checkout flow" || got.Owner != "payments" {
+		t.Errorf("normalizeMetadata() did not trim: %+v", got)
+	}
+	if strings.Join(got.Tags, ",") != "checkout,q1" {
+		t.Errorf("tags = %v, want sorted and deduped", got.Tags)
+	}
+	if got.ExpiresAt.Location() != time.UTC || got.ExpiresAt.Nanosecond() != 0 || !got.ExpiresAt.Equal(local.Truncate(time.Second)) {
+		t.Errorf("expires_at = %v, want %v in UTC seconds", got.ExpiresAt, local)
+	}
+
+	if got, _ := normalizeMetadata(req.FeatureMetadata{Tags: []string{" "}}); got.Tags != nil {
+		t.Errorf("tags = %#v, want nil for blank tags", got.Tags)
+	}
+
+	tooMany := make([]string, maxTags+1)
+	for i := range tooMany {
+		tooMany[i] = strings.Repeat("t", i+1)
+	}
+	invalid := []req.FeatureMetadata{
+		{Description: strings.Repeat("d", maxDescriptionLen+1)},
+		{Owner: strings.Repeat("o", maxOwnerLen+1)},
+		{Tags: []string{strings.Repeat("t", maxTagLen+1)}},
+		{Tags: tooMany},
+	}
+	for _, meta := range invalid {
+		if _, err := normalizeMetadata(meta); !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("normalizeMetadata(%+v) error = %v, want ErrInvalidMetadata", meta, err)
+		}
+	}
+}
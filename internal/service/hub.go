@@ -7,15 +7,34 @@ import (
 	"time"
 )
 
+// Message types the hub sends besides flag updates; stream handlers turn them
+// into SSE events of the same name.
+const (
+	MessageTypePing = "ping"
+	// MessageTypeReset tells the clients of its env and namespace to refetch
+	MessageTypeReset = "reset"
+)
+
 type Client struct {
 	Send       chan v1.Message
 	Namespaces map[string]bool
 	Env        string
+	// Events receives admin events; nil for SDK clients, which never get them
+	Events chan AdminEvent
+}
+
+// AdminEvent is a control plane change other than a flag write, e.g. a rollout
+// plan moving on. Type is the SSE event name, Env empty for every env.
+type AdminEvent struct {
+	Type string
+	Env  string
+	Data any
 }
 
 type Hub struct {
 	clients    map[*Client]bool
 	Broadcast  chan v1.Message
+	Admin      chan AdminEvent
 	Register   chan *Client
 	Unregister chan *Client
 
@@ -30,6 +49,7 @@ func NewHub(obs metrics.HubObserver, heartbeatInterval time.Duration, bufferSize
 	return &Hub{
 		clients:           make(map[*Client]bool),
 		Broadcast:         make(chan v1.Message),
+		Admin:             make(chan AdminEvent, bufferSize),
 		Register:          make(chan *Client, bufferSize),
 		Unregister:        make(chan *Client, bufferSize),
 		observer:          obs,
@@ -125,6 +145,18 @@ func (h *Hub) Run() {
 			}
 			h.observer.ObservePushLatency(time.Since(start).Seconds())
 			h.observer.RecordPush()
+		case event := <-h.Admin:
+			for client := range h.clients {
+				if client.Events == nil || (client.Env != "" && event.Env != "" && client.Env != event.Env) {
+					continue
+				}
+				select {
+				case client.Events <- event:
+				default:
+					logger.Warn("admin client too slow, dropping it")
+					removeClient(client)
+				}
+			}
 		case <-heartbeatTicker.C:
 			// TODO: record with another ticker to reduce time gap
 			h.observer.UpdateEventLag(len(h.Broadcast))
@@ -139,7 +171,7 @@ func (h *Hub) Run() {
 			// This reduces redundant bandwidth usage.
 
 			heartbeat := v1.Message{
-				Type: "ping",
+				Type: MessageTypePing,
 			}
 			for client := range h.clients {
 				sendMessage(client, heartbeat)
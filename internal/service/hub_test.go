@@ -108,3 +108,49 @@ func TestHub_Concurrency(t *testing.T) {
 
 	readWg.Wait()
 }
+
+// onlineObserver signals every registration, which Register being buffered
+// does not wait for.
+type onlineObserver struct {
+	MockObserver
+	online chan struct{}
+}
+
+func (o *onlineObserver) IncOnline() { o.online <- struct{}{} }
+
+func TestHub_AdminEvents(t *testing.T) {
+	observer := &onlineObserver{online: make(chan struct{}, 4)}
+	hub := NewHub(observer, time.Minute, 16)
+	go hub.Run()
+
+	sdk := &Client{Send: make(chan v1.Message, 1), Namespaces: map[string]bool{"default": true}, Env: "prod"}
+	prod := &Client{Send: make(chan v1.Message, 1), Namespaces: map[string]bool{"*": true}, Env: "prod", Events: make(chan AdminEvent, 1)}
+	dev := &Client{Send: make(chan v1.Message, 1), Namespaces: map[string]bool{"*": true}, Env: "dev", Events: make(chan AdminEvent, 1)}
+	all := &Client{Send: make(chan v1.Message, 1), Namespaces: map[string]bool{"*": true}, Events: make(chan AdminEvent, 1)}
+	for _, c := range []*Client{sdk, prod, dev, all} {
+		hub.Register <- c
+	}
+	for range 4 {
+		<-observer.online
+	}
+
+	hub.Admin <- AdminEvent{Type: "rollout", Env: "prod", Data: "plan 1"}
+
+	for name, c := range map[string]*Client{"prod": prod, "all": all} {
+		select {
+		case event := <-c.Events:
+			if event.Type != "rollout" || event.Data != "plan 1" {
+				t.Errorf("%s client got %+v", name, event)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s client did not get the admin event", name)
+		}
+	}
+	select {
+	case event := <-dev.Events:
+		t.Errorf("dev client got the prod event %+v", event)
+	case msg := <-sdk.Send:
+		t.Errorf("sdk client got %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
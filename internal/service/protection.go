@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrProtectedEnv = errors.New("environment is protected, writes need an approved change request")
+
+// Protection holds the environments whose flag writes need approvals and how
+// many each needs. Only change requests, and the scheduled changes and rollout
+// plans they create, write to them: the feature, schedule and rollout services
+// refuse every other write with Check. Metadata edits leave the value alone
+// and are not checked.
+type Protection map[string]int
+
+// NewProtection keys the environments lower case like viper does.
+func NewProtection(required map[string]int) Protection {
+	p := make(Protection, len(required))
+	for env, n := range required {
+		if n > 0 {
+			p[strings.ToLower(env)] = n
+		}
+	}
+	return p
+}
+
+// Required is the number of approvals a write to env needs, 0 if none.
+func (p Protection) Required(env string) int {
+	return p[strings.ToLower(env)]
+}
+
+func (p Protection) Protects(env string) bool {
+	return p.Required(env) > 0
+}
+
+// Check refuses a write to env that does not come from a change request.
+func (p Protection) Check(env string) error {
+	if p.Protects(env) {
+		return fmt.Errorf("%w: %s", ErrProtectedEnv, env)
+	}
+	return nil
+}
@@ -118,6 +118,14 @@ func (r *Reconciler) reconcile(ctx context.Context) {
 
 func (r *Reconciler) checkOne(ctx context.Context, dbItem *model.FeatureMaster) {
 	fullKey := BuildFeatureKey(dbItem.Env, dbItem.Namespace, dbItem.Key)
+	if dbItem.Archived() {
+		// an archived flag must not be in etcd, unless a newer restore is
+		flag := v1.FeatureFlag{Namespace: dbItem.Namespace, Env: dbItem.Env, Key: dbItem.Key, Version: dbItem.Version, Generation: dbItem.ID}
+		if _, err := r.etcdRepo.DeleteFeatureIfNotNewer(ctx, fullKey, flag); err != nil {
+			logger.Error("recon: failed to delete archived feature from etcd", zap.String("key", fullKey), zap.Error(err))
+		}
+		return
+	}
 	etcdFlag, err := r.etcdRepo.GetFeature(ctx, fullKey)
 	if err != nil {
 		logger.Error("recon: failed to get feature from etcd", zap.String("key", fullKey), zap.Error(err))
@@ -140,12 +148,13 @@ func (r *Reconciler) checkOne(ctx context.Context, dbItem *model.FeatureMaster)
 
 		// Construct payload
 		flag := v1.FeatureFlag{
-			Namespace: dbItem.Namespace,
-			Env:       dbItem.Env,
-			Key:       dbItem.Key,
-			Value:     dbItem.CurrentVal,
-			Type:      dbItem.Type,
-			Version:   dbItem.Version,
+			Namespace:  dbItem.Namespace,
+			Env:        dbItem.Env,
+			Key:        dbItem.Key,
+			Value:      dbItem.CurrentVal,
+			Type:       dbItem.Type,
+			Version:    dbItem.Version,
+			Generation: dbItem.ID,
 		}
 
 		_, err := r.etcdRepo.SaveFeatureIfNewer(ctx, fullKey, flag)
@@ -0,0 +1,556 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/logger"
+	"strconv"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+// RolloutOperator is the operator audited for the writes of rollout steps.
+const RolloutOperator = "rollout"
+
+// RolloutEventPrefix is where plan changes are put in etcd for every server to
+// pass them on to its admin stream clients.
+const RolloutEventPrefix = "/mizuflow-admin/rollouts/"
+
+// rolloutEventTTL bounds how long plan events stay in etcd; they only feed watches.
+const rolloutEventTTL = 600
+
+const maxRolloutSteps = 20
+
+var ErrRolloutNotFound = errors.New("rollout plan not found")
+var ErrRolloutState = errors.New("rollout plan state does not allow this")
+var ErrInvalidRollout = errors.New("invalid rollout plan")
+var ErrRolloutActive = errors.New("flag already has an active rollout plan")
+
+type RolloutConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// RolloutService moves the mod threshold of a strategy rule through the steps
+// of a plan. Every step is a SaveFeature write, so it is audited and reaches
+// the SDKs like any other change.
+type RolloutService struct {
+	etcdClient *clientv3.Client
+	repo       repository.RolloutInterface
+	features   featureWriter
+	protection Protection
+	hub        *Hub
+	config     RolloutConfig
+	now        func() time.Time
+	publish    func(ctx context.Context, item resp.RolloutItem)
+}
+
+func NewRolloutService(client *clientv3.Client, repo repository.RolloutInterface, features *FeatureService, hub *Hub, cfg RolloutConfig) *RolloutService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	s := &RolloutService{
+		etcdClient: client,
+		repo:       repo,
+		features:   features,
+		protection: features.protection,
+		hub:        hub,
+		config:     cfg,
+		now:        time.Now,
+	}
+	s.publish = s.publishToEtcd
+	return s
+}
+
+// CreateRollout checks the flag and its rule, stores the plan and applies its
+// first step right away. Protected environments are refused, their plans are
+// started by approved change requests.
+func (s *RolloutService) CreateRollout(ctx context.Context, r req.CreateRolloutRequest, operator string) (*resp.RolloutItem, error) {
+	if err := s.protection.Check(r.Env); err != nil {
+		return nil, err
+	}
+	return s.createRollout(ctx, r, operator, 0)
+}
+
+// checkRollout validates a plan against the flag as it is now. It returns the
+// steps of the plan and the percentage the rule is at.
+func (s *RolloutService) checkRollout(ctx context.Context, r req.CreateRolloutRequest) ([]model.RolloutStep, int, error) {
+	steps, err := rolloutSteps(r.Steps)
+	if err != nil {
+		return nil, 0, err
+	}
+	item, err := s.features.GetFeature(ctx, r.Namespace, r.Env, r.Key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if item.Archived {
+		return nil, 0, ErrFeatureArchived
+	}
+	_, current, err := percentageRule(item, r.RuleID)
+	if err != nil {
+		return nil, 0, err
+	}
+	active, err := s.repo.List(ctx, repository.RolloutFilter{Namespace: r.Namespace, Env: r.Env, Key: r.Key})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(active) > 0 {
+		return nil, 0, fmt.Errorf("%w: plan %d, abort it first", ErrRolloutActive, active[0].ID)
+	}
+	return steps, current, nil
+}
+
+// createRollout starts a plan. The change request that approved it, if any,
+// lets its steps write to a protected environment.
+func (s *RolloutService) createRollout(ctx context.Context, r req.CreateRolloutRequest, operator string, changeRequestID uint64) (*resp.RolloutItem, error) {
+	steps, current, err := s.checkRollout(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	plan := &model.RolloutPlan{
+		Namespace:         r.Namespace,
+		Env:               r.Env,
+		Key:               r.Key,
+		RuleID:            r.RuleID,
+		Steps:             steps,
+		InitialPercentage: current,
+		Status:            model.RolloutStatusRunning,
+		ChangeRequestID:   changeRequestID,
+		CreatedBy:         operator,
+		UpdatedBy:         operator,
+	}
+	if len(steps) == 1 {
+		plan.Status = model.RolloutStatusCompleted
+	} else {
+		next := now.Add(steps[0].Duration)
+		plan.NextAt = &next
+	}
+	if err := s.repo.Create(ctx, plan); err != nil {
+		// checkRollout may race with another create, the unique index on the
+		// active plans of a flag decides
+		if errors.Is(err, repository.ErrActiveRollout) {
+			return nil, fmt.Errorf("%w, abort it first", ErrRolloutActive)
+		}
+		logger.Error("failed to create rollout plan", zap.String("key", r.Key), zap.Error(err))
+		return nil, err
+	}
+	if err := s.setPercentage(ctx, plan, steps[0].Percentage); err != nil {
+		s.fail(ctx, plan, err)
+		return nil, err
+	}
+	logger.Info("rollout plan started", zap.Uint64("id", plan.ID), zap.String("key", plan.Key), zap.Int("percentage", steps[0].Percentage))
+	return s.changed(ctx, plan), nil
+}
+
+func (s *RolloutService) ListRollouts(ctx context.Context, filter repository.RolloutFilter) ([]resp.RolloutItem, error) {
+	plans, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]resp.RolloutItem, 0, len(plans))
+	for i := range plans {
+		items = append(items, rolloutItem(&plans[i]))
+	}
+	return items, nil
+}
+
+// PauseRollout holds the current step, keeping what is left of it for resume.
+func (s *RolloutService) PauseRollout(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error) {
+	plan, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != model.RolloutStatusRunning {
+		return nil, fmt.Errorf("%w: plan is %s", ErrRolloutState, rolloutStatusName(plan.Status))
+	}
+	plan.Remaining = max(plan.NextAt.Sub(s.now()), 0)
+	plan.NextAt = nil
+	plan.Status = model.RolloutStatusPaused
+	plan.UpdatedBy = operator
+	if err := s.update(ctx, plan, model.RolloutStatusRunning, plan.Step); err != nil {
+		return nil, err
+	}
+	return s.changed(ctx, plan), nil
+}
+
+func (s *RolloutService) ResumeRollout(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error) {
+	plan, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != model.RolloutStatusPaused {
+		return nil, fmt.Errorf("%w: plan is %s", ErrRolloutState, rolloutStatusName(plan.Status))
+	}
+	next := s.now().Add(plan.Remaining)
+	plan.NextAt = &next
+	plan.Remaining = 0
+	plan.Status = model.RolloutStatusRunning
+	plan.UpdatedBy = operator
+	if err := s.update(ctx, plan, model.RolloutStatusPaused, plan.Step); err != nil {
+		return nil, err
+	}
+	return s.changed(ctx, plan), nil
+}
+
+// AbortRollout stops the plan and puts the rule back to the percentage it had
+// before the plan started.
+func (s *RolloutService) AbortRollout(ctx context.Context, id uint64, operator string) (*resp.RolloutItem, error) {
+	plan, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Active() {
+		return nil, fmt.Errorf("%w: plan is %s", ErrRolloutState, rolloutStatusName(plan.Status))
+	}
+	status := plan.Status
+	plan.NextAt = nil
+	plan.Status = model.RolloutStatusAborted
+	plan.UpdatedBy = operator
+	if err := s.update(ctx, plan, status, plan.Step); err != nil {
+		return nil, err
+	}
+	if err := s.setPercentage(ctx, plan, plan.InitialPercentage); err != nil {
+		plan.Result = truncate("abort could not restore the percentage: "+err.Error(), 255)
+		s.update(ctx, plan, model.RolloutStatusAborted, plan.Step)
+		s.changed(ctx, plan)
+		return nil, err
+	}
+	logger.Info("rollout plan aborted", zap.Uint64("id", plan.ID), zap.String("operator", operator))
+	return s.changed(ctx, plan), nil
+}
+
+func (s *RolloutService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	session, err := concurrency.NewSession(s.etcdClient, concurrency.WithTTL(10))
+	if err != nil {
+		logger.Error("failed to create etcd concurrency session", zap.Error(err))
+		return
+	}
+	defer session.Close()
+
+	mutex := concurrency.NewMutex(session, "/locks/rollout")
+
+	logger.Info("rollout worker started", zap.Duration("interval", s.config.Interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := mutex.Lock(lockCtx)
+			cancel()
+
+			if err != nil {
+				if err == context.DeadlineExceeded {
+					logger.Debug("rollout round skipped, another instance holds the lock")
+				} else {
+					logger.Error("failed to acquire rollout lock", zap.Error(err))
+				}
+				continue
+			}
+
+			s.advanceDue(ctx)
+
+			if err := mutex.Unlock(context.Background()); err != nil {
+				logger.Warn("failed to release rollout lock", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Watch passes the plan changes of all servers on to the admin stream clients
+// of this one. Unlike Run it runs on every server.
+func (s *RolloutService) Watch(ctx context.Context) {
+	for wresp := range s.etcdClient.Watch(ctx, RolloutEventPrefix, clientv3.WithPrefix()) {
+		if wresp.Canceled {
+			logger.Warn("rollout watch canceled", zap.Error(wresp.Err()))
+			return
+		}
+		for _, ev := range wresp.Events {
+			if ev.Type != clientv3.EventTypePut {
+				continue
+			}
+			var item resp.RolloutItem
+			if err := json.Unmarshal(ev.Kv.Value, &item); err != nil {
+				logger.Warn("failed to unmarshal rollout event", zap.String("key", string(ev.Kv.Key)))
+				continue
+			}
+			select {
+			case s.hub.Admin <- AdminEvent{Type: "rollout", Env: item.Env, Data: item}:
+			default:
+				logger.Warn("admin event buffer full, dropping rollout event", zap.Uint64("id", item.ID))
+			}
+		}
+	}
+}
+
+func (s *RolloutService) advanceDue(ctx context.Context) {
+	due, err := s.repo.FetchDue(ctx, s.now(), s.config.BatchSize)
+	if err != nil {
+		logger.Error("rollout: failed to fetch due plans", zap.Error(err))
+		return
+	}
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.advance(ctx, &due[i])
+	}
+}
+
+// advance claims the next step of a plan before writing it, so a pause or
+// abort racing with it either comes first or applies to the new step.
+func (s *RolloutService) advance(ctx context.Context, plan *model.RolloutPlan) {
+	from := plan.Step
+	plan.Step++
+	step := plan.Steps[plan.Step]
+	plan.UpdatedBy = RolloutOperator
+	if plan.Step == len(plan.Steps)-1 {
+		plan.Status = model.RolloutStatusCompleted
+		plan.NextAt = nil
+	} else {
+		next := s.now().Add(step.Duration)
+		plan.NextAt = &next
+	}
+	ok, err := s.repo.Update(ctx, plan, model.RolloutStatusRunning, from)
+	if err != nil {
+		logger.Error("rollout: failed to claim step", zap.Uint64("id", plan.ID), zap.Error(err))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if err := s.setPercentage(ctx, plan, step.Percentage); err != nil {
+		logger.Warn("rollout: step failed", zap.Uint64("id", plan.ID), zap.String("key", plan.Key), zap.Error(err))
+		s.fail(ctx, plan, err)
+		return
+	}
+	logger.Info("rollout: step applied", zap.Uint64("id", plan.ID), zap.String("key", plan.Key), zap.Int("step", plan.Step), zap.Int("percentage", step.Percentage))
+	s.changed(ctx, plan)
+}
+
+// setPercentage writes the threshold into the rule of the current strategy.
+// A concurrent edit of the flag is not overwritten: the write is redone on top
+// of it a few times before giving up.
+func (s *RolloutService) setPercentage(ctx context.Context, plan *model.RolloutPlan, percentage int) error {
+	// the environment may have become protected after the plan started
+	if plan.ChangeRequestID == 0 {
+		if err := s.protection.Check(plan.Env); err != nil {
+			return err
+		}
+	}
+	ctx = context.WithValue(ctx, "TraceID", fmt.Sprintf("rollout-%d-%d", plan.ID, plan.Step))
+	var err error
+	for range 3 {
+		var item *resp.FeatureItem
+		item, err = s.features.GetFeature(ctx, plan.Namespace, plan.Env, plan.Key)
+		if err != nil {
+			return err
+		}
+		var strategy *v1.FeatureStrategy
+		var current int
+		strategy, current, err = percentageRule(item, plan.RuleID)
+		if err != nil {
+			return err
+		}
+		if current == percentage {
+			return nil
+		}
+		for i := range strategy.Rules {
+			if strategy.Rules[i].ID == plan.RuleID {
+				strategy.Rules[i].Values = []string{strconv.Itoa(percentage)}
+			}
+		}
+		value, _ := json.Marshal(strategy)
+		_, err = s.features.SaveFeature(ctx, v1.FeatureFlag{
+			Namespace: plan.Namespace,
+			Env:       plan.Env,
+			Key:       plan.Key,
+			Value:     string(value),
+			Type:      constraints.TypeStrategy,
+		}, nil, &item.Version, RolloutOperator)
+		var conflict *VersionConflictError
+		if !errors.As(err, &conflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *RolloutService) fail(ctx context.Context, plan *model.RolloutPlan, cause error) {
+	status := plan.Status
+	plan.Status = model.RolloutStatusFailed
+	plan.NextAt = nil
+	plan.Result = truncate(cause.Error(), 255)
+	if _, err := s.repo.Update(ctx, plan, status, plan.Step); err != nil {
+		logger.Error("rollout: failed to record failure", zap.Uint64("id", plan.ID), zap.Error(err))
+	}
+	s.changed(ctx, plan)
+}
+
+func (s *RolloutService) get(ctx context.Context, id uint64) (*model.RolloutPlan, error) {
+	plan, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrRolloutNotFound
+	}
+	return plan, nil
+}
+
+func (s *RolloutService) update(ctx context.Context, plan *model.RolloutPlan, status, step int) error {
+	ok, err := s.repo.Update(ctx, plan, status, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRolloutState
+	}
+	return nil
+}
+
+// changed announces the new state of the plan and returns it.
+func (s *RolloutService) changed(ctx context.Context, plan *model.RolloutPlan) *resp.RolloutItem {
+	item := rolloutItem(plan)
+	s.publish(ctx, item)
+	return &item
+}
+
+func (s *RolloutService) publishToEtcd(ctx context.Context, item resp.RolloutItem) {
+	data, _ := json.Marshal(item)
+	lease, err := s.etcdClient.Grant(ctx, rolloutEventTTL)
+	if err != nil {
+		logger.Warn("failed to publish rollout event", zap.Uint64("id", item.ID), zap.Error(err))
+		return
+	}
+	key := RolloutEventPrefix + strconv.FormatUint(item.ID, 10)
+	if _, err := s.etcdClient.Put(ctx, key, string(data), clientv3.WithLease(lease.ID)); err != nil {
+		logger.Warn("failed to publish rollout event", zap.Uint64("id", item.ID), zap.Error(err))
+	}
+}
+
+// rolloutSteps checks that the percentages rise and that every step but the
+// last one lasts.
+func rolloutSteps(in []req.RolloutStep) ([]model.RolloutStep, error) {
+	if len(in) == 0 || len(in) > maxRolloutSteps {
+		return nil, fmt.Errorf("%w: it needs 1 to %d steps", ErrInvalidRollout, maxRolloutSteps)
+	}
+	steps := make([]model.RolloutStep, 0, len(in))
+	for i, step := range in {
+		if step.Percentage < 0 || step.Percentage > 100 {
+			return nil, fmt.Errorf("%w: step %d: percentage must be between 0 and 100", ErrInvalidRollout, i+1)
+		}
+		if i > 0 && step.Percentage <= in[i-1].Percentage {
+			return nil, fmt.Errorf("%w: step %d: percentages must rise from step to step", ErrInvalidRollout, i+1)
+		}
+		var d time.Duration
+		if i < len(in)-1 {
+			var err error
+			if d, err = time.ParseDuration(step.Duration); err != nil || d < time.Minute {
+				return nil, fmt.Errorf("%w: step %d: duration must be at least 1m", ErrInvalidRollout, i+1)
+			}
+		}
+		steps = append(steps, model.RolloutStep{Percentage: step.Percentage, Duration: d})
+	}
+	return steps, nil
+}
+
+// percentageRule finds the rule a plan drives: an inline mod condition, whose
+// threshold is the share of traffic the rule matches.
+func percentageRule(item *resp.FeatureItem, ruleID string) (*v1.FeatureStrategy, int, error) {
+	if item.Type != constraints.TypeStrategy {
+		return nil, 0, fmt.Errorf("%w: flag %s is not a strategy", ErrInvalidRollout, item.Key)
+	}
+	var strategy v1.FeatureStrategy
+	if err := json.Unmarshal([]byte(item.Value), &strategy); err != nil {
+		return nil, 0, fmt.Errorf("%w: flag %s has an invalid strategy: %v", ErrInvalidRollout, item.Key, err)
+	}
+	for _, rule := range strategy.Rules {
+		if rule.ID != ruleID {
+			continue
+		}
+		if rule.Operator != constraints.OpMod || len(rule.Values) != 1 || len(rule.Conditions) > 0 || rule.Negate {
+			return nil, 0, fmt.Errorf("%w: rule %s is not a percentage rule with the mod operator", ErrInvalidRollout, ruleID)
+		}
+		threshold, err := strconv.Atoi(rule.Values[0])
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: rule %s has an invalid mod threshold", ErrInvalidRollout, ruleID)
+		}
+		return &strategy, threshold, nil
+	}
+	return nil, 0, fmt.Errorf("%w: flag %s has no rule %q", ErrInvalidRollout, item.Key, ruleID)
+}
+
+func rolloutItem(p *model.RolloutPlan) resp.RolloutItem {
+	steps := make([]resp.RolloutStep, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		s := resp.RolloutStep{Percentage: step.Percentage}
+		if step.Duration > 0 {
+			s.Duration = step.Duration.String()
+		}
+		steps = append(steps, s)
+	}
+	item := resp.RolloutItem{
+		ID:                p.ID,
+		Namespace:         p.Namespace,
+		Env:               p.Env,
+		Key:               p.Key,
+		RuleID:            p.RuleID,
+		Steps:             steps,
+		Step:              p.Step,
+		Percentage:        p.Steps[p.Step].Percentage,
+		InitialPercentage: p.InitialPercentage,
+		Status:            rolloutStatusName(p.Status),
+		NextAt:            p.NextAt,
+		Result:            p.Result,
+		ChangeRequestID:   p.ChangeRequestID,
+		CreatedBy:         p.CreatedBy,
+		UpdatedBy:         p.UpdatedBy,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+	if p.Status == model.RolloutStatusAborted {
+		item.Percentage = p.InitialPercentage
+	}
+	if p.Remaining > 0 {
+		item.Remaining = p.Remaining.Round(time.Second).String()
+	}
+	return item
+}
+
+func rolloutStatusName(status int) string {
+	switch status {
+	case model.RolloutStatusRunning:
+		return "running"
+	case model.RolloutStatusPaused:
+		return "paused"
+	case model.RolloutStatusCompleted:
+		return "completed"
+	case model.RolloutStatusAborted:
+		return "aborted"
+	case model.RolloutStatusFailed:
+		return "failed"
+	}
+	return "unknown"
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+)
+
+type mockRolloutRepo struct {
+	plans  map[uint64]*model.RolloutPlan
+	nextID uint64
+	// staleList has List miss the active plans, as if they were created
+	// between List and Create
+	staleList bool
+}
+
+// Create enforces one active plan per flag like the unique index does.
+func (m *mockRolloutRepo) Create(ctx context.Context, plan *model.RolloutPlan) error {
+	for _, p := range m.plans {
+		if p.Active() && plan.Active() && p.Namespace == plan.Namespace && p.Env == plan.Env && p.Key == plan.Key {
+			return repository.ErrActiveRollout
+		}
+	}
+	m.nextID++
+	plan.ID = m.nextID
+	p := *plan
+	m.plans[p.ID] = &p
+	return nil
+}
+
+func (m *mockRolloutRepo) Get(ctx context.Context, id uint64) (*model.RolloutPlan, error) {
+	if p, ok := m.plans[id]; ok {
+		c := *p
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRolloutRepo) List(ctx context.Context, filter repository.RolloutFilter) ([]model.RolloutPlan, error) {
+	var out []model.RolloutPlan
+	for _, p := range m.plans {
+		if p.Key == filter.Key && (filter.IncludeDone || p.Active() && !m.staleList) {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockRolloutRepo) FetchDue(ctx context.Context, now time.Time, limit int) ([]model.RolloutPlan, error) {
+	var out []model.RolloutPlan
+	for id := uint64(1); id <= m.nextID; id++ {
+		if p := m.plans[id]; p != nil && p.Status == model.RolloutStatusRunning && !p.NextAt.After(now) {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockRolloutRepo) Update(ctx context.Context, plan *model.RolloutPlan, status, step int) (bool, error) {
+	p := m.plans[plan.ID]
+	if p == nil || p.Status != status || p.Step != step {
+		return false, nil
+	}
+	c := *plan
+	m.plans[plan.ID] = &c
+	return true, nil
+}
+
+func newTestRolloutService(now *time.Time) (*RolloutService, *mockRolloutRepo, *fakeFeatureWriter, *[]resp.RolloutItem) {
+	strategy := `{"default_value":"off","rules":[` +
+		`{"id":"beta","attribute":"tier","operator":"eq","value":["beta"],"result":"on"},` +
+		`{"id":"canary","attribute":"user_id","operator":"mod","value":["0"],"result":"on"}]}`
+	repo := &mockRolloutRepo{plans: map[uint64]*model.RolloutPlan{}}
+	features := &fakeFeatureWriter{flags: map[string]*resp.FeatureItem{
+		"checkout": {Key: "checkout", Type: constraints.TypeStrategy, Value: strategy, Version: 3},
+		"banner":   {Key: "banner", Type: constraints.TypeBool, Value: "false", Version: 1},
+	}}
+	var published []resp.RolloutItem
+	svc := &RolloutService{
+		repo:       repo,
+		features:   features,
+		protection: Protection{"prod": 2},
+		config:     RolloutConfig{BatchSize: 10},
+		now:        func() time.Time { return *now },
+		publish:    func(ctx context.Context, item resp.RolloutItem) { published = append(published, item) },
+	}
+	return svc, repo, features, &published
+}
+
+// canaryThreshold reads the mod threshold of the canary rule back from the flag.
+func canaryThreshold(t *testing.T, features *fakeFeatureWriter) string {
+	t.Helper()
+	var strategy v1.FeatureStrategy
+	if err := json.Unmarshal([]byte(features.flags["checkout"].Value), &strategy); err != nil {
+		t.Fatal(err)
+	}
+	return strategy.Rules[1].Values[0]
+}
+
+func TestRolloutSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []req.RolloutStep
+		wantErr bool
+	}{
+		{name: "plan", steps: []req.RolloutStep{{Percentage: 1, Duration: "6h"}, {Percentage: 25, Duration: "12h"}, {Percentage: 100}}},
+		{name: "single step", steps: []req.RolloutStep{{Percentage: 50}}},
+		{name: "no steps", wantErr: true},
+		{name: "too many", steps: make([]req.RolloutStep, maxRolloutSteps+1), wantErr: true},
+		{name: "falling", steps: []req.RolloutStep{{Percentage: 10, Duration: "1h"}, {Percentage: 5}}, wantErr: true},
+		{name: "over 100", steps: []req.RolloutStep{{Percentage: 10, Duration: "1h"}, {Percentage: 101}}, wantErr: true},
+		{name: "missing duration", steps: []req.RolloutStep{{Percentage: 10}, {Percentage: 100}}, wantErr: true},
+		{name: "too short", steps: []req.RolloutStep{{Percentage: 10, Duration: "10s"}, {Percentage: 100}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := rolloutSteps(tt.steps)
+			if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrInvalidRollout) {
+				t.Fatalf("rolloutSteps() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(steps) != len(tt.steps) {
+				t.Errorf("rolloutSteps() = %+v", steps)
+			}
+		})
+	}
+}
+
+func TestRolloutService_Lifecycle(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	svc, repo, features, published := newTestRolloutService(&now)
+	ctx := context.Background()
+
+	r := req.CreateRolloutRequest{Key: "checkout", RuleID: "canary", Steps: []req.RolloutStep{
+		{Percentage: 1, Duration: "1h"}, {Percentage: 10, Duration: "2h"}, {Percentage: 100},
+	}}
+	prod := r
+	prod.Env = "prod"
+	if _, err := svc.CreateRollout(ctx, prod, "alice"); !errors.Is(err, ErrProtectedEnv) || len(repo.plans) != 0 {
+		t.Fatalf("CreateRollout() in a protected env error = %v, want ErrProtectedEnv", err)
+	}
+	item, err := svc.CreateRollout(ctx, r, "alice")
+	if err != nil {
+		t.Fatalf("CreateRollout() error = %v", err)
+	}
+	if item.Status != "running" || item.Percentage != 1 || canaryThreshold(t, features) != "1" {
+		t.Fatalf("CreateRollout() = %+v, threshold %s, want running at 1%%", item, canaryThreshold(t, features))
+	}
+	if _, err := svc.CreateRollout(ctx, r, "alice"); !errors.Is(err, ErrRolloutActive) {
+		t.Errorf("CreateRollout() for a flag with a running plan error = %v, want ErrRolloutActive", err)
+	}
+	// a create racing with this one passed the check, the index refuses it
+	repo.staleList = true
+	if _, err := svc.CreateRollout(ctx, r, "bob"); !errors.Is(err, ErrRolloutActive) || len(repo.plans) != 1 {
+		t.Errorf("concurrent CreateRollout() error = %v, want ErrRolloutActive and one plan", err)
+	}
+	repo.staleList = false
+	other := r
+	other.RuleID = "beta"
+	if _, err := svc.CreateRollout(ctx, other, "alice"); !errors.Is(err, ErrInvalidRollout) {
+		t.Errorf("CreateRollout() of a rule without mod error = %v, want ErrInvalidRollout", err)
+	}
+
+	svc.advanceDue(ctx)
+	if p := repo.plans[item.ID]; p.Step != 0 {
+		t.Fatalf("plan advanced to step %d before its time", p.Step)
+	}
+
+	now = now.Add(time.Hour)
+	svc.advanceDue(ctx)
+	if p := repo.plans[item.ID]; p.Step != 1 || canaryThreshold(t, features) != "10" {
+		t.Fatalf("plan at step %d, threshold %s, want step 1 at 10%%", p.Step, canaryThreshold(t, features))
+	}
+
+	now = now.Add(30 * time.Minute)
+	paused, err := svc.PauseRollout(ctx, item.ID, "bob")
+	if err != nil || paused.Status != "paused" || paused.Remaining != "1h30m0s" {
+		t.Fatalf("PauseRollout() = %+v, %v, want paused with 1h30m left", paused, err)
+	}
+	now = now.Add(24 * time.Hour)
+	svc.advanceDue(ctx)
+	if p := repo.plans[item.ID]; p.Step != 1 {
+		t.Fatalf("paused plan advanced to step %d", p.Step)
+	}
+	if _, err := svc.PauseRollout(ctx, item.ID, "bob"); !errors.Is(err, ErrRolloutState) {
+		t.Errorf("PauseRollout() of a paused plan error = %v, want ErrRolloutState", err)
+	}
+
+	if _, err := svc.ResumeRollout(ctx, item.ID, "bob"); err != nil {
+		t.Fatalf("ResumeRollout() error = %v", err)
+	}
+	now = now.Add(90 * time.Minute)
+	svc.advanceDue(ctx)
+	p := repo.plans[item.ID]
+	if p.Status != model.RolloutStatusCompleted || p.Step != 2 || canaryThreshold(t, features) != "100" {
+		t.Fatalf("plan = %+v, threshold %s, want completed at 100%%", p, canaryThreshold(t, features))
+	}
+	if _, err := svc.AbortRollout(ctx, item.ID, "bob"); !errors.Is(err, ErrRolloutState) {
+		t.Errorf("AbortRollout() of a completed plan error = %v, want ErrRolloutState", err)
+	}
+
+	for i, op := range features.operators {
+		if op != RolloutOperator || features.traceIDs[i] == "" {
+			t.Errorf("write %d by %q trace %q, want the rollout worker with a trace id", i, op, features.traceIDs[i])
+		}
+	}
+	var statuses []string
+	for _, e := range *published {
+		statuses = append(statuses, e.Status)
+	}
+	want := []string{"running", "running", "paused", "running", "completed"}
+	if len(statuses) != len(want) {
+		t.Fatalf("published %v, want %v", statuses, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("published %v, want %v", statuses, want)
+			break
+		}
+	}
+}
+
+func TestRolloutService_Abort(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	svc, repo, features, _ := newTestRolloutService(&now)
+	ctx := context.Background()
+
+	item, err := svc.CreateRollout(ctx, req.CreateRolloutRequest{Key: "checkout", RuleID: "canary", Steps: []req.RolloutStep{
+		{Percentage: 5, Duration: "1h"}, {Percentage: 50},
+	}}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aborted, err := svc.AbortRollout(ctx, item.ID, "bob")
+	if err != nil || aborted.Status != "aborted" || aborted.Percentage != 0 {
+		t.Fatalf("AbortRollout() = %+v, %v, want aborted at the initial 0%%", aborted, err)
+	}
+	if canaryThreshold(t, features) != "0" {
+		t.Errorf("threshold = %s after abort, want 0", canaryThreshold(t, features))
+	}
+	now = now.Add(2 * time.Hour)
+	svc.advanceDue(ctx)
+	if p := repo.plans[item.ID]; p.Status != model.RolloutStatusAborted || p.Step != 0 {
+		t.Errorf("aborted plan = %+v, want it left alone", p)
+	}
+	if _, err := svc.ResumeRollout(ctx, 99, "bob"); !errors.Is(err, ErrRolloutNotFound) {
+		t.Errorf("ResumeRollout() of an unknown plan error = %v, want ErrRolloutNotFound", err)
+	}
+
+	steps := []req.RolloutStep{{Percentage: 50}}
+	for _, r := range []req.CreateRolloutRequest{
+		{Key: "checkout", RuleID: "beta", Steps: steps},
+		{Key: "checkout", RuleID: "missing", Steps: steps},
+		{Key: "banner", RuleID: "canary", Steps: steps},
+		{Key: "unknown", RuleID: "canary", Steps: steps},
+	} {
+		if _, err := svc.CreateRollout(ctx, r, "alice"); err == nil {
+			t.Errorf("CreateRollout(%s, %s) succeeded, want an error", r.Key, r.RuleID)
+		}
+	}
+}
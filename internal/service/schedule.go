@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"slices"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+// SchedulerOperator is the operator audited for the writes of scheduled changes.
+const SchedulerOperator = "scheduler"
+
+// applyingTimeout is how long a change may stay applying before the scheduler
+// takes its apply for interrupted
+const applyingTimeout = time.Minute
+
+var ErrScheduleNotFound = errors.New("scheduled change not found")
+var ErrScheduleNotPending = errors.New("scheduled change is no longer pending")
+var ErrInvalidSchedule = errors.New("invalid scheduled change")
+
+// featureWriter is the part of FeatureService scheduled changes, rollouts and
+// change requests go through.
+type featureWriter interface {
+	GetFeature(ctx context.Context, namespace, env, key string) (*resp.FeatureItem, error)
+	SaveFeature(ctx context.Context, flag v1.FeatureFlag, meta *req.FeatureMetadata, expectedVersion *int, operator string) (int, error)
+	deleteFeature(ctx context.Context, namespace, env, key string, hard bool, expectedVersion *int, operator string) (int, error)
+	restoreFeature(ctx context.Context, namespace, env, key string, expectedVersion *int, operator string) (int, error)
+	rollbackTarget(ctx context.Context, namespace, env, key string, auditID uint) (*model.FeatureAudit, error)
+	validatePayload(typeStr, value string) error
+}
+
+type ScheduleConfig struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// ScheduleService keeps flag writes for later and applies them once they are
+// due. Like the reconciler, only the instance holding the etcd lock applies.
+type ScheduleService struct {
+	etcdClient *clientv3.Client
+	repo       repository.ScheduleInterface
+	auditRepo  repository.AuditInterface
+	features   featureWriter
+	protection Protection
+	config     ScheduleConfig
+	now        func() time.Time
+}
+
+func NewScheduleService(client *clientv3.Client, repo repository.ScheduleInterface, auditRepo repository.AuditInterface, features *FeatureService, cfg ScheduleConfig) *ScheduleService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &ScheduleService{
+		etcdClient: client,
+		repo:       repo,
+		auditRepo:  auditRepo,
+		features:   features,
+		protection: features.protection,
+		config:     cfg,
+		now:        time.Now,
+	}
+}
+
+// CreateSchedule validates the change now rather than when it is due. Without
+// a type the flag must exist and keeps its current type. Protected
+// environments are refused, their changes are scheduled by approved change
+// requests.
+func (s *ScheduleService) CreateSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string) (*resp.ScheduleItem, error) {
+	if err := s.protection.Check(r.Env); err != nil {
+		return nil, err
+	}
+	r, err := s.prepareSchedule(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return s.createSchedule(ctx, r, operator, 0)
+}
+
+// prepareSchedule validates a change and fills in the type it keeps.
+func (s *ScheduleService) prepareSchedule(ctx context.Context, r req.CreateScheduleRequest) (req.CreateScheduleRequest, error) {
+	if !r.RunAt.After(s.now()) {
+		return r, fmt.Errorf("%w: run_at must be in the future", ErrInvalidSchedule)
+	}
+	if r.Type == "" {
+		current, err := s.features.GetFeature(ctx, r.Namespace, r.Env, r.Key)
+		if errors.Is(err, ErrFeatureNotFound) {
+			return r, fmt.Errorf("%w: type is required for a flag that does not exist yet", ErrInvalidSchedule)
+		}
+		if err != nil {
+			return r, err
+		}
+		r.Type = current.Type
+	}
+	if err := s.features.validatePayload(r.Type, r.Value); err != nil {
+		return r, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return r, nil
+}
+
+// createSchedule stores a prepared change. The change request that approved
+// it, if any, lets it write to a protected environment when it is due.
+func (s *ScheduleService) createSchedule(ctx context.Context, r req.CreateScheduleRequest, operator string, changeRequestID uint64) (*resp.ScheduleItem, error) {
+	change := &model.ScheduledChange{
+		Namespace:       r.Namespace,
+		Env:             r.Env,
+		Key:             r.Key,
+		Value:           r.Value,
+		Type:            r.Type,
+		RunAt:           r.RunAt.UTC(),
+		Status:          model.ScheduleStatusPending,
+		ChangeRequestID: changeRequestID,
+		CreatedBy:       operator,
+	}
+	if err := s.repo.Create(ctx, change); err != nil {
+		logger.Error("failed to create scheduled change", zap.String("key", r.Key), zap.Error(err))
+		return nil, err
+	}
+	logger.Info("feature change scheduled", zap.Uint64("id", change.ID), zap.String("key", r.Key), zap.Time("run_at", change.RunAt))
+	item := scheduleItem(change)
+	return &item, nil
+}
+
+func (s *ScheduleService) ListSchedules(ctx context.Context, filter repository.ScheduleFilter) ([]resp.ScheduleItem, error) {
+	changes, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]resp.ScheduleItem, 0, len(changes))
+	for i := range changes {
+		items = append(items, scheduleItem(&changes[i]))
+	}
+	return items, nil
+}
+
+// CancelSchedule drops a change that has not been applied yet.
+func (s *ScheduleService) CancelSchedule(ctx context.Context, id uint64, operator string) error {
+	change, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		return ErrScheduleNotFound
+	}
+	ok, err := s.repo.UpdateStatus(ctx, id, model.ScheduleStatusPending, model.ScheduleStatusCanceled, 0, "canceled by "+operator)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrScheduleNotPending
+	}
+	logger.Info("scheduled change canceled", zap.Uint64("id", id), zap.String("operator", operator))
+	return nil
+}
+
+func (s *ScheduleService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	session, err := concurrency.NewSession(s.etcdClient, concurrency.WithTTL(10))
+	if err != nil {
+		logger.Error("failed to create etcd concurrency session", zap.Error(err))
+		return
+	}
+	defer session.Close()
+
+	mutex := concurrency.NewMutex(session, "/locks/scheduler")
+
+	logger.Info("scheduler started", zap.Duration("interval", s.config.Interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := mutex.Lock(lockCtx)
+			cancel()
+
+			if err != nil {
+				if err == context.DeadlineExceeded {
+					logger.Debug("scheduler round skipped, another instance holds the lock")
+				} else {
+					logger.Error("failed to acquire scheduler lock", zap.Error(err))
+				}
+				continue
+			}
+
+			s.applyDue(ctx)
+
+			if err := mutex.Unlock(context.Background()); err != nil {
+				logger.Warn("failed to release scheduler lock", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *ScheduleService) applyDue(ctx context.Context) {
+	s.recoverApplying(ctx)
+	due, err := s.repo.FetchDue(ctx, s.now(), s.config.BatchSize)
+	if err != nil {
+		logger.Error("scheduler: failed to fetch due changes", zap.Error(err))
+		return
+	}
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.apply(ctx, &due[i])
+	}
+}
+
+// apply claims a due change, writes it through SaveFeature and records the
+// outcome. A failure is audited too, as the write that should have happened.
+func (s *ScheduleService) apply(ctx context.Context, change *model.ScheduledChange) {
+	ok, err := s.repo.UpdateStatus(ctx, change.ID, model.ScheduleStatusPending, model.ScheduleStatusApplying, 0, "")
+	if err != nil {
+		logger.Error("scheduler: failed to claim change", zap.Uint64("id", change.ID), zap.Error(err))
+		return
+	}
+	if !ok {
+		// canceled meanwhile
+		return
+	}
+
+	traceID := scheduleTraceID(change.ID)
+	var version int
+	// the environment may have become protected after the change was scheduled
+	if change.ChangeRequestID == 0 {
+		err = s.protection.Check(change.Env)
+	}
+	if err == nil {
+		version, err = s.features.SaveFeature(context.WithValue(ctx, "TraceID", traceID), v1.FeatureFlag{
+			Namespace: change.Namespace,
+			Env:       change.Env,
+			Key:       change.Key,
+			Value:     change.Value,
+			Type:      change.Type,
+		}, nil, nil, SchedulerOperator)
+	}
+
+	status, result := model.ScheduleStatusApplied, ""
+	if err != nil {
+		status, result = model.ScheduleStatusFailed, truncate(err.Error(), 255)
+		logger.Warn("scheduler: change failed", zap.Uint64("id", change.ID), zap.String("key", change.Key), zap.Error(err))
+		auditErr := s.auditRepo.Create(ctx, &model.FeatureAudit{
+			Namespace: change.Namespace,
+			Env:       change.Env,
+			Key:       change.Key,
+			NewValue:  change.Value,
+			Type:      change.Type,
+			Action:    model.AuditActionScheduleFailed,
+			Operator:  SchedulerOperator,
+			TraceID:   traceID,
+		})
+		if auditErr != nil {
+			logger.Error("failed to create feature audit", zap.String("key", change.Key), zap.Error(auditErr))
+		}
+	} else {
+		logger.Info("scheduler: change applied", zap.Uint64("id", change.ID), zap.String("key", change.Key), zap.Int("version", version))
+	}
+
+	if _, err := s.repo.UpdateStatus(ctx, change.ID, model.ScheduleStatusApplying, status, version, result); err != nil {
+		logger.Error("scheduler: failed to record change result", zap.Uint64("id", change.ID), zap.Error(err))
+	}
+}
+
+// recoverApplying settles the changes an apply left applying when it was cut
+// off, by a crash or a lost lock, between its claim and its result. The audit
+// entry is written in the same transaction as the flag: with one the change is
+// applied, without one it is pending again and retried.
+func (s *ScheduleService) recoverApplying(ctx context.Context) {
+	stale, err := s.repo.FetchStale(ctx, s.now().Add(-applyingTimeout), s.config.BatchSize)
+	if err != nil {
+		logger.Error("scheduler: failed to fetch interrupted changes", zap.Error(err))
+		return
+	}
+	for i := range stale {
+		change := &stale[i]
+		audits, err := s.auditRepo.ListByKey(ctx, change.Namespace, change.Env, change.Key)
+		if err != nil {
+			logger.Error("scheduler: failed to check interrupted change", zap.Uint64("id", change.ID), zap.Error(err))
+			continue
+		}
+		written := slices.ContainsFunc(audits, func(a model.FeatureAudit) bool {
+			return a.TraceID == scheduleTraceID(change.ID) && (a.Action == "" || a.Action == model.AuditActionUpdate)
+		})
+		if !written {
+			if _, err := s.repo.UpdateStatus(ctx, change.ID, model.ScheduleStatusApplying, model.ScheduleStatusPending, 0, ""); err != nil {
+				logger.Error("scheduler: failed to release interrupted change", zap.Uint64("id", change.ID), zap.Error(err))
+				continue
+			}
+			logger.Warn("scheduler: interrupted change will be retried", zap.Uint64("id", change.ID), zap.String("key", change.Key))
+			continue
+		}
+		// the version it produced, unless the flag was written again since
+		var version int
+		if current, err := s.features.GetFeature(ctx, change.Namespace, change.Env, change.Key); err == nil && current.Value == change.Value {
+			version = current.Version
+		}
+		if _, err := s.repo.UpdateStatus(ctx, change.ID, model.ScheduleStatusApplying, model.ScheduleStatusApplied, version, ""); err != nil {
+			logger.Error("scheduler: failed to record change result", zap.Uint64("id", change.ID), zap.Error(err))
+			continue
+		}
+		logger.Info("scheduler: interrupted change was applied", zap.Uint64("id", change.ID), zap.String("key", change.Key), zap.Int("version", version))
+	}
+}
+
+func scheduleTraceID(id uint64) string {
+	return fmt.Sprintf("schedule-%d", id)
+}
+
+func scheduleItem(c *model.ScheduledChange) resp.ScheduleItem {
+	return resp.ScheduleItem{
+		ID:              c.ID,
+		Namespace:       c.Namespace,
+		Env:             c.Env,
+		Key:             c.Key,
+		Value:           c.Value,
+		Type:            c.Type,
+		RunAt:           c.RunAt,
+		Status:          scheduleStatusName(c.Status),
+		Version:         c.Version,
+		Result:          c.Result,
+		ChangeRequestID: c.ChangeRequestID,
+		CreatedBy:       c.CreatedBy,
+		CreatedAt:       c.CreatedAt,
+		UpdatedAt:       c.UpdatedAt,
+	}
+}
+
+func scheduleStatusName(status int) string {
+	switch status {
+	case model.ScheduleStatusPending:
+		return "pending"
+	case model.ScheduleStatusApplying:
+		return "applying"
+	case model.ScheduleStatusApplied:
+		return "applied"
+	case model.ScheduleStatusFailed:
+		return "failed"
+	case model.ScheduleStatusCanceled:
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// truncate cuts s to n characters, the size of a VARCHAR(n) column.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mizuflow/internal/dto/req"
+	"mizuflow/internal/dto/resp"
+	"mizuflow/internal/model"
+	"mizuflow/internal/repository"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+)
+
+type mockScheduleRepo struct {
+	changes map[uint64]*model.ScheduledChange
+	nextID  uint64
+}
+
+func (m *mockScheduleRepo) Create(ctx context.Context, change *model.ScheduledChange) error {
+	m.nextID++
+	change.ID = m.nextID
+	c := *change
+	m.changes[c.ID] = &c
+	return nil
+}
+
+func (m *mockScheduleRepo) Get(ctx context.Context, id uint64) (*model.ScheduledChange, error) {
+	return m.changes[id], nil
+}
+
+func (m *mockScheduleRepo) List(ctx context.Context, filter repository.ScheduleFilter) ([]model.ScheduledChange, error) {
+	var out []model.ScheduledChange
+	for _, c := range m.changes {
+		out = append(out, *c)
+	}
+	return out, nil
+}
+
+func (m *mockScheduleRepo) FetchDue(ctx context.Context, now time.Time, limit int) ([]model.ScheduledChange, error) {
+	var out []model.ScheduledChange
+	for id := uint64(1); id <= m.nextID; id++ {
+		if c := m.changes[id]; c != nil && c.Status == model.ScheduleStatusPending && !c.RunAt.After(now) {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockScheduleRepo) FetchStale(ctx context.Context, before time.Time, limit int) ([]model.ScheduledChange, error) {
+	var out []model.ScheduledChange
+	for id := uint64(1); id <= m.nextID; id++ {
+		if c := m.changes[id]; c != nil && c.Status == model.ScheduleStatusApplying && c.UpdatedAt.Before(before) {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockScheduleRepo) UpdateStatus(ctx context.Context, id uint64, from, to, version int, result string) (bool, error) {
+	c := m.changes[id]
+	if c == nil || c.Status != from {
+		return false, nil
+	}
+	c.Status, c.Version, c.Result = to, version, result
+	return true, nil
+}
+
+// mockAuditRepo partially implements repository.AuditInterface
+type mockAuditRepo struct {
+	repository.AuditInterface
+	audits []model.FeatureAudit
+}
+
+func (m *mockAuditRepo) Create(ctx context.Context, audit *model.FeatureAudit) error {
+	m.audits = append(m.audits, *audit)
+	return nil
+}
+
+func (m *mockAuditRepo) ListByKey(ctx context.Context, namespace, env, key string) ([]model.FeatureAudit, error) {
+	var out []model.FeatureAudit
+	for _, a := range m.audits {
+		if a.Namespace == namespace && a.Env == env && a.Key == key {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+type fakeFeatureWriter struct {
+	FeatureService
+	flags     map[string]*resp.FeatureItem
+	operators []string
+	traceIDs  []string
+}
+
+func (f *fakeFeatureWriter) GetFeature(ctx context.Context, namespace, env, key string) (*resp.FeatureItem, error) {
+	if item, ok := f.flags[key]; ok {
+		return item, nil
+	}
+	return nil, ErrFeatureNotFound
+}
+
+func (f *fakeFeatureWriter) SaveFeature(ctx context.Context, flag v1.FeatureFlag, meta *req.FeatureMetadata, expectedVersion *int, operator string) (int, error) {
+	f.operators = append(f.operators, operator)
+	traceID, _ := ctx.Value("TraceID").(string)
+	f.traceIDs = append(f.traceIDs, traceID)
+	item, ok := f.flags[flag.Key]
+	if ok && item.Archived {
+		return 0, ErrFeatureArchived
+	}
+	if expectedVersion != nil {
+		var current int
+		if ok {
+			current = item.Version
+		}
+		if current != *expectedVersion {
+			return 0, &VersionConflictError{Expected: *expectedVersion, Current: current}
+		}
+	}
+	if !ok {
+		item = &resp.FeatureItem{Namespace: flag.Namespace, Env: flag.Env, Key: flag.Key}
+		f.flags[flag.Key] = item
+	}
+	item.Version++
+	item.Value, item.Type = flag.Value, flag.Type
+	return item.Version, nil
+}
+
+func (f *fakeFeatureWriter) deleteFeature(ctx context.Context, namespace, env, key string, hard bool, expectedVersion *int, operator string) (int, error) {
+	item, err := f.lockedItem(key, expectedVersion, operator)
+	if err != nil {
+		return 0, err
+	}
+	if item.Archived && !hard {
+		return 0, ErrFeatureAlreadyArchived
+	}
+	item.Version++
+	item.Archived = true
+	if hard {
+		delete(f.flags, key)
+	}
+	return item.Version, nil
+}
+
+func (f *fakeFeatureWriter) restoreFeature(ctx context.Context, namespace, env, key string, expectedVersion *int, operator string) (int, error) {
+	item, err := f.lockedItem(key, expectedVersion, operator)
+	if err != nil {
+		return 0, err
+	}
+	if !item.Archived {
+		return 0, ErrFeatureNotArchived
+	}
+	item.Version++
+	item.Archived = false
+	return item.Version, nil
+}
+
+// lockedItem records the write and checks the flag like the locked master
+// record is checked.
+func (f *fakeFeatureWriter) lockedItem(key string, expectedVersion *int, operator string) (*resp.FeatureItem, error) {
+	f.operators = append(f.operators, operator)
+	item, ok := f.flags[key]
+	if !ok {
+		return nil, ErrFeatureNotFound
+	}
+	if expectedVersion != nil && *expectedVersion != item.Version {
+		return nil, &VersionConflictError{Expected: *expectedVersion, Current: item.Version}
+	}
+	return item, nil
+}
+
+func newTestScheduleService(now time.Time) (*ScheduleService, *mockScheduleRepo, *mockAuditRepo, *fakeFeatureWriter) {
+	repo := &mockScheduleRepo{changes: map[uint64]*model.ScheduledChange{}}
+	audits := &mockAuditRepo{}
+	features := &fakeFeatureWriter{flags: map[string]*resp.FeatureItem{
+		"banner": {Key: "banner", Type: constraints.TypeBool, Value: "false", Version: 4},
+		"legacy": {Key: "legacy", Type: constraints.TypeBool, Value: "false", Version: 2, Archived: true},
+	}}
+	svc := &ScheduleService{
+		repo:       repo,
+		auditRepo:  audits,
+		features:   features,
+		protection: Protection{"prod": 2},
+		config:     ScheduleConfig{BatchSize: 10},
+		now:        func() time.Time { return now },
+	}
+	return svc, repo, audits, features
+}
+
+func TestScheduleService_Create(t *testing.T) {
+	now := time.Date(2026, 11, 26, 12, 0, 0, 0, time.UTC)
+	svc, _, _, _ := newTestScheduleService(now)
+	ctx := context.Background()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		r        req.CreateScheduleRequest
+		wantType string
+		wantErr  error
+	}{
+		{name: "current type", r: req.CreateScheduleRequest{Key: "banner", Value: "true", RunAt: later}, wantType: constraints.TypeBool},
+		{name: "new flag with type", r: req.CreateScheduleRequest{Key: "sale", Value: "30", Type: constraints.TypeNumber, RunAt: later}, wantType: constraints.TypeNumber},
+		{name: "new flag without type", r: req.CreateScheduleRequest{Key: "sale", Value: "30", RunAt: later}, wantErr: ErrInvalidSchedule},
+		{name: "invalid value", r: req.CreateScheduleRequest{Key: "banner", Value: "yes", RunAt: later}, wantErr: ErrInvalidSchedule},
+		{name: "in the past", r: req.CreateScheduleRequest{Key: "banner", Value: "true", RunAt: now.Add(-time.Minute)}, wantErr: ErrInvalidSchedule},
+		{name: "protected env", r: req.CreateScheduleRequest{Env: "prod", Key: "banner", Value: "true", RunAt: later}, wantErr: ErrProtectedEnv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := svc.CreateSchedule(ctx, tt.r, "alice")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSchedule() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (item.Type != tt.wantType || item.Status != "pending" || item.CreatedBy != "alice") {
+				t.Errorf("CreateSchedule() = %+v", item)
+			}
+		})
+	}
+}
+
+func TestScheduleService_ApplyDue(t *testing.T) {
+	now := time.Date(2026, 11, 26, 15, 0, 0, 0, time.UTC)
+	svc, repo, audits, features := newTestScheduleService(now)
+	ctx := context.Background()
+
+	add := func(key, value string, runAt time.Time) uint64 {
+		c := &model.ScheduledChange{Key: key, Value: value, Type: constraints.TypeBool, RunAt: runAt}
+		repo.Create(ctx, c)
+		return c.ID
+	}
+	// scheduled before prod became protected
+	protected := add("banner", "false", now.Add(-2*time.Minute))
+	repo.changes[protected].Env = "prod"
+	due := add("banner", "true", now)
+	failing := add("legacy", "true", now.Add(-time.Minute))
+	canceled := add("banner", "false", now.Add(-time.Minute))
+	future := add("banner", "false", now.Add(time.Minute))
+	if err := svc.CancelSchedule(ctx, canceled, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	svc.applyDue(ctx)
+
+	if c := repo.changes[due]; c.Status != model.ScheduleStatusApplied || c.Version != 5 {
+		t.Errorf("due change = %+v, want applied as version 5", c)
+	}
+	if features.flags["banner"].Value != "true" {
+		t.Errorf("banner = %s, want the scheduled value", features.flags["banner"].Value)
+	}
+	if c := repo.changes[failing]; c.Status != model.ScheduleStatusFailed || c.Result != ErrFeatureArchived.Error() {
+		t.Errorf("failing change = %+v, want failed with the save error", c)
+	}
+	if c := repo.changes[protected]; c.Status != model.ScheduleStatusFailed || features.traceIDs[0] != "schedule-2" {
+		t.Errorf("protected change = %+v, want failed without a write", c)
+	}
+	if len(audits.audits) != 2 || audits.audits[1].Action != model.AuditActionScheduleFailed || audits.audits[1].Key != "legacy" {
+		t.Errorf("audits = %+v, want the failed changes", audits.audits)
+	}
+	if c := repo.changes[canceled]; c.Status != model.ScheduleStatusCanceled {
+		t.Errorf("canceled change = %+v, want it left canceled", c)
+	}
+	if c := repo.changes[future]; c.Status != model.ScheduleStatusPending {
+		t.Errorf("future change = %+v, want it still pending", c)
+	}
+	for i, op := range features.operators {
+		if op != SchedulerOperator || features.traceIDs[i] == "" {
+			t.Errorf("write %d by %q trace %q, want the scheduler with a trace id", i, op, features.traceIDs[i])
+		}
+	}
+
+	if err := svc.CancelSchedule(ctx, due, "bob"); !errors.Is(err, ErrScheduleNotPending) {
+		t.Errorf("CancelSchedule() of an applied change error = %v, want ErrScheduleNotPending", err)
+	}
+	if err := svc.CancelSchedule(ctx, 99, "bob"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("CancelSchedule() of an unknown change error = %v, want ErrScheduleNotFound", err)
+	}
+}
+
+func TestScheduleService_RecoverApplying(t *testing.T) {
+	now := time.Date(2026, 11, 26, 15, 0, 0, 0, time.UTC)
+	svc, repo, audits, features := newTestScheduleService(now)
+	ctx := context.Background()
+
+	add := func(value string, claimedAt time.Time) uint64 {
+		c := &model.ScheduledChange{Key: "banner", Value: value, Type: constraints.TypeBool, RunAt: now.Add(-time.Hour), Status: model.ScheduleStatusApplying, UpdatedAt: claimedAt}
+		repo.Create(ctx, c)
+		return c.ID
+	}
+	// written before the scheduler stopped, its audit entry tells
+	written := add("true", now.Add(-2*applyingTimeout))
+	features.flags["banner"].Value, features.flags["banner"].Version = "true", 5
+	audits.Create(ctx, &model.FeatureAudit{Key: "banner", NewValue: "true", Action: model.AuditActionUpdate, TraceID: scheduleTraceID(written)})
+	interrupted := add("false", now.Add(-2*applyingTimeout))
+	inFlight := add("false", now.Add(-time.Second))
+
+	svc.applyDue(ctx)
+
+	if c := repo.changes[written]; c.Status != model.ScheduleStatusApplied || c.Version != 5 {
+		t.Errorf("written change = %+v, want applied as version 5", c)
+	}
+	if c := repo.changes[interrupted]; c.Status != model.ScheduleStatusApplied || c.Version != 6 || features.traceIDs[0] != scheduleTraceID(interrupted) {
+		t.Errorf("interrupted change = %+v, want it retried as version 6", c)
+	}
+	if c := repo.changes[inFlight]; c.Status != model.ScheduleStatusApplying {
+		t.Errorf("recently claimed change = %+v, want it left applying", c)
+	}
+	if len(features.operators) != 1 {
+		t.Errorf("flag written %d times, want only the interrupted change retried", len(features.operators))
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"mizuflow/internal/model"
 	"mizuflow/internal/repository"
-	v1 "mizuflow/pkg/api/v1"
 	"mizuflow/pkg/logger"
 	"time"
 
@@ -53,9 +52,9 @@ func (w *OutboxWorker) processPending(ctx context.Context) {
 	for _, task := range tasks {
 		logger.Debug("processing outbox task", zap.Int64("id", task.ID), zap.String("key", task.Key))
 
-		var flag v1.FeatureFlag
+		var payload outboxPayload
 		// Payload is the JSON string of feature flag
-		if err := json.Unmarshal([]byte(task.Payload), &flag); err != nil {
+		if err := json.Unmarshal([]byte(task.Payload), &payload); err != nil {
 			logger.Error("failed to unmarshal task payload", zap.Int64("id", task.ID), zap.Error(err))
 			// Mark as failed directly since payload is corrupt
 			w.outboxRepo.UpdateStatus(ctx, uint64(task.ID), model.StatusFailed, task.RetryCount)
@@ -63,8 +62,7 @@ func (w *OutboxWorker) processPending(ctx context.Context) {
 		}
 
 		// Sync to Etcd
-		fullKey := BuildFeatureKey(flag.Env, flag.Namespace, flag.Key)
-		_, err := w.etcdRepo.SaveFeatureIfNewer(ctx, fullKey, flag)
+		err := publish(ctx, w.etcdRepo, payload)
 		if err != nil {
 			logger.Warn("failed to sync task to etcd", zap.Int64("id", task.ID), zap.Error(err))
 			newRetryCount := task.RetryCount + 1
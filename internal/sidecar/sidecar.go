@@ -0,0 +1,206 @@
+package sidecar
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mizuflow/client"
+	"mizuflow/internal/middleware"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/logger"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// changeBufferSize bounds the changes queued for one slow stream reader; past
+// it the reader is told to resync instead of blocking the client.
+const changeBufferSize = 256
+
+// Server exposes a MizuClient over a local HTTP API, so services in other
+// languages evaluate flags with the exact semantics of the Go SDK.
+type Server struct {
+	client            *client.MizuClient
+	heartbeatInterval time.Duration
+}
+
+func New(c *client.MizuClient, heartbeatInterval time.Duration) *Server {
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = 15 * time.Second
+	}
+	return &Server{client: c, heartbeatInterval: heartbeatInterval}
+}
+
+// EvalRequest is the body of /v1/evaluate and /v1/evaluate/all. Namespace
+// defaults to the client's default namespace; Key is ignored by the latter.
+type EvalRequest struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Context   EvalContext `json:"context"`
+}
+
+// EvalContext mirrors client.EvalContext. Attribute values may be strings,
+// numbers, booleans or arrays of strings.
+type EvalContext struct {
+	TargetingKey string         `json:"targeting_key"`
+	Attributes   map[string]any `json:"attributes"`
+}
+
+// Change is one event of /v1/changes. Callers re-evaluate the flag; Value is
+// the raw flag value, not an evaluation.
+type Change struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Type      string `json:"type,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Version   int    `json:"version"`
+	Revision  int64  `json:"revision"`
+	Deleted   bool   `json:"deleted"`
+}
+
+func (s *Server) Handler() *gin.Engine {
+	e := gin.New()
+	e.Use(
+		middleware.RequestID(),
+		middleware.GinZapLogger(),
+		middleware.GinZapRecovery(),
+	)
+	e.GET("/health", s.health)
+
+	v := e.Group("/v1")
+	{
+		v.POST("/evaluate", s.evaluate)
+		v.POST("/evaluate/all", s.evaluateAll)
+		v.GET("/changes", s.changes)
+	}
+	return e
+}
+
+// health is up once the client holds flags, from the server or its cache file;
+// the state tells whether it is in sync.
+func (s *Server) health(c *gin.Context) {
+	state := s.client.State()
+	status := 200
+	if state == client.StateInitializing {
+		status = 503
+	}
+	c.JSON(status, gin.H{
+		"state":     state.String(),
+		"last_sync": s.client.LastSync(),
+	})
+}
+
+func (s *Server) evaluate(c *gin.Context) {
+	req, ok := s.bindEvalRequest(c)
+	if !ok {
+		return
+	}
+	if req.Key == "" {
+		c.JSON(400, gin.H{"error": "key is required"})
+		return
+	}
+	c.JSON(200, s.client.EvaluateDetailIn(req.Namespace, req.Key, req.Context.toClient()))
+}
+
+func (s *Server) evaluateAll(c *gin.Context) {
+	req, ok := s.bindEvalRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{
+		"namespace": req.Namespace,
+		"flags":     s.client.EvaluateAllIn(req.Namespace, req.Context.toClient()),
+	})
+}
+
+// bindEvalRequest decodes numbers as json.Number, so attributes compare with
+// the precision the caller sent.
+func (s *Server) bindEvalRequest(c *gin.Context) (EvalRequest, bool) {
+	var req EvalRequest
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil && len(body) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		err = dec.Decode(&req)
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if req.Namespace == "" {
+		req.Namespace = s.client.DefaultNamespace()
+	}
+	return req, true
+}
+
+func (e EvalContext) toClient() client.EvalContext {
+	return client.EvalContext{TargetingKey: e.TargetingKey, Attributes: e.Attributes}
+}
+
+// changes streams flag changes as server-sent events, optionally limited to
+// the namespaces of the namespace query parameter.
+func (s *Server) changes(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	var namespaces []string
+	for ns := range strings.SplitSeq(c.Query("namespace"), ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	events := make(chan Change, changeBufferSize)
+	overflow := make(chan struct{})
+	var once sync.Once
+	unsubscribe := s.client.OnAnyChange(func(old, new v1.FeatureFlag) {
+		if len(namespaces) > 0 && !slices.Contains(namespaces, new.Namespace) {
+			return
+		}
+		select {
+		case events <- changeOf(new):
+		default:
+			once.Do(func() { close(overflow) })
+		}
+	})
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(s.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.SSEvent("ping", "pong")
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case change := <-events:
+			c.SSEvent("change", change)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", "pong")
+			return true
+		case <-overflow:
+			logger.Warn("change stream reader too slow, resetting", zap.String("ip", c.ClientIP()))
+			c.SSEvent("reset", "resync")
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func changeOf(f v1.FeatureFlag) Change {
+	return Change{
+		Namespace: f.Namespace,
+		Key:       f.Key,
+		Type:      f.Type,
+		Value:     f.Value,
+		Version:   f.Version,
+		Revision:  f.Revision,
+		Deleted:   f.Type == "",
+	}
+}
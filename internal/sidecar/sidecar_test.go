@@ -0,0 +1,206 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mizuflow/client"
+	"mizuflow/internal/api"
+	"mizuflow/internal/metrics"
+	"mizuflow/internal/relay"
+	"mizuflow/internal/service"
+	v1 "mizuflow/pkg/api/v1"
+	"mizuflow/pkg/constraints"
+	"mizuflow/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	logger.InitLogger("test")
+	gin.SetMode(gin.TestMode)
+}
+
+const checkoutStrategy = `{"default_value":"old","rules":[
+	{"attribute":"targeting_key","operator":"eq","value":["vip"],"result":"vip"},
+	{"attribute":"cart","operator":"gt","value":["100"],"result":"new"}
+]}`
+
+// newUpstream serves the stream endpoints of a server backed by a relay store.
+func newUpstream(t *testing.T, namespace string) (*relay.Store, *httptest.Server) {
+	t.Helper()
+	hub := service.NewHub(metrics.NewPrometheusObserver(), time.Second, 64)
+	go hub.Run()
+	store := relay.NewStore("dev", []string{namespace}, 100, hub)
+	store.Reset([]v1.FeatureFlag{
+		{Namespace: namespace, Env: "dev", Key: "checkout", Value: checkoutStrategy, Type: constraints.TypeStrategy, Version: 1, Revision: 1},
+		{Namespace: namespace, Env: "dev", Key: "banner", Value: "true", Type: constraints.TypeBool, Version: 1, Revision: 2},
+	}, 2)
+
+	e := gin.New()
+	h := api.NewStreamHandler(store, hub)
+	e.GET("/v1/stream/watch", h.WatchFeature)
+	e.GET("/v1/stream/snapshot", h.FetchAll)
+	srv := httptest.NewServer(e)
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	return store, srv
+}
+
+func post(t *testing.T, url, body string, out any) int {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestSidecar(t *testing.T) {
+	upstream, upstreamSrv := newUpstream(t, "default")
+	c := client.NewMizuClient(upstreamSrv.URL, "dev", "", []string{"default"}, client.WithCacheFile(filepath.Join(t.TempDir(), "cache.json")))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+	srv := httptest.NewServer(New(c, 0).Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		reason  client.Reason
+		sdkCtx  client.EvalContext
+		wantKey string
+	}{
+		{"targeting key", `{"key":"checkout","context":{"targeting_key":"vip"}}`, "vip", client.ReasonTargetMatch, client.NewEvalContext("vip"), "checkout"},
+		{"number attribute", `{"key":"checkout","context":{"attributes":{"cart":150.5}}}`, "new", client.ReasonTargetMatch, client.NewEvalContext("").With("cart", 150.5), "checkout"},
+		{"no match", `{"namespace":"default","key":"checkout","context":{"attributes":{"cart":20}}}`, "old", client.ReasonDefault, client.NewEvalContext("").With("cart", 20), "checkout"},
+		{"missing flag", `{"key":"nope"}`, "", client.ReasonFlagNotFound, client.EvalContext{}, "nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got client.EvaluationDetail
+			if status := post(t, srv.URL+"/v1/evaluate", tt.body, &got); status != 200 {
+				t.Fatalf("status = %d, want 200", status)
+			}
+			if got.Value != tt.want || got.Reason != tt.reason {
+				t.Errorf("evaluate = %s by %s, want %s by %s", got.Value, got.Reason, tt.want, tt.reason)
+			}
+			if sdk := c.EvaluateDetailIn("default", tt.wantKey, tt.sdkCtx); sdk != got {
+				t.Errorf("evaluate = %+v, the SDK gives %+v", got, sdk)
+			}
+		})
+	}
+
+	for _, body := range []string{`{"context":{}}`, `{"key":`} {
+		if status := post(t, srv.URL+"/v1/evaluate", body, nil); status != 400 {
+			t.Errorf("evaluate %s = %d, want 400", body, status)
+		}
+	}
+
+	var all struct {
+		Flags []client.EvaluationDetail `json:"flags"`
+	}
+	post(t, srv.URL+"/v1/evaluate/all", `{"context":{"targeting_key":"vip"}}`, &all)
+	if len(all.Flags) != 2 || all.Flags[0].Key != "banner" || all.Flags[1].Value != "vip" {
+		t.Errorf("evaluate/all = %+v, want banner and checkout=vip", all.Flags)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/changes?namespace=default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewScanner(resp.Body)
+	// wait for the initial ping, the subscription is in place by then
+	for events.Scan() && events.Text() != "event:ping" {
+	}
+
+	upstream.Apply(v1.Message{Namespace: "default", Env: "dev", Key: "banner", Value: "false", Type: constraints.TypeBool, Version: 2, Revision: 3, Action: constraints.PUT})
+	for events.Scan() {
+		line := events.Text()
+		if !strings.HasPrefix(line, "data:") || strings.Contains(line, "pong") {
+			continue
+		}
+		var change Change
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &change); err != nil {
+			t.Fatal(err)
+		}
+		if change.Key != "banner" || change.Value != "false" || change.Revision != 3 || change.Deleted {
+			t.Errorf("change = %+v, want banner=false at 3", change)
+		}
+		break
+	}
+}
+
+func TestSidecar_ClientDefaultNamespace(t *testing.T) {
+	_, upstreamSrv := newUpstream(t, "checkout")
+	c := client.NewMizuClient(upstreamSrv.URL, "dev", "", []string{"checkout"}, client.WithCacheFile(filepath.Join(t.TempDir(), "cache.json")))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+	srv := httptest.NewServer(New(c, 0).Handler())
+	defer srv.Close()
+
+	var got client.EvaluationDetail
+	if status := post(t, srv.URL+"/v1/evaluate", `{"key":"banner"}`, &got); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if got.Namespace != "checkout" || got.Value != "true" {
+		t.Errorf("evaluate without namespace = %+v, want banner from checkout", got)
+	}
+	var all struct {
+		Namespace string                    `json:"namespace"`
+		Flags     []client.EvaluationDetail `json:"flags"`
+	}
+	post(t, srv.URL+"/v1/evaluate/all", `{}`, &all)
+	if all.Namespace != "checkout" || len(all.Flags) != 2 {
+		t.Errorf("evaluate/all without namespace = %+v, want the checkout flags", all)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	_, upstreamSrv := newUpstream(t, "default")
+	c := client.NewMizuClient(upstreamSrv.URL, "dev", "", []string{"default"}, client.WithCacheFile(filepath.Join(t.TempDir(), "cache.json")))
+	h := New(c, 0).Handler()
+
+	health := func() (int, string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/health", nil)
+		h.ServeHTTP(w, req)
+		var body struct {
+			State string `json:"state"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.State
+	}
+
+	if status, state := health(); status != 503 || state != client.StateInitializing.String() {
+		t.Errorf("health before Start = %d %s, want 503 %s", status, state, client.StateInitializing)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Close()
+	if status, state := health(); status != 200 || state != client.StateLive.String() {
+		t.Errorf("health after Start = %d %s, want 200 %s", status, state, client.StateLive)
+	}
+}
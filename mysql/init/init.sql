@@ -10,6 +10,7 @@ CREATE TABLE IF NOT EXISTS `feature_audits` (
     `old_value`  TEXT COMMENT 'old value',
     `new_value`  TEXT COMMENT 'new value',
     `type`       VARCHAR(32)  COMMENT 'business type: bool, strategy, etc.',
+    `action`     VARCHAR(16)  NOT NULL DEFAULT 'update' COMMENT 'update, archive, restore, delete, metadata, schedule_failed or change_requested/approved/rejected/failed',
     `operator`   VARCHAR(64)  DEFAULT 'system' COMMENT 'operator ID',
     `trace_id`   VARCHAR(36)  NOT NULL COMMENT 'UUID for full traceability',
     `ip`         VARCHAR(45)  COMMENT 'operator IP address',
@@ -29,9 +30,13 @@ CREATE TABLE IF NOT EXISTS `feature_master` (
     `version`     BIGINT UNSIGNED NOT NULL DEFAULT 1 COMMENT 'logical version number, incremented with each change',
     `description` VARCHAR(255) COMMENT 'description of the feature for human understanding',
     `status`      TINYINT DEFAULT 1 COMMENT '1: enabled, 0: archived/disabled',
+    `owner`       VARCHAR(64) COMMENT 'user or team responsible for the feature',
+    `tags`        TEXT COMMENT 'JSON array of free-form tags',
+    `expires_at`  TIMESTAMP NULL COMMENT 'date by which the feature should be removed',
     `created_at`  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     `updated_at`  TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    UNIQUE INDEX `idx_key_env_ns` (`namespace`, `env`, `key`)
+    UNIQUE INDEX `idx_key_env_ns` (`namespace`, `env`, `key`),
+    INDEX `idx_owner` (`owner`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow feature master table';
 
 
@@ -55,16 +60,108 @@ CREATE TABLE IF NOT EXISTS `sdk_clients` (
     `api_key` VARCHAR(64) NOT NULL COMMENT 'SDK client key for authentication',
     `env`        VARCHAR(32) NOT NULL DEFAULT 'dev' COMMENT 'environment',
     `status`     TINYINT NOT NULL DEFAULT 1 COMMENT '1: active, 0: inactive',
+    `relay`      TINYINT(1) NOT NULL DEFAULT 0 COMMENT '1: relay key, may list the keys of its env',
     `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE INDEX `idx_api_key_env` (`api_key`, `env`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow SDK clients table';
 
-INSERT INTO `sdk_clients` (`app_id`, `api_key`, `env`, `status`)
+CREATE TABLE IF NOT EXISTS `feature_exposures` (
+    `id`           BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `env`          VARCHAR(32) NOT NULL,
+    `namespace`    VARCHAR(64) NOT NULL,
+    `key`          VARCHAR(128) NOT NULL COMMENT 'key of the feature',
+    `variant`      VARCHAR(64) NOT NULL COMMENT 'served value, truncated to 64 chars',
+    `reason`       VARCHAR(32) NOT NULL COMMENT 'evaluation reason reported by the SDK',
+    `hits`         BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    `last_seen_at` TIMESTAMP NULL,
+    UNIQUE INDEX `idx_exposure` (`env`, `namespace`, `key`, `variant`, `reason`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow flag exposure counters reported by SDKs';
+
+CREATE TABLE IF NOT EXISTS `scheduled_changes` (
+    `id`         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `env`        VARCHAR(32) NOT NULL,
+    `namespace`  VARCHAR(64) NOT NULL,
+    `key`        VARCHAR(128) NOT NULL COMMENT 'key of the feature',
+    `value`      TEXT COMMENT 'value to write',
+    `type`       VARCHAR(32) NOT NULL COMMENT 'type to write',
+    `run_at`     TIMESTAMP NOT NULL COMMENT 'when the change is due',
+    `status`     TINYINT NOT NULL DEFAULT 0 COMMENT '0: pending, 1: applying, 2: applied, 3: failed, 4: canceled',
+    `version`    BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'feature version written by the change',
+    `result`     VARCHAR(255) COMMENT 'error of a failed change',
+    `change_request_id` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'change request that approved a change to a protected env',
+    `created_by` VARCHAR(64) COMMENT 'operator who scheduled the change',
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX `idx_schedule_due` (`status`, `run_at`),
+    INDEX `idx_schedule_flag` (`namespace`, `env`, `key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow feature changes scheduled for later';
+
+CREATE TABLE IF NOT EXISTS `rollout_plans` (
+    `id`                 BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `env`                VARCHAR(32) NOT NULL,
+    `namespace`          VARCHAR(64) NOT NULL,
+    `key`                VARCHAR(128) NOT NULL COMMENT 'key of the strategy feature',
+    `rule_id`            VARCHAR(64) NOT NULL COMMENT 'id of the mod rule the plan drives',
+    `steps`              TEXT NOT NULL COMMENT 'JSON array of percentage and duration (ns) steps',
+    `step`               INT NOT NULL DEFAULT 0 COMMENT 'index of the step in effect',
+    `initial_percentage` INT NOT NULL DEFAULT 0 COMMENT 'threshold before the plan, restored on abort',
+    `status`             TINYINT NOT NULL DEFAULT 0 COMMENT '0: running, 1: paused, 2: completed, 3: aborted, 4: failed',
+    `next_at`            TIMESTAMP NULL COMMENT 'when the next step is due',
+    `remaining`          BIGINT NOT NULL DEFAULT 0 COMMENT 'ns left of the step when paused',
+    `result`             VARCHAR(255) COMMENT 'error of a failed plan',
+    `change_request_id`  BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'change request that approved a plan in a protected env',
+    `current`            TINYINT(1) NULL COMMENT '1 while the plan is running or paused, NULL after',
+    `created_by`         VARCHAR(64),
+    `updated_by`         VARCHAR(64),
+    `created_at`         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at`         TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX `idx_rollout_due` (`status`, `next_at`),
+    INDEX `idx_rollout_flag` (`namespace`, `env`, `key`),
+    UNIQUE INDEX `idx_rollout_current` (`namespace`, `env`, `key`, `current`) COMMENT 'one active plan per feature'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow progressive rollout plans';
+
+CREATE TABLE IF NOT EXISTS `change_requests` (
+    `id`                 BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `env`                VARCHAR(32) NOT NULL,
+    `namespace`          VARCHAR(64) NOT NULL,
+    `key`                VARCHAR(128) NOT NULL COMMENT 'key of the feature',
+    `action`             VARCHAR(16) NOT NULL DEFAULT 'update' COMMENT 'update, archive, delete, restore, schedule or rollout',
+    `value`              TEXT COMMENT 'proposed value',
+    `type`               VARCHAR(32) NOT NULL COMMENT 'proposed type',
+    `metadata`           TEXT COMMENT 'metadata to replace as JSON, empty to keep it',
+    `payload`            TEXT COMMENT 'schedule or rollout request as JSON',
+    `old_value`          TEXT COMMENT 'value when the change was requested',
+    `base_version`       BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'version the change applies to, 0 for a new feature',
+    `reason`             VARCHAR(255),
+    `required_approvals` INT NOT NULL DEFAULT 1,
+    `status`             TINYINT NOT NULL DEFAULT 0 COMMENT '0: pending, 1: applying, 2: applied, 3: rejected, 4: failed',
+    `version`            BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'feature version written by the change',
+    `result`             VARCHAR(255) COMMENT 'why the change was rejected or failed',
+    `created_by`         VARCHAR(64) COMMENT 'author of the change',
+    `updated_by`         VARCHAR(64),
+    `created_at`         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updated_at`         TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX `idx_status` (`status`),
+    INDEX `idx_change_flag` (`namespace`, `env`, `key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow feature changes awaiting approval in protected environments';
+
+CREATE TABLE IF NOT EXISTS `change_approvals` (
+    `id`         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `request_id` BIGINT UNSIGNED NOT NULL,
+    `approver`   VARCHAR(64) NOT NULL,
+    `comment`    VARCHAR(255),
+    `created_at` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX `idx_approval_user` (`request_id`, `approver`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MizuFlow change request approvals';
+
+INSERT INTO `sdk_clients` (`app_id`, `api_key`, `env`, `status`, `relay`)
 VALUES 
-    ('admin-cli', 'mizu-admin-key-1', 'dev', 1),
-    ('web-portal', 'mizu-web-key', 'dev', 1),
-    ('load-test', 'load-test-key-1', 'dev', 1);
+    ('admin-cli', 'mizu-admin-key-1', 'dev', 1, 0),
+    ('web-portal', 'mizu-web-key', 'dev', 1, 0),
+    ('load-test', 'load-test-key-1', 'dev', 1, 0),
+    ('edge-relay', 'mizu-relay-key-1', 'dev', 1, 1),
+    ('sidecar', 'mizu-sidecar-key-1', 'dev', 1, 0);
 
 INSERT IGNORE INTO `feature_master` (`env`, `namespace`, `key`, `current_val`, `type`, `version`)
 VALUES 
@@ -0,0 +1,18 @@
+package v1
+
+// ExposureReport is a batch of flag evaluations aggregated by an SDK over a time window.
+type ExposureReport struct {
+	Env         string          `json:"env"`
+	WindowStart int64           `json:"window_start"` // unix millis
+	WindowEnd   int64           `json:"window_end"`   // unix millis
+	Dropped     int64           `json:"dropped"`      // evaluations the SDK could not record
+	Entries     []ExposureEntry `json:"entries"`
+}
+
+type ExposureEntry struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Variant   string `json:"variant"`
+	Reason    string `json:"reason"`
+	Count     int64  `json:"count"`
+}
@@ -13,18 +13,88 @@ type FeatureFlag struct {
 	Version   int    `json:"version"`  // feature version
 	Revision  int64  `json:"revision"` // overall etcd revision
 	Type      string `json:"type"`
+	// Generation is the id of the flag's database record. A flag recreated
+	// after a hard delete starts again at version 1 but gets a new record, so
+	// generation and version together order all writes to a key.
+	Generation uint64 `json:"generation,omitempty"`
 }
 
 type FeatureStrategy struct {
 	DefaultValue string `json:"default_value"`
 	Rules        []Rule `json:"rules"`
+	// Seed salts percentage bucketing; the flag key is used when empty.
+	Seed string `json:"seed,omitempty"`
+	// Prerequisites are flags of the same namespace that must evaluate to the
+	// given values, with their own prerequisites met, before any rule applies;
+	// otherwise DefaultValue is served.
+	Prerequisites []Prerequisite `json:"prerequisites,omitempty"`
 }
 
+type Prerequisite struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Rule yields Result when its condition matches. A rule either tests a single
+// attribute inline (Attribute/Operator/Values) or lists Conditions joined by Combinator.
 type Rule struct {
-	Attribute string   `json:"attribute"`
-	Operator  string   `json:"operator"`
-	Values    []string `json:"value"`
-	Result    string   `json:"result"`
+	// ID optionally names the rule so evaluation details can point at it.
+	ID         string      `json:"id,omitempty"`
+	Attribute  string      `json:"attribute,omitempty"`
+	Operator   string      `json:"operator,omitempty"`
+	Values     []string    `json:"value,omitempty"`
+	Combinator string      `json:"combinator,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	Negate     bool        `json:"negate,omitempty"`
+	Result     string      `json:"result"`
+	// Rollout, when set, replaces Result with a weighted pick among variants.
+	Rollout *Rollout `json:"rollout,omitempty"`
+}
+
+// Rollout splits the traffic matched by a rule across weighted variants.
+// Weights are percentages and add up to 100.
+type Rollout struct {
+	BucketBy string    `json:"bucket_by"`
+	Variants []Variant `json:"variants"`
+}
+
+type Variant struct {
+	Value  string `json:"value"`
+	Weight int    `json:"weight"`
+}
+
+// Condition is either a single attribute test or, when Conditions is set, a group
+// of nested conditions joined by Combinator ("and" when empty, or "or").
+type Condition struct {
+	Attribute  string      `json:"attribute,omitempty"`
+	Operator   string      `json:"operator,omitempty"`
+	Values     []string    `json:"value,omitempty"`
+	Combinator string      `json:"combinator,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	Negate     bool        `json:"negate,omitempty"`
+}
+
+// IsGroup reports whether the condition combines nested conditions.
+func (c Condition) IsGroup() bool {
+	return len(c.Conditions) > 0
+}
+
+// HasCondition reports whether the rule restricts who it applies to. Only
+// rollout rules may omit a condition, in which case they match everyone.
+func (r Rule) HasCondition() bool {
+	return r.Attribute != "" || r.Operator != "" || len(r.Conditions) > 0
+}
+
+// Condition returns the root condition of the rule.
+func (r Rule) Condition() Condition {
+	return Condition{
+		Attribute:  r.Attribute,
+		Operator:   r.Operator,
+		Values:     r.Values,
+		Combinator: r.Combinator,
+		Conditions: r.Conditions,
+		Negate:     r.Negate,
+	}
 }
 
 type Message struct {
@@ -0,0 +1,19 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RelayKeys lists the SDK keys of an env for relays. Keys are only sent as
+// hashes, so a relay can check the keys it is shown without holding them.
+type RelayKeys struct {
+	Env       string   `json:"env"`
+	KeyHashes []string `json:"key_hashes"`
+}
+
+// HashAPIKey is the hash of an SDK key in RelayKeys.
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
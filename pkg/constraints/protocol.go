@@ -15,3 +15,29 @@ const (
 	TypeStrategy = "strategy"
 	TypeNumber   = "number"
 )
+
+// Rule operators understood by the SDK evaluator and accepted by strategy validation
+const (
+	OpIn          = "in"
+	OpNotIn       = "not_in"
+	OpEq          = "eq"
+	OpNeq         = "neq"
+	OpMod         = "mod"
+	OpGt          = "gt"
+	OpGte         = "gte"
+	OpLt          = "lt"
+	OpLte         = "lte"
+	OpSemverGt    = "semver_gt"
+	OpSemverLt    = "semver_lt"
+	OpSemverRange = "semver_range"
+	OpRegex       = "regex"
+	OpContains    = "contains"
+	OpStartsWith  = "starts_with"
+	OpEndsWith    = "ends_with"
+)
+
+// Combinators joining the conditions of a rule group
+const (
+	CombinatorAnd = "and"
+	CombinatorOr  = "or"
+)